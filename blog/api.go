@@ -0,0 +1,139 @@
+package blog
+
+// Request types are decoded by the handlers. Response types document
+// the payloads in the OpenAPI spec, which tests validate responses against.
+
+type ArticleRequest struct {
+	Article *ArticleInput `json:"article"`
+}
+
+type ArticleInput struct {
+	Title       string   `json:"title,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Body        string   `json:"body,omitempty"`
+	TagList     []string `json:"tagList,omitempty"`
+}
+
+func (in *ArticleInput) article() *Article {
+	return &Article{
+		Title:       in.Title,
+		Description: in.Description,
+		Body:        in.Body,
+		TagList:     in.TagList,
+	}
+}
+
+type CommentRequest struct {
+	Comment *CommentInput `json:"comment"`
+}
+
+type CommentInput struct {
+	Body string `json:"body"`
+}
+
+type TagRequest struct {
+	Tag *TagInput `json:"tag"`
+}
+
+type TagInput struct {
+	Description string `json:"description"`
+}
+
+type TagAliasRequest struct {
+	Alias string `json:"alias"`
+}
+
+type TagMergeRequest struct {
+	Tags []string `json:"tags"`
+}
+
+type ReportRequest struct {
+	Report *ReportInput `json:"report"`
+}
+
+type ReportInput struct {
+	Reason string `json:"reason"`
+}
+
+// ResolutionRequest resolves a report with one of the moderation actions:
+// dismiss, hide, delete or suspend.
+type ResolutionRequest struct {
+	Resolution *ResolutionInput `json:"resolution"`
+}
+
+type ResolutionInput struct {
+	Action string `json:"action"`
+}
+
+//------------------------------------------------------------------------------
+
+type ArticleResponse struct {
+	Article *Article `json:"article"`
+}
+
+// RedirectResponse is returned for old slugs of renamed articles.
+type RedirectResponse struct {
+	Redirect *Redirect `json:"redirect"`
+}
+
+type Redirect struct {
+	Slug     string `json:"slug"`
+	Location string `json:"location"`
+}
+
+type ArticlesResponse struct {
+	Articles      []*Article `json:"articles"`
+	ArticlesCount int        `json:"articlesCount"`
+}
+
+type CommentResponse struct {
+	Comment *Comment `json:"comment"`
+}
+
+type CommentsResponse struct {
+	Comments []*Comment `json:"comments"`
+}
+
+type TagsResponse struct {
+	Tags []string `json:"tags"`
+	// Trending is set when the tags are requested for a window.
+	Trending []*TrendingTag `json:"trending,omitempty"`
+}
+
+type TagResponse struct {
+	Tag *TagPage `json:"tag"`
+}
+
+// BookmarkRequest is optional. FolderID moves an existing bookmark to the folder.
+type BookmarkRequest struct {
+	Bookmark *BookmarkInput `json:"bookmark"`
+}
+
+type BookmarkInput struct {
+	FolderID uint64 `json:"folderId,omitempty"`
+}
+
+type BookmarkFolderRequest struct {
+	Folder *BookmarkFolderInput `json:"folder"`
+}
+
+type BookmarkFolderInput struct {
+	Name string `json:"name"`
+}
+
+type BookmarkFolderResponse struct {
+	Folder *BookmarkFolder `json:"folder"`
+}
+
+type BookmarkFoldersResponse struct {
+	Folders []*BookmarkFolder `json:"folders"`
+}
+
+type ReportResponse struct {
+	Report *Report `json:"report"`
+}
+
+type ReportsResponse struct {
+	Reports      []*Report `json:"reports"`
+	ReportsCount int       `json:"reportsCount"`
+}
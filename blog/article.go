@@ -17,6 +17,13 @@ type Article struct {
 	Title       string `json:"title"`
 	Description string `json:"description"`
 	Body        string `json:"body"`
+	BodyHTML    string `json:"bodyHtml" bun:"-"`
+
+	// Computed from the body on create and update, see analyzeArticle.
+	WordCount          int        `json:"wordCount"`
+	ReadingTimeMinutes int        `json:"readingTimeMinutes"`
+	TOC                []TOCEntry `json:"toc" bun:"toc,type:jsonb"`
+	Excerpt            string     `json:"excerpt"`
 
 	Author   *org.Profile `json:"author" bun:"rel:belongs-to"`
 	AuthorID uint64       `json:"-"`
@@ -24,11 +31,27 @@ type Article struct {
 	Tags    []ArticleTag `json:"-" bun:"rel:has-many"`
 	TagList []string     `json:"tagList" bun:",scanonly,array"`
 
-	Favorited      bool `json:"favorited" bun:",scanonly"`
-	FavoritesCount int  `json:"favoritesCount" bun:",scanonly"`
+	Favorited bool `json:"favorited" bun:",scanonly"`
+	// Bookmarked is private to the current user, unlike Favorited.
+	Bookmarked bool `json:"bookmarked" bun:",scanonly"`
+	// Counters are maintained by triggers, see `bun db recount`.
+	FavoritesCount int `json:"favoritesCount"`
+	CommentsCount  int `json:"commentsCount"`
 
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
+	// HiddenAt is set by moderators. Hidden articles are excluded by ArticleFilter.
+	HiddenAt time.Time `json:"-" bun:",nullzero"`
+	// ChangedAt is set by a trigger on every change, including counters.
+	ChangedAt time.Time `json:"-" bun:",nullzero"`
+}
+
+// LastModified returns when the article or its author last changed.
+func (a *Article) LastModified() time.Time {
+	if a.Author != nil && a.Author.ChangedAt.After(a.ChangedAt) {
+		return a.Author.ChangedAt
+	}
+	return a.ChangedAt
 }
 
 type ArticleTag struct {
@@ -45,11 +68,13 @@ type FavoriteArticle struct {
 	ArticleID uint64
 }
 
+// SelectArticle returns the article with the slug unless it was hidden by moderators.
 func SelectArticle(ctx context.Context, app *bunapp.App, slug string) (*Article, error) {
 	article := new(Article)
-	if err := app.DB().NewSelect().
+	if err := app.IDB(ctx).NewSelect().
 		Model(article).
 		Where("slug = ?", slug).
+		Where("hidden_at IS NULL").
 		Scan(ctx); err != nil {
 		return nil, err
 	}
@@ -58,7 +83,7 @@ func SelectArticle(ctx context.Context, app *bunapp.App, slug string) (*Article,
 
 func selectArticleByFilter(ctx context.Context, app *bunapp.App, f *ArticleFilter) (*Article, error) {
 	article := new(Article)
-	if err := app.DB().NewSelect().
+	if err := app.IDB(ctx).NewSelect().
 		Model(article).
 		ColumnExpr("?TableColumns").
 		Apply(f.query).
@@ -73,7 +98,14 @@ func selectArticleByFilter(ctx context.Context, app *bunapp.App, f *ArticleFilte
 	return article, nil
 }
 
+// createTags replaces article.TagList with the canonical tags and inserts them.
 func createTags(ctx context.Context, app *bunapp.App, article *Article) error {
+	tagList, err := resolveTags(ctx, app, article.TagList)
+	if err != nil {
+		return err
+	}
+	article.TagList = tagList
+
 	if len(article.TagList) == 0 {
 		return nil
 	}
@@ -86,7 +118,7 @@ func createTags(ctx context.Context, app *bunapp.App, article *Article) error {
 		})
 	}
 
-	if _, err := app.DB().NewInsert().
+	if _, err := app.IDB(ctx).NewInsert().
 		Model(&tags).
 		Exec(ctx); err != nil {
 		return err
@@ -17,6 +17,12 @@ type ArticleFilter struct {
 	Favorited string
 	Slug      string
 	Feed      bool
+	// Bookmarks lists the articles bookmarked by UserID, optionally only in FolderID.
+	Bookmarks bool
+	FolderID  uint64
+	// NoRelations skips the author and tag list columns so callers
+	// can load them lazily, e.g. in batches.
+	NoRelations bool
 	urlstruct.Pager
 }
 
@@ -41,19 +47,23 @@ func decodeArticleFilter(app *bunapp.App, req bunrouter.Request) (*ArticleFilter
 }
 
 func (f *ArticleFilter) query(q *bun.SelectQuery) *bun.SelectQuery {
-	q = q.Relation("Author")
+	if !f.NoRelations {
+		q = q.Relation("Author")
 
-	{
 		subq := f.app.DB().NewSelect().
 			Model((*ArticleTag)(nil)).
 			ColumnExpr("array_agg(t.tag)::text[]").
 			Where("t.article_id = a.id")
 
 		q = q.ColumnExpr("(?) AS tag_list", subq)
+		q = q.Apply(authorFollowingColumn(f.app, f.UserID))
 	}
 
+	q = q.Where("a.hidden_at IS NULL")
+
 	if f.UserID == 0 {
-		q = q.ColumnExpr("false AS favorited")
+		q = q.ColumnExpr("false AS favorited").
+			ColumnExpr("false AS bookmarked")
 	} else {
 		subq := f.app.DB().NewSelect().
 			Model((*FavoriteArticle)(nil)).
@@ -61,29 +71,45 @@ func (f *ArticleFilter) query(q *bun.SelectQuery) *bun.SelectQuery {
 			Where("fa.user_id = ?", f.UserID)
 
 		q = q.ColumnExpr("EXISTS (?) AS favorited", subq)
-	}
 
-	q.Apply(authorFollowingColumn(f.app, f.UserID))
+		subq = f.app.DB().NewSelect().
+			Model((*Bookmark)(nil)).
+			Where("bm.article_id = a.id").
+			Where("bm.user_id = ?", f.UserID)
 
-	{
-		subq := f.app.DB().NewSelect().
-			Model((*FavoriteArticle)(nil)).
-			ColumnExpr("count(*)").
-			Where("fa.article_id = a.id")
+		q = q.ColumnExpr("EXISTS (?) AS bookmarked", subq)
+	}
 
-		q = q.ColumnExpr("(?) AS favorites_count", subq)
+	if f.Bookmarks {
+		q = q.Join("JOIN bookmarks AS b ON b.article_id = a.id").
+			Where("b.user_id = ?", f.UserID)
+		if f.FolderID != 0 {
+			q = q.Where("b.folder_id = ?", f.FolderID)
+		}
 	}
 
 	if f.Author != "" {
-		q = q.Where("author.username = ?", f.Author)
+		subq := f.app.DB().NewSelect().
+			Model((*org.User)(nil)).
+			Column("id").
+			Where("username = ?", f.Author)
+
+		q = q.Where("a.author_id IN (?)", subq)
 	}
 
 	if f.Tag != "" {
+		// Invalid tags don't match any articles.
+		tag, _ := normalizeTag(f.Tag)
+		aliasq := f.app.DB().NewSelect().
+			Model((*TagAlias)(nil)).
+			Column("ta.tag").
+			Where("ta.alias = ?", tag)
+
 		subq := f.app.DB().NewSelect().
 			Model((*ArticleTag)(nil)).
 			Distinct().
 			ColumnExpr("t.article_id").
-			Where("t.tag = ?", f.Tag)
+			Where("t.tag = coalesce((?), ?)", aliasq, tag)
 
 		q = q.Where("a.id IN (?)", subq)
 	}
@@ -94,7 +120,8 @@ func (f *ArticleFilter) query(q *bun.SelectQuery) *bun.SelectQuery {
 			ColumnExpr("fu.followed_user_id").
 			Where("fu.user_id = ?", f.UserID)
 
-		q = q.Where("a.author_id IN (?)", subq)
+		q = q.Where("a.author_id IN (?)", subq).
+			Apply(excludeMuted(f.app, f.UserID, "a.author_id"))
 	} else if f.Slug != "" {
 		q = q.Where("a.slug = ?", f.Slug)
 	}
@@ -2,27 +2,29 @@ package blog
 
 import (
 	"errors"
-	"math/rand"
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/uptrace/bun-realworld-app/bunapp"
 	"github.com/uptrace/bun-realworld-app/httputil"
+	"github.com/uptrace/bun-realworld-app/httputil/httperror"
 	"github.com/uptrace/bun-realworld-app/org"
 	"github.com/uptrace/bunrouter"
-
-	"github.com/gosimple/slug"
 )
 
 const kb = 10
 
 type ArticleHandler struct {
-	app *bunapp.App
+	app     *bunapp.App
+	service *ArticleService
 }
 
 func NewArticleHandler(app *bunapp.App) ArticleHandler {
 	return ArticleHandler{
-		app: app,
+		app:     app,
+		service: NewArticleService(app),
 	}
 }
 
@@ -34,21 +36,25 @@ func (h ArticleHandler) List(w http.ResponseWriter, req bunrouter.Request) error
 		return err
 	}
 
-	articles := make([]*Article, 0)
-	if err := h.app.DB().NewSelect().
-		Model(&articles).
-		ColumnExpr("?TableColumns").
-		Apply(f.query).
-		Limit(f.Pager.GetLimit()).
-		Offset(f.Pager.GetOffset()).
-		Scan(ctx); err != nil {
+	// Read the time first, so concurrent changes can only make it older.
+	var lastModified time.Time
+	if org.UserFromContext(ctx) == nil {
+		lastModified, err = h.service.LastModified(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	articles, err := h.service.List(ctx, f)
+	if err != nil {
 		return err
 	}
 
-	return bunrouter.JSON(w, bunrouter.H{
+	setCacheControl(h.app, w, req)
+	return httputil.ConditionalJSON(w, req, bunrouter.H{
 		"articles":      articles,
 		"articlesCount": len(articles),
-	})
+	}, lastModified)
 }
 
 func (h ArticleHandler) Show(w http.ResponseWriter, req bunrouter.Request) error {
@@ -59,14 +65,23 @@ func (h ArticleHandler) Show(w http.ResponseWriter, req bunrouter.Request) error
 		return err
 	}
 
-	article, err := selectArticleByFilter(ctx, h.app, f)
+	article, err := h.service.Get(ctx, f)
+	if err == ErrArticleNotFound {
+		return h.redirect(w, req, f.Slug)
+	}
 	if err != nil {
 		return err
 	}
 
-	return bunrouter.JSON(w, bunrouter.H{
+	var lastModified time.Time
+	if org.UserFromContext(ctx) == nil {
+		lastModified = article.LastModified()
+	}
+
+	setCacheControl(h.app, w, req)
+	return httputil.ConditionalJSON(w, req, bunrouter.H{
 		"article": article,
-	})
+	}, lastModified)
 }
 
 func (h ArticleHandler) Feed(w http.ResponseWriter, req bunrouter.Request) error {
@@ -76,14 +91,9 @@ func (h ArticleHandler) Feed(w http.ResponseWriter, req bunrouter.Request) error
 	if err != nil {
 		return err
 	}
-	f.Feed = true
-
-	articles := make([]*Article, 0)
-	if err := h.app.DB().NewSelect().
-		Model(&articles).
-		ColumnExpr("?TableColumns").
-		Apply(f.query).
-		Scan(ctx); err != nil {
+
+	articles, err := h.service.Feed(ctx, f)
+	if err != nil {
 		return err
 	}
 
@@ -97,10 +107,7 @@ func (h ArticleHandler) Create(w http.ResponseWriter, req bunrouter.Request) err
 	ctx := req.Context()
 	user := org.UserFromContext(ctx)
 
-	var in struct {
-		Article *Article `json:"article"`
-	}
-
+	var in ArticleRequest
 	if err := httputil.UnmarshalJSON(w, req, &in, 100<<kb); err != nil {
 		return err
 	}
@@ -109,24 +116,12 @@ func (h ArticleHandler) Create(w http.ResponseWriter, req bunrouter.Request) err
 		return errors.New(`JSON field "article" is required`)
 	}
 
-	article := in.Article
-
-	article.Slug = makeSlug(article.Title)
-	article.AuthorID = user.ID
-	article.CreatedAt = h.app.Clock().Now()
-	article.UpdatedAt = h.app.Clock().Now()
+	article := in.Article.article()
 
-	if _, err := h.app.DB().NewInsert().
-		Model(article).
-		Exec(ctx); err != nil {
+	if err := h.service.Create(ctx, user, article); err != nil {
 		return err
 	}
 
-	if err := createTags(ctx, h.app, article); err != nil {
-		return err
-	}
-
-	article.Author = org.NewProfile(user)
 	return bunrouter.JSON(w, bunrouter.H{
 		"article": article,
 	})
@@ -136,10 +131,7 @@ func (h ArticleHandler) Update(w http.ResponseWriter, req bunrouter.Request) err
 	ctx := req.Context()
 	user := org.UserFromContext(ctx)
 
-	var in struct {
-		Article *Article `json:"article"`
-	}
-
+	var in ArticleRequest
 	if err := httputil.UnmarshalJSON(w, req, &in, 100<<kb); err != nil {
 		return err
 	}
@@ -148,36 +140,11 @@ func (h ArticleHandler) Update(w http.ResponseWriter, req bunrouter.Request) err
 		return errors.New(`JSON field "article" is required`)
 	}
 
-	article := in.Article
-
-	if _, err := h.app.DB().NewUpdate().
-		Model(article).
-		Set("title = ?", article.Title).
-		Set("description = ?", article.Description).
-		Set("body = ?", article.Body).
-		Set("updated_at = ?", h.app.Clock().Now()).
-		Where("slug = ?", req.Param("slug")).
-		Returning("*").
-		Exec(ctx); err != nil {
-		return err
-	}
-
-	if _, err := h.app.DB().NewDelete().
-		Model((*ArticleTag)(nil)).
-		Where("article_id = ?", article.ID).
-		Exec(ctx); err != nil {
-		return err
-	}
-
-	if err := createTags(ctx, h.app, article); err != nil {
+	article, err := h.service.Update(ctx, user, req.Param("slug"), in.Article.article())
+	if err != nil {
 		return err
 	}
 
-	if article.TagList == nil {
-		article.TagList = make([]string, 0)
-	}
-
-	article.Author = org.NewProfile(user)
 	return bunrouter.JSON(w, bunrouter.H{
 		"article": article,
 	})
@@ -187,15 +154,7 @@ func (h ArticleHandler) Delete(w http.ResponseWriter, req bunrouter.Request) err
 	ctx := req.Context()
 	user := org.UserFromContext(ctx)
 
-	if _, err := h.app.DB().NewDelete().
-		Model((*Article)(nil)).
-		Where("author_id = ?", user.ID).
-		Where("slug = ?", req.Param("slug")).
-		Exec(ctx); err != nil {
-		return err
-	}
-
-	return nil
+	return h.service.Delete(ctx, user, req.Param("slug"))
 }
 
 func (h ArticleHandler) Favorite(w http.ResponseWriter, req bunrouter.Request) error {
@@ -207,30 +166,10 @@ func (h ArticleHandler) Favorite(w http.ResponseWriter, req bunrouter.Request) e
 		return err
 	}
 
-	article, err := selectArticleByFilter(ctx, h.app, f)
-	if err != nil {
-		return err
-	}
-
-	favoriteArticle := &FavoriteArticle{
-		UserID:    user.ID,
-		ArticleID: article.ID,
-	}
-	res, err := h.app.DB().NewInsert().
-		Model(favoriteArticle).
-		Exec(ctx)
-	if err != nil {
-		return err
-	}
-
-	rowsAffected, err := res.RowsAffected()
+	article, err := h.service.Favorite(ctx, user, f)
 	if err != nil {
 		return err
 	}
-	if rowsAffected != 0 {
-		article.Favorited = true
-		article.FavoritesCount = article.FavoritesCount + 1
-	}
 
 	return bunrouter.JSON(w, bunrouter.H{
 		"article": article,
@@ -246,34 +185,76 @@ func (h ArticleHandler) Unfavorite(w http.ResponseWriter, req bunrouter.Request)
 		return err
 	}
 
-	article, err := selectArticleByFilter(ctx, h.app, f)
+	article, err := h.service.Unfavorite(ctx, user, f)
 	if err != nil {
 		return err
 	}
 
-	res, err := h.app.DB().NewDelete().
-		Model((*FavoriteArticle)(nil)).
-		Where("user_id = ?", user.ID).
-		Where("article_id = ?", article.ID).
-		Exec(ctx)
-	if err != nil {
-		return err
-	}
+	return bunrouter.JSON(w, bunrouter.H{
+		"article": article,
+	})
+}
 
-	rowsAffected, err := res.RowsAffected()
+// redirect replies with 301 Moved Permanently when the article was renamed.
+func (h ArticleHandler) redirect(w http.ResponseWriter, req bunrouter.Request, oldSlug string) error {
+	slug, err := h.service.CurrentSlug(req.Context(), oldSlug)
 	if err != nil {
 		return err
 	}
-	if rowsAffected != 0 {
-		article.Favorited = false
-		article.FavoritesCount = article.FavoritesCount - 1
-	}
 
-	return bunrouter.JSON(w, bunrouter.H{
-		"article": article,
+	location := "/api/articles/" + slug
+
+	hdr := w.Header()
+	hdr.Set("Location", location)
+	hdr.Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusMovedPermanently)
+
+	return bunrouter.JSON(w, RedirectResponse{
+		Redirect: &Redirect{
+			Slug:     slug,
+			Location: location,
+		},
 	})
 }
 
-func makeSlug(title string) string {
-	return slug.Make(title) + "-" + strconv.Itoa(rand.Int())
+// setCacheControl lets shared caches store responses for anonymous users.
+// Responses for authenticated users include favorited and following flags,
+// so they are private and must be revalidated with the ETag. They don't set
+// Last-Modified, because bookmarks and mutes don't touch changed_at.
+func setCacheControl(app *bunapp.App, w http.ResponseWriter, req bunrouter.Request) {
+	h := w.Header()
+	h.Add("Vary", "Authorization")
+
+	if org.UserFromContext(req.Context()) != nil {
+		h.Set("Cache-Control", "private, no-cache")
+		return
+	}
+
+	maxAge := app.Config().HTTPCache.MaxAge
+	h.Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+}
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// parsePage parses the limit and offset query params of paginated lists.
+func parsePage(limitStr, offsetStr string) (limit, offset int, err error) {
+	limit = defaultPageLimit
+	if limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return 0, 0, httperror.BadRequest("invalid_limit",
+				"limit must be a number between 1 and %d", maxPageLimit)
+		}
+	}
+	if offsetStr != "" {
+		offset, err = strconv.Atoi(offsetStr)
+		if err != nil || offset < 0 {
+			return 0, 0, httperror.BadRequest("invalid_offset",
+				"offset must be a non-negative number")
+		}
+	}
+	return limit, offset, nil
 }
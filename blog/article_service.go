@@ -0,0 +1,391 @@
+package blog
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun-realworld-app/bunapp"
+	"github.com/uptrace/bun-realworld-app/httputil/httperror"
+	"github.com/uptrace/bun-realworld-app/org"
+)
+
+var (
+	ErrArticleNotFound = httperror.NotFound("article not found")
+	ErrForbidden       = httperror.New(http.StatusForbidden,
+		"forbidden", "You are not allowed to change this resource")
+)
+
+const (
+	popularTagsCacheKey = "blog:popular_tags"
+	popularTagsCacheTTL = time.Minute
+)
+
+// ArticleService manages articles and favorites independently of HTTP.
+type ArticleService struct {
+	app *bunapp.App
+}
+
+func NewArticleService(app *bunapp.App) *ArticleService {
+	return &ArticleService{
+		app: app,
+	}
+}
+
+func (s *ArticleService) List(ctx context.Context, f *ArticleFilter) ([]*Article, error) {
+	f.app = s.app
+
+	articles := make([]*Article, 0)
+	if err := s.app.IDB(ctx).NewSelect().
+		Model(&articles).
+		ColumnExpr("?TableColumns").
+		Apply(f.query).
+		Limit(f.Pager.GetLimit()).
+		Offset(f.Pager.GetOffset()).
+		Scan(ctx); err != nil {
+		return nil, err
+	}
+	renderArticles(s.app, articles...)
+	return articles, nil
+}
+
+// Feed returns articles written by the users f.UserID follows.
+func (s *ArticleService) Feed(ctx context.Context, f *ArticleFilter) ([]*Article, error) {
+	f.app = s.app
+	f.Feed = true
+
+	articles := make([]*Article, 0)
+	if err := s.app.IDB(ctx).NewSelect().
+		Model(&articles).
+		ColumnExpr("?TableColumns").
+		Apply(f.query).
+		Scan(ctx); err != nil {
+		return nil, err
+	}
+	renderArticles(s.app, articles...)
+	return articles, nil
+}
+
+func (s *ArticleService) Get(ctx context.Context, f *ArticleFilter) (*Article, error) {
+	f.app = s.app
+
+	article, err := selectArticleByFilter(ctx, s.app, f)
+	if err != nil {
+		return nil, articleErr(err)
+	}
+	renderArticles(s.app, article)
+	return article, nil
+}
+
+// LastModified returns when any article or author last changed. Hidden articles
+// are included and deleted articles touch their authors, so the time also
+// moves when articles leave lists.
+func (s *ArticleService) LastModified(ctx context.Context) (time.Time, error) {
+	articles := s.app.IDB(ctx).NewSelect().
+		Model((*Article)(nil)).
+		ColumnExpr("max(a.changed_at)")
+	users := s.app.IDB(ctx).NewSelect().
+		Model((*org.User)(nil)).
+		ColumnExpr("max(u.changed_at)")
+
+	var t bun.NullTime
+	if err := s.app.IDB(ctx).NewSelect().
+		ColumnExpr("greatest((?), (?))", articles, users).
+		Scan(ctx, &t); err != nil {
+		return time.Time{}, err
+	}
+	return t.Time, nil
+}
+
+// Tags returns all tags ordered by the number of articles.
+func (s *ArticleService) Tags(ctx context.Context) ([]string, error) {
+	if v, ok := s.app.Cache().Get(popularTagsCacheKey); ok {
+		return v.([]string), nil
+	}
+
+	tags := make([]string, 0)
+	if err := s.app.IDB(ctx).NewSelect().
+		Model((*ArticleTag)(nil)).
+		ColumnExpr("t.tag").
+		Join("JOIN articles AS a ON a.id = t.article_id").
+		Where("a.hidden_at IS NULL").
+		GroupExpr("t.tag").
+		OrderExpr("count(t.tag) DESC").
+		Scan(ctx, &tags); err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	s.app.Cache().Set(popularTagsCacheKey, tags, popularTagsCacheTTL)
+	return tags, nil
+}
+
+// TagsByArticleID returns the tags of the articles with the ids.
+func (s *ArticleService) TagsByArticleID(ctx context.Context, ids []uint64) (map[uint64][]string, error) {
+	var tags []ArticleTag
+	if err := s.app.IDB(ctx).NewSelect().
+		Model(&tags).
+		Where("article_id IN (?)", bun.In(ids)).
+		Scan(ctx); err != nil {
+		return nil, err
+	}
+
+	m := make(map[uint64][]string, len(ids))
+	for _, t := range tags {
+		m[t.ArticleID] = append(m[t.ArticleID], t.Tag)
+	}
+	return m, nil
+}
+
+// Create inserts the article and its tags on behalf of the user.
+func (s *ArticleService) Create(ctx context.Context, user *org.User, article *Article) error {
+	article.AuthorID = user.ID
+	article.CreatedAt = s.app.Clock().Now()
+	article.UpdatedAt = s.app.Clock().Now()
+
+	if err := retrySlugConflict(func() error {
+		return s.app.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			return s.create(ctx, tx, article)
+		})
+	}); err != nil {
+		return err
+	}
+
+	if article.TagList == nil {
+		article.TagList = make([]string, 0)
+	}
+	article.Author = org.NewProfile(user)
+	renderArticles(s.app, article)
+	return nil
+}
+
+func (s *ArticleService) create(ctx context.Context, tx bun.Tx, article *Article) error {
+	analyzeArticle(article)
+
+	var err error
+	article.Slug, err = makeSlug(ctx, s.app, article.Title, 0)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.NewInsert().
+		Model(article).
+		Exec(ctx); err != nil {
+		return err
+	}
+
+	if err := createTags(ctx, s.app, article); err != nil {
+		return err
+	}
+	return s.app.Invalidate(ctx, popularTagsCacheKey)
+}
+
+// Update replaces the content and tags of the article with the slug.
+// Only the author can update the article.
+func (s *ArticleService) Update(
+	ctx context.Context, user *org.User, slug string, in *Article,
+) (*Article, error) {
+	article := in
+
+	if err := retrySlugConflict(func() error {
+		return s.app.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			return s.update(ctx, tx, user, slug, article)
+		})
+	}); err != nil {
+		return nil, err
+	}
+
+	if article.TagList == nil {
+		article.TagList = make([]string, 0)
+	}
+	article.Author = org.NewProfile(user)
+	renderArticles(s.app, article)
+	return article, nil
+}
+
+func (s *ArticleService) update(
+	ctx context.Context, tx bun.Tx, user *org.User, slug string, article *Article,
+) error {
+	existing, err := SelectArticle(ctx, s.app, slug)
+	if err != nil {
+		return articleErr(err)
+	}
+	if existing.AuthorID != user.ID {
+		return ErrForbidden
+	}
+
+	// The slug follows the title, old slugs are redirected.
+	newSlug := existing.Slug
+	if article.Title != "" && article.Title != existing.Title {
+		newSlug, err = makeSlug(ctx, s.app, article.Title, existing.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	analyzeArticle(article)
+
+	if _, err := tx.NewUpdate().
+		Model(article).
+		Set("slug = ?", newSlug).
+		Set("title = ?", article.Title).
+		Set("description = ?", article.Description).
+		Set("body = ?", article.Body).
+		Set("word_count = ?", article.WordCount).
+		Set("reading_time_minutes = ?", article.ReadingTimeMinutes).
+		Set("toc = ?", article.TOC).
+		Set("excerpt = ?", article.Excerpt).
+		Set("updated_at = ?", s.app.Clock().Now()).
+		Where("id = ?", existing.ID).
+		Returning("*").
+		Exec(ctx); err != nil {
+		return err
+	}
+
+	if newSlug != existing.Slug {
+		if err := renameSlug(ctx, s.app, article, existing.Slug); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.NewDelete().
+		Model((*ArticleTag)(nil)).
+		Where("article_id = ?", article.ID).
+		Exec(ctx); err != nil {
+		return err
+	}
+
+	if err := createTags(ctx, s.app, article); err != nil {
+		return err
+	}
+	return s.app.Invalidate(ctx, popularTagsCacheKey)
+}
+
+// Delete deletes the article with the slug. Only the author can delete the article.
+func (s *ArticleService) Delete(ctx context.Context, user *org.User, slug string) error {
+	return s.app.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		article, err := SelectArticle(ctx, s.app, slug)
+		if err != nil {
+			return articleErr(err)
+		}
+		if article.AuthorID != user.ID {
+			return ErrForbidden
+		}
+
+		if _, err := tx.NewDelete().
+			Model((*Article)(nil)).
+			Where("id = ?", article.ID).
+			Exec(ctx); err != nil {
+			return err
+		}
+		return s.app.Invalidate(ctx, popularTagsCacheKey)
+	})
+}
+
+// Favorite marks the article as favorited by the user. Favoriting an article
+// twice is a no-op.
+func (s *ArticleService) Favorite(
+	ctx context.Context, user *org.User, f *ArticleFilter,
+) (*Article, error) {
+	f.UserID = user.ID
+
+	article, err := s.Get(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+
+	var rowsAffected int64
+	if err := s.app.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if err := checkBlocked(ctx, tx, s.app, article, user); err != nil {
+			return err
+		}
+
+		favoriteArticle := &FavoriteArticle{
+			UserID:    user.ID,
+			ArticleID: article.ID,
+		}
+		res, err := tx.NewInsert().
+			Model(favoriteArticle).
+			On("CONFLICT DO NOTHING").
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		rowsAffected, err = res.RowsAffected()
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	if rowsAffected != 0 {
+		article.Favorited = true
+		article.FavoritesCount++
+	}
+
+	return article, nil
+}
+
+func (s *ArticleService) Unfavorite(
+	ctx context.Context, user *org.User, f *ArticleFilter,
+) (*Article, error) {
+	f.UserID = user.ID
+
+	article, err := s.Get(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.app.IDB(ctx).NewDelete().
+		Model((*FavoriteArticle)(nil)).
+		Where("user_id = ?", user.ID).
+		Where("article_id = ?", article.ID).
+		Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if rowsAffected != 0 {
+		article.Favorited = false
+		article.FavoritesCount--
+	}
+
+	return article, nil
+}
+
+// CurrentSlug returns the current slug of the article that used the old slug.
+func (s *ArticleService) CurrentSlug(ctx context.Context, oldSlug string) (string, error) {
+	var slug string
+	if err := s.app.IDB(ctx).NewSelect().
+		Model((*ArticleSlugHistory)(nil)).
+		ColumnExpr("a.slug").
+		Join("JOIN articles AS a ON a.id = ash.article_id").
+		Where("ash.slug = ?", oldSlug).
+		Where("a.hidden_at IS NULL").
+		Scan(ctx, &slug); err != nil {
+		return "", articleErr(err)
+	}
+	return slug, nil
+}
+
+// Recount repairs the favorite and comment counters, e.g. after the triggers were disabled.
+func (s *ArticleService) Recount(ctx context.Context) error {
+	_, err := s.app.IDB(ctx).NewUpdate().
+		Model((*Article)(nil)).
+		Set("favorites_count = (SELECT count(*) FROM favorite_articles AS fa WHERE fa.article_id = a.id)").
+		Set("comments_count = (SELECT count(*) FROM comments AS c WHERE c.article_id = a.id)").
+		Where("TRUE").
+		Exec(ctx)
+	return err
+}
+
+func articleErr(err error) error {
+	if err == sql.ErrNoRows {
+		return ErrArticleNotFound
+	}
+	return err
+}
@@ -0,0 +1,129 @@
+package blog
+
+import (
+	"context"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yuin/goldmark/ast"
+	extast "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+const (
+	wordsPerMinute   = 200
+	maxExcerptLength = 200
+)
+
+// TOCEntry is a heading in the table of contents. ID matches the id
+// attribute of the heading in the rendered HTML.
+type TOCEntry struct {
+	Level int    `json:"level"`
+	Text  string `json:"text"`
+	ID    string `json:"id"`
+}
+
+// analyzeArticle computes the word count, reading time, table of contents
+// and excerpt from the Markdown body.
+func analyzeArticle(article *Article) {
+	src := []byte(article.Body)
+	doc := markdownParser.Parser().Parse(text.NewReader(src))
+
+	var words int
+	var excerpt []string
+	toc := make([]TOCEntry, 0)
+
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		switch n := n.(type) {
+		case *ast.Heading:
+			title := string(n.Text(src))
+			words += len(strings.Fields(title))
+
+			id, _ := n.AttributeString("id")
+			idBytes, _ := id.([]byte)
+			toc = append(toc, TOCEntry{
+				Level: n.Level,
+				Text:  title,
+				ID:    string(idBytes),
+			})
+			return ast.WalkSkipChildren, nil
+		case *ast.Paragraph, *ast.TextBlock:
+			para := strings.Fields(string(n.Text(src)))
+			words += len(para)
+			excerpt = append(excerpt, para...)
+			return ast.WalkSkipChildren, nil
+		case *extast.TableCell:
+			words += len(strings.Fields(string(n.Text(src))))
+			return ast.WalkSkipChildren, nil
+		case *ast.CodeBlock, *ast.FencedCodeBlock:
+			lines := n.Lines()
+			for i := 0; i < lines.Len(); i++ {
+				line := lines.At(i)
+				words += len(strings.Fields(string(line.Value(src))))
+			}
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+
+	article.WordCount = words
+	article.ReadingTimeMinutes = (words + wordsPerMinute - 1) / wordsPerMinute
+	article.TOC = toc
+
+	if article.Description != "" {
+		article.Excerpt = article.Description
+	} else {
+		article.Excerpt = truncateWords(excerpt, maxExcerptLength)
+	}
+}
+
+// truncateWords joins the words and cuts the text at a word boundary.
+func truncateWords(words []string, maxLen int) string {
+	var b strings.Builder
+	for _, word := range words {
+		n := utf8.RuneCountInString(word)
+		if b.Len() > 0 {
+			n++
+		}
+		if utf8.RuneCountInString(b.String())+n > maxLen {
+			if b.Len() == 0 {
+				// The first word is too long.
+				return string([]rune(word)[:maxLen-1]) + "…"
+			}
+			return b.String() + "…"
+		}
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(word)
+	}
+	return b.String()
+}
+
+// Reanalyze recomputes the stats of all articles, e.g. after the
+// analysis has changed.
+func (s *ArticleService) Reanalyze(ctx context.Context) error {
+	var articles []*Article
+	if err := s.app.IDB(ctx).NewSelect().
+		Model(&articles).
+		Column("id", "description", "body").
+		Scan(ctx); err != nil {
+		return err
+	}
+	for _, article := range articles {
+		analyzeArticle(article)
+
+		if _, err := s.app.IDB(ctx).NewUpdate().
+			Model(article).
+			Column("word_count", "reading_time_minutes", "toc", "excerpt").
+			WherePK().
+			Exec(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
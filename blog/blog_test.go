@@ -1,8 +1,10 @@
 package blog_test
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -10,6 +12,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun-realworld-app/blog"
+	"github.com/uptrace/bun-realworld-app/httputil/openapi"
 	"github.com/uptrace/bun-realworld-app/org"
 	"github.com/uptrace/bun-realworld-app/testbed"
 	"github.com/uptrace/bun/dbfixture"
@@ -17,6 +22,7 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	. "github.com/onsi/gomega/gstruct"
+	"github.com/onsi/gomega/types"
 )
 
 func TestGinkgo(t *testing.T) {
@@ -58,16 +64,23 @@ var _ = Describe("createArticle", func() {
 		app.TruncateDB(ctx)
 
 		helloArticleKeys = Keys{
-			"title":          Equal("Hello world"),
-			"slug":           HavePrefix("hello-world-"),
-			"description":    Equal("Hello world article description!"),
-			"body":           Equal("Hello world article body."),
-			"author":         Equal(map[string]interface{}{"following": false, "username": "CurrentUser", "bio": "", "image": ""}),
-			"tagList":        ConsistOf([]interface{}{"greeting", "welcome", "salut"}),
-			"favoritesCount": Equal(float64(0)),
-			"favorited":      Equal(false),
-			"createdAt":      Equal(app.Clock().Now().Format(time.RFC3339Nano)),
-			"updatedAt":      Equal(app.Clock().Now().Format(time.RFC3339Nano)),
+			"title":              Equal("Hello world"),
+			"slug":               Equal("hello-world"),
+			"description":        Equal("Hello world article description!"),
+			"body":               Equal("Hello world article body."),
+			"bodyHtml":           Equal("<p>Hello world article body.</p>\n"),
+			"wordCount":          Equal(float64(4)),
+			"readingTimeMinutes": Equal(float64(1)),
+			"toc":                BeEmpty(),
+			"excerpt":            Equal("Hello world article description!"),
+			"author":             profileKeys("CurrentUser", false),
+			"tagList":            ConsistOf([]interface{}{"greeting", "welcome", "salut"}),
+			"favoritesCount":     Equal(float64(0)),
+			"commentsCount":      Equal(float64(0)),
+			"favorited":          Equal(false),
+			"bookmarked":         Equal(false),
+			"createdAt":          Equal(app.Clock().Now().Format(time.RFC3339Nano)),
+			"updatedAt":          Equal(app.Clock().Now().Format(time.RFC3339Nano)),
 		}
 
 		favoritedArticleKeys = testbed.ExtendKeys(helloArticleKeys, Keys{
@@ -76,16 +89,23 @@ var _ = Describe("createArticle", func() {
 		})
 
 		fooArticleKeys = Keys{
-			"title":          Equal("Foo bar"),
-			"slug":           HavePrefix("foo-bar-"),
-			"description":    Equal("Foo bar article description!"),
-			"body":           Equal("Foo bar article body."),
-			"author":         Equal(map[string]interface{}{"following": false, "username": "CurrentUser", "bio": "", "image": ""}),
-			"tagList":        ConsistOf([]interface{}{"foobar", "variable"}),
-			"favoritesCount": Equal(float64(0)),
-			"favorited":      Equal(false),
-			"createdAt":      Equal(app.Clock().Now().Format(time.RFC3339Nano)),
-			"updatedAt":      Equal(app.Clock().Now().Format(time.RFC3339Nano)),
+			"title":              Equal("Foo bar"),
+			"slug":               Equal("foo-bar"),
+			"description":        Equal("Foo bar article description!"),
+			"body":               Equal("Foo bar article body."),
+			"bodyHtml":           Equal("<p>Foo bar article body.</p>\n"),
+			"wordCount":          Equal(float64(4)),
+			"readingTimeMinutes": Equal(float64(1)),
+			"toc":                BeEmpty(),
+			"excerpt":            Equal("Foo bar article description!"),
+			"author":             profileKeys("CurrentUser", false),
+			"tagList":            ConsistOf([]interface{}{"foobar", "variable"}),
+			"favoritesCount":     Equal(float64(0)),
+			"commentsCount":      Equal(float64(0)),
+			"favorited":          Equal(false),
+			"bookmarked":         Equal(false),
+			"createdAt":          Equal(app.Clock().Now().Format(time.RFC3339Nano)),
+			"updatedAt":          Equal(app.Clock().Now().Format(time.RFC3339Nano)),
 		}
 
 		app.DB().RegisterModel((*org.User)(nil))
@@ -128,12 +148,7 @@ var _ = Describe("createArticle", func() {
 
 			Expect(articles).To(HaveLen(1))
 			followedAuthorKeys := testbed.ExtendKeys(fooArticleKeys, Keys{
-				"author": Equal(map[string]interface{}{
-					"following": true,
-					"username":  "FollowedUser",
-					"bio":       "",
-					"image":     "",
-				}),
+				"author": profileKeys("FollowedUser", true),
 			})
 			Expect(articles[0].(map[string]interface{})).To(MatchAllKeys(followedAuthorKeys))
 		})
@@ -152,6 +167,103 @@ var _ = Describe("createArticle", func() {
 		})
 	})
 
+	Describe("conditional requests", func() {
+		get := func(client testbed.Client, url string, header http.Header) *httptest.ResponseRecorder {
+			req := httptest.NewRequest("GET", url, nil)
+			for k, v := range header {
+				req.Header[k] = v
+			}
+			return client.Serve(req)
+		}
+
+		It("returns 304 when the article ETag matches", func() {
+			url := fmt.Sprintf("/api/articles/%s", slug)
+			resp := get(app.Client(), url, nil)
+			Expect(resp.Code).To(Equal(http.StatusOK))
+			Expect(resp.Header().Get("Cache-Control")).To(Equal("public, max-age=0"))
+			Expect(resp.Header().Get("Last-Modified")).NotTo(BeEmpty())
+
+			etag := resp.Header().Get("ETag")
+			Expect(etag).NotTo(BeEmpty())
+
+			resp = get(app.Client(), url, http.Header{"If-None-Match": {etag}})
+			Expect(resp.Code).To(Equal(http.StatusNotModified))
+			Expect(resp.Body.Len()).To(Equal(0))
+
+			resp = get(app.Client(), url, http.Header{"If-None-Match": {`"stale"`}})
+			Expect(resp.Code).To(Equal(http.StatusOK))
+		})
+
+		It("returns 304 when the article was not modified since", func() {
+			url := fmt.Sprintf("/api/articles/%s", slug)
+			resp := get(app.Client(), url, nil)
+			header := http.Header{"If-Modified-Since": {resp.Header().Get("Last-Modified")}}
+
+			resp = get(app.Client(), url, header)
+			Expect(resp.Code).To(Equal(http.StatusNotModified))
+
+			_ = parseJSON(userClient.Post(url+"/favorite", ""), http.StatusOK)
+
+			resp = get(app.Client(), url, header)
+			Expect(resp.Code).To(Equal(http.StatusOK))
+			Expect(resp.Body.String()).To(ContainSubstring(`"favoritesCount":1`))
+		})
+
+		It("returns 200 when the author changed", func() {
+			url := fmt.Sprintf("/api/articles/%s", slug)
+			resp := get(app.Client(), url, nil)
+			header := http.Header{"If-Modified-Since": {resp.Header().Get("Last-Modified")}}
+
+			json := `{"user": {"bio": "New bio"}}`
+			_ = parseJSON(userClient.PutJSON("/api/user/", json), http.StatusOK)
+
+			resp = get(app.Client(), url, header)
+			Expect(resp.Code).To(Equal(http.StatusOK))
+			Expect(resp.Body.String()).To(ContainSubstring(`"bio":"New bio"`))
+		})
+
+		It("returns 200 when an article left the list", func() {
+			resp := get(app.Client(), "/api/articles", nil)
+			Expect(resp.Body.String()).To(ContainSubstring(slug))
+			header := http.Header{"If-Modified-Since": {resp.Header().Get("Last-Modified")}}
+
+			resp = get(app.Client(), "/api/articles", header)
+			Expect(resp.Code).To(Equal(http.StatusNotModified))
+
+			resp = userClient.Delete(fmt.Sprintf("/api/articles/%s", slug))
+			Expect(resp.Code).To(Equal(http.StatusOK))
+
+			resp = get(app.Client(), "/api/articles", header)
+			Expect(resp.Code).To(Equal(http.StatusOK))
+			Expect(resp.Body.String()).NotTo(ContainSubstring(slug))
+		})
+
+		It("changes the ETag when the article is favorited", func() {
+			url := "/api/articles?author=CurrentUser"
+			resp := get(userClient, url, nil)
+			Expect(resp.Code).To(Equal(http.StatusOK))
+			Expect(resp.Header().Get("Cache-Control")).To(Equal("private, no-cache"))
+			Expect(resp.Header().Get("Last-Modified")).To(BeEmpty())
+			etag := resp.Header().Get("ETag")
+
+			_ = parseJSON(userClient.Post(fmt.Sprintf("/api/articles/%s/favorite", slug), ""), http.StatusOK)
+
+			resp = get(userClient, url, http.Header{"If-None-Match": {etag}})
+			Expect(resp.Code).To(Equal(http.StatusOK))
+			Expect(resp.Header().Get("ETag")).NotTo(Equal(etag))
+		})
+
+		It("returns 304 for tags", func() {
+			resp := get(app.Client(), "/api/tags/", nil)
+			Expect(resp.Code).To(Equal(http.StatusOK))
+
+			resp = get(app.Client(), "/api/tags/", http.Header{
+				"If-None-Match": {"W/" + resp.Header().Get("ETag")},
+			})
+			Expect(resp.Code).To(Equal(http.StatusNotModified))
+		})
+	})
+
 	Describe("listArticles", func() {
 		BeforeEach(func() {
 			url := fmt.Sprintf("/api/articles/%s?author=CurrentUser", slug)
@@ -227,12 +339,101 @@ var _ = Describe("createArticle", func() {
 
 		It("returns article", func() {
 			updatedArticleKeys := testbed.ExtendKeys(fooArticleKeys, Keys{
-				"slug":      HavePrefix("hello-world-"),
 				"tagList":   Equal([]interface{}{}),
 				"updatedAt": Equal(app.Clock().Now().Format(time.RFC3339Nano)),
 			})
 			Expect(data["article"]).To(MatchAllKeys(updatedArticleKeys))
 		})
+
+		It("redirects the old slug", func() {
+			resp := userClient.Get("/api/articles/hello-world")
+			data := parseJSON(resp, http.StatusMovedPermanently)
+			Expect(resp.Header().Get("Location")).To(Equal("/api/articles/foo-bar"))
+			Expect(data["redirect"]).To(Equal(map[string]interface{}{
+				"slug":     "foo-bar",
+				"location": "/api/articles/foo-bar",
+			}))
+		})
+
+		It("reuses the old slug when the title is restored", func() {
+			json := `{"article": {"title": "Hello world", "description": "Hello world article description!", "body": "Hello world article body."}}`
+			data := parseJSON(userClient.PutJSON("/api/articles/foo-bar", json), http.StatusOK)
+			Expect(data["article"]).To(HaveKeyWithValue("slug", "hello-world"))
+
+			resp := userClient.Get("/api/articles/foo-bar")
+			_ = parseJSON(resp, http.StatusMovedPermanently)
+			Expect(resp.Header().Get("Location")).To(Equal("/api/articles/hello-world"))
+		})
+	})
+
+	Describe("createArticle with markdown", func() {
+		It("renders sanitized HTML", func() {
+			body := "# Title\n\n| a | b |\n|---|---|\n| 1 | 2 |\n\n```go\nfmt.Println()\n```\n\n" +
+				"[link](javascript:alert(1)) <sup>2</sup>\n\n<script>alert(1)</script>"
+			in, err := json.Marshal(map[string]interface{}{
+				"article": map[string]interface{}{
+					"title":       "Markdown",
+					"description": "Markdown article",
+					"body":        body,
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			data := parseJSON(userClient.PostJSON("/api/articles", string(in)), http.StatusOK)
+			html := data["article"].(map[string]interface{})["bodyHtml"].(string)
+			Expect(html).To(ContainSubstring(`<h1 id="title">Title</h1>`))
+			Expect(html).To(ContainSubstring("<td>1</td>"))
+			Expect(html).To(ContainSubstring(`<code class="language-go">fmt.Println()`))
+			Expect(html).To(ContainSubstring("<sup>2</sup>"))
+			Expect(html).NotTo(ContainSubstring("<script>"))
+			Expect(html).NotTo(ContainSubstring("javascript:"))
+		})
+	})
+
+	Describe("createArticle without description", func() {
+		It("computes the stats", func() {
+			json := `{"article": {"title": "Guide", "body": "# Intro\n\nFirst words here.\n\n## Setup steps\n\n- install it\n- run it\n"}}`
+			data := parseJSON(userClient.PostJSON("/api/articles", json), http.StatusOK)
+
+			article := data["article"].(map[string]interface{})
+			Expect(article["wordCount"]).To(Equal(float64(10)))
+			Expect(article["readingTimeMinutes"]).To(Equal(float64(1)))
+			Expect(article["excerpt"]).To(Equal("First words here. install it run it"))
+			Expect(article["toc"]).To(Equal([]interface{}{
+				map[string]interface{}{"level": float64(1), "text": "Intro", "id": "intro"},
+				map[string]interface{}{"level": float64(2), "text": "Setup steps", "id": "setup-steps"},
+			}))
+		})
+	})
+
+	Describe("createArticle with the same title", func() {
+		It("appends a number to the slug", func() {
+			json := `{"article": {"title": "Hello world", "description": "Hello world article description!", "body": "Hello world article body."}}`
+
+			data := parseJSON(userClient.PostJSON("/api/articles", json), http.StatusOK)
+			Expect(data["article"]).To(HaveKeyWithValue("slug", "hello-world-2"))
+
+			data = parseJSON(userClient.PostJSON("/api/articles", json), http.StatusOK)
+			Expect(data["article"]).To(HaveKeyWithValue("slug", "hello-world-3"))
+		})
+	})
+
+	Describe("updateArticle with invalid tags", func() {
+		BeforeEach(func() {
+			json := `{"article": {"title": "Foo bar", "description": "Foo bar article description!", "body": "Foo bar article body.", "tagList": ["foobar", "   "]}}`
+
+			url := fmt.Sprintf("/api/articles/%s", slug)
+			resp := userClient.PutJSON(url, json)
+			data = parseJSON(resp, http.StatusUnprocessableEntity)
+			Expect(data["code"]).To(Equal("invalid_tag"))
+
+			resp = userClient.Get(url)
+			data = parseJSON(resp, http.StatusOK)
+		})
+
+		It("rolls back the update", func() {
+			Expect(data["article"]).To(MatchAllKeys(helloArticleKeys))
+		})
 	})
 
 	Describe("deleteArticle", func() {
@@ -257,7 +458,8 @@ var _ = Describe("createArticle", func() {
 			commentKeys = Keys{
 				"id":        Not(BeZero()),
 				"body":      Equal("First comment."),
-				"author":    Equal(map[string]interface{}{"following": false, "username": "FollowedUser", "bio": "", "image": ""}),
+				"bodyHtml":  Equal("<p>First comment.</p>\n"),
+				"author":    profileKeys("FollowedUser", false),
 				"createdAt": Equal(app.Clock().Now().Format(time.RFC3339Nano)),
 				"updatedAt": Equal(app.Clock().Now().Format(time.RFC3339Nano)),
 			}
@@ -276,6 +478,39 @@ var _ = Describe("createArticle", func() {
 			Expect(data["comment"]).To(MatchAllKeys(commentKeys))
 		})
 
+		It("maintains counters", func() {
+			data = parseJSON(userClient.Get(fmt.Sprintf("/api/articles/%s", slug)), http.StatusOK)
+			article := data["article"].(map[string]interface{})
+			Expect(article["commentsCount"]).To(Equal(float64(1)))
+			Expect(article["author"]).To(HaveKeyWithValue("followingCount", float64(1)))
+
+			url := fmt.Sprintf("/api/articles/%s/comments/%d", slug, commentID)
+			resp := app.Client().WithToken(followedUser.ID).Delete(url)
+			Expect(resp.Code).To(Equal(http.StatusOK))
+
+			data = parseJSON(userClient.Get(fmt.Sprintf("/api/articles/%s", slug)), http.StatusOK)
+			article = data["article"].(map[string]interface{})
+			Expect(article["commentsCount"]).To(Equal(float64(0)))
+		})
+
+		It("recounts corrupted counters", func() {
+			_, err := app.DB().NewUpdate().
+				Model((*blog.Article)(nil)).
+				Set("comments_count = 42").
+				Set("favorites_count = 7").
+				Where("slug = ?", slug).
+				Exec(ctx)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = blog.NewArticleService(app.App).Recount(ctx)
+			Expect(err).NotTo(HaveOccurred())
+
+			data = parseJSON(userClient.Get(fmt.Sprintf("/api/articles/%s", slug)), http.StatusOK)
+			article := data["article"].(map[string]interface{})
+			Expect(article["commentsCount"]).To(Equal(float64(1)))
+			Expect(article["favoritesCount"]).To(Equal(float64(0)))
+		})
+
 		Describe("showComment", func() {
 			BeforeEach(func() {
 				url := fmt.Sprintf("/api/articles/%s/comments/%d", slug, commentID)
@@ -297,7 +532,7 @@ var _ = Describe("createArticle", func() {
 
 			It("returns article comments", func() {
 				followedCommentKeys := testbed.ExtendKeys(commentKeys, Keys{
-					"author": Equal(map[string]interface{}{"following": true, "username": "FollowedUser", "bio": "", "image": ""}),
+					"author": profileKeys("FollowedUser", true),
 				})
 				Expect(data["comment"]).To(MatchAllKeys(followedCommentKeys))
 			})
@@ -312,7 +547,7 @@ var _ = Describe("createArticle", func() {
 
 			It("returns article comments", func() {
 				followedCommentKeys := testbed.ExtendKeys(commentKeys, Keys{
-					"author": Equal(map[string]interface{}{"following": true, "username": "FollowedUser", "bio": "", "image": ""}),
+					"author": profileKeys("FollowedUser", true),
 				})
 				Expect(data["comments"].([]interface{})[0]).To(MatchAllKeys(followedCommentKeys))
 			})
@@ -332,6 +567,99 @@ var _ = Describe("createArticle", func() {
 		})
 	})
 
+	Describe("services", func() {
+		var otherUser *org.User
+
+		BeforeEach(func() {
+			otherUser = &org.User{
+				Username:     "OtherUser",
+				Email:        "other@bar.com",
+				PasswordHash: "h3",
+			}
+			_, err := app.DB().NewInsert().Model(otherUser).Exec(ctx)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("allows only the author to update and delete the article", func() {
+			service := blog.NewArticleService(app.App)
+
+			_, err := service.Update(ctx, otherUser, slug, &blog.Article{Title: "Hijacked"})
+			Expect(err).To(Equal(blog.ErrForbidden))
+
+			err = service.Delete(ctx, otherUser, slug)
+			Expect(err).To(Equal(blog.ErrForbidden))
+
+			err = service.Delete(ctx, user, "unknown")
+			Expect(err).To(Equal(blog.ErrArticleNotFound))
+
+			err = service.Delete(ctx, user, slug)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = service.Get(ctx, &blog.ArticleFilter{Slug: slug})
+			Expect(err).To(Equal(blog.ErrArticleNotFound))
+		})
+
+		It("favorites the article once", func() {
+			service := blog.NewArticleService(app.App)
+
+			article, err := service.Favorite(ctx, otherUser, &blog.ArticleFilter{Slug: slug})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(article.FavoritesCount).To(Equal(1))
+
+			article, err = service.Favorite(ctx, otherUser, &blog.ArticleFilter{Slug: slug})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(article.Favorited).To(BeTrue())
+			Expect(article.FavoritesCount).To(Equal(1))
+		})
+
+		It("deletes only the requested comment", func() {
+			service := blog.NewCommentService(app.App)
+
+			first := &blog.Comment{Body: "First comment."}
+			Expect(service.Create(ctx, user, slug, first)).NotTo(HaveOccurred())
+			second := &blog.Comment{Body: "Second comment."}
+			Expect(service.Create(ctx, user, slug, second)).NotTo(HaveOccurred())
+
+			err := service.Delete(ctx, otherUser, slug, first.ID)
+			Expect(err).To(Equal(blog.ErrForbidden))
+
+			err = service.Delete(ctx, user, slug, first.ID)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = service.Get(ctx, nil, slug, first.ID)
+			Expect(err).To(Equal(blog.ErrCommentNotFound))
+
+			comments, err := service.List(ctx, nil, slug)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(comments).To(HaveLen(1))
+			Expect(comments[0].ID).To(Equal(second.ID))
+		})
+	})
+
+	Describe("personal token", func() {
+		createToken := func(scope string) testbed.Client {
+			json := fmt.Sprintf(`{"token": {"name": "ci", "scopes": [%q]}}`, scope)
+			resp := userClient.PostJSON("/api/user/tokens", json)
+			data := parseJSON(resp, http.StatusOK)
+			token := data["token"].(map[string]interface{})["token"].(string)
+			return app.Client().WithAuthToken(token)
+		}
+
+		json := `{"article": {"title": "Foo bar", "description": "Foo bar article description!", "body": "Foo bar article body.", "tagList": ["foobar", "variable"]}}`
+
+		It("creates article with articles:write scope", func() {
+			resp := createToken("articles:write").PostJSON("/api/articles", json)
+			data := parseJSON(resp, http.StatusOK)
+			Expect(data["article"]).To(MatchAllKeys(fooArticleKeys))
+		})
+
+		It("requires articles:write scope", func() {
+			resp := createToken("comments:write").PostJSON("/api/articles", json)
+			data := parseJSON(resp, http.StatusForbidden)
+			Expect(data["code"]).To(Equal("insufficient_scope"))
+		})
+	})
+
 	Describe("listTags", func() {
 		BeforeEach(func() {
 			resp := app.Client().Get("/api/tags/")
@@ -345,9 +673,749 @@ var _ = Describe("createArticle", func() {
 				"welcome",
 			}))
 		})
+
+		It("invalidates cached tags when articles change", func() {
+			json := `{"article": {"title": "Foo bar", "description": "Foo bar article description!", "body": "Foo bar article body.", "tagList": ["foobar"]}}`
+			_ = parseJSON(userClient.PostJSON("/api/articles", json), http.StatusOK)
+
+			data = parseJSON(app.Client().Get("/api/tags/"), http.StatusOK)
+			Expect(data["tags"]).To(ContainElement("foobar"))
+
+			resp := userClient.Delete(fmt.Sprintf("/api/articles/%s", slug))
+			Expect(resp.Code).To(Equal(http.StatusOK))
+
+			data = parseJSON(app.Client().Get("/api/tags/"), http.StatusOK)
+			Expect(data["tags"]).To(ConsistOf([]string{"foobar"}))
+		})
+	})
+
+	Describe("tags", func() {
+		var adminClient testbed.Client
+
+		BeforeEach(func() {
+			admin := &org.User{
+				Username:     "admin",
+				Email:        "admin@bar.com",
+				PasswordHash: "#",
+				IsAdmin:      true,
+			}
+			_, err := app.DB().NewInsert().Model(admin).Exec(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			adminClient = app.Client().WithToken(admin.ID)
+		})
+
+		It("normalizes tags", func() {
+			json := `{"article": {"title": "Foo bar", "description": "Foo bar article description!", "body": "Foo bar article body.", "tagList": ["Go", "go ", "Web  Dev"]}}`
+			data := parseJSON(userClient.PostJSON("/api/articles", json), http.StatusOK)
+			Expect(data["article"]).To(HaveKeyWithValue("tagList", ConsistOf("go", "web-dev")))
+
+			data = parseJSON(app.Client().Get("/api/articles?tag=Web+Dev"), http.StatusOK)
+			Expect(data["articlesCount"]).To(Equal(float64(1)))
+		})
+
+		It("shows the tag with related tags", func() {
+			json := `{"article": {"title": "Foo bar", "description": "Foo bar article description!", "body": "Foo bar article body.", "tagList": ["greeting", "foobar"]}}`
+			_ = parseJSON(userClient.PostJSON("/api/articles", json), http.StatusOK)
+
+			data := parseJSON(app.Client().Get("/api/tags/greeting"), http.StatusOK)
+			Expect(data["tag"]).To(MatchAllKeys(Keys{
+				"slug":          Equal("greeting"),
+				"description":   Equal(""),
+				"articlesCount": Equal(float64(2)),
+				"aliases":       BeEmpty(),
+				"relatedTags": Equal([]interface{}{
+					map[string]interface{}{"tag": "foobar", "articlesCount": float64(1)},
+					map[string]interface{}{"tag": "salut", "articlesCount": float64(1)},
+					map[string]interface{}{"tag": "welcome", "articlesCount": float64(1)},
+				}),
+			}))
+
+			_ = parseJSON(app.Client().Get("/api/tags/unknown"), http.StatusNotFound)
+		})
+
+		It("lets only admins describe tags", func() {
+			json := `{"tag": {"description": "Say hi."}}`
+
+			resp := userClient.PutJSON("/api/admin/tags/greeting", json)
+			_ = parseJSON(resp, http.StatusForbidden)
+
+			resp = adminClient.PutJSON("/api/admin/tags/greeting", json)
+			data := parseJSON(resp, http.StatusOK)
+			Expect(data["tag"]).To(HaveKeyWithValue("description", "Say hi."))
+		})
+
+		It("requires a session to manage tags", func() {
+			json := `{"token": {"name": "ci", "scopes": ["articles:write"]}}`
+			data := parseJSON(adminClient.PostJSON("/api/user/tokens", json), http.StatusOK)
+			token := data["token"].(map[string]interface{})["token"].(string)
+
+			resp := app.Client().WithAuthToken(token).
+				PutJSON("/api/admin/tags/greeting", `{"tag": {"description": "Say hi."}}`)
+			data = parseJSON(resp, http.StatusForbidden)
+			Expect(data["code"]).To(Equal("session_required"))
+		})
+
+		It("resolves aliases", func() {
+			resp := adminClient.PostJSON("/api/admin/tags/greeting/aliases", `{"alias": "Hello"}`)
+			data := parseJSON(resp, http.StatusOK)
+			Expect(data["tag"]).To(HaveKeyWithValue("aliases", []interface{}{"hello"}))
+
+			resp = adminClient.PostJSON("/api/admin/tags/greeting/aliases", `{"alias": "salut"}`)
+			data = parseJSON(resp, http.StatusConflict)
+			Expect(data["code"]).To(Equal("tag_exists"))
+
+			json := `{"article": {"title": "Foo bar", "description": "Foo bar article description!", "body": "Foo bar article body.", "tagList": ["hello"]}}`
+			data = parseJSON(userClient.PostJSON("/api/articles", json), http.StatusOK)
+			Expect(data["article"]).To(HaveKeyWithValue("tagList", []interface{}{"greeting"}))
+
+			data = parseJSON(app.Client().Get("/api/tags/hello"), http.StatusOK)
+			Expect(data["tag"]).To(HaveKeyWithValue("slug", "greeting"))
+
+			data = parseJSON(app.Client().Get("/api/articles?tag=hello"), http.StatusOK)
+			Expect(data["articlesCount"]).To(Equal(float64(2)))
+
+			resp = adminClient.Delete("/api/admin/tags/greeting/aliases/hello")
+			data = parseJSON(resp, http.StatusOK)
+			Expect(data["tag"]).To(HaveKeyWithValue("aliases", BeEmpty()))
+		})
+
+		It("merges tags", func() {
+			json := `{"article": {"title": "Foo bar", "description": "Foo bar article description!", "body": "Foo bar article body.", "tagList": ["welcome", "hi"]}}`
+			_ = parseJSON(userClient.PostJSON("/api/articles", json), http.StatusOK)
+
+			resp := adminClient.PostJSON("/api/admin/tags/greeting/merge", `{"tags": ["welcome", "hi"]}`)
+			data := parseJSON(resp, http.StatusOK)
+			Expect(data["tag"]).To(HaveKeyWithValue("articlesCount", float64(2)))
+			Expect(data["tag"]).To(HaveKeyWithValue("aliases", []interface{}{"hi", "welcome"}))
+
+			data = parseJSON(app.Client().Get("/api/tags/"), http.StatusOK)
+			Expect(data["tags"]).To(ConsistOf("greeting", "salut"))
+
+			data = parseJSON(app.Client().Get("/api/articles/"+slug), http.StatusOK)
+			Expect(data["article"]).To(HaveKeyWithValue("tagList", ConsistOf("greeting", "salut")))
+		})
+
+		It("returns trending tags for the window", func() {
+			json := `{"article": {"title": "Foo bar", "description": "Foo bar article description!", "body": "Foo bar article body.", "tagList": ["welcome", "foobar"]}}`
+			_ = parseJSON(userClient.PostJSON("/api/articles", json), http.StatusOK)
+
+			service := blog.NewTagService(app.App)
+			Expect(service.ComputeTrending(ctx)).NotTo(HaveOccurred())
+
+			data := parseJSON(app.Client().Get("/api/tags/?window=7d&limit=1"), http.StatusOK)
+			Expect(data["tags"]).To(Equal([]interface{}{"welcome"}))
+			Expect(data["trending"]).To(ConsistOf(MatchAllKeys(Keys{
+				"tag":           Equal("welcome"),
+				"score":         BeNumerically("~", 2),
+				"articlesCount": Equal(float64(2)),
+			})))
+
+			app.AdvanceClock(8 * 24 * time.Hour)
+			Expect(service.ComputeTrending(ctx)).NotTo(HaveOccurred())
+
+			data = parseJSON(app.Client().Get("/api/tags/?window=7d"), http.StatusOK)
+			Expect(data["tags"]).To(BeEmpty())
+
+			data = parseJSON(app.Client().Get("/api/tags/?window=30d"), http.StatusOK)
+			Expect(data["tags"]).To(HaveLen(4))
+
+			data = parseJSON(app.Client().Get("/api/tags/?window=2w"), http.StatusBadRequest)
+			Expect(data["code"]).To(Equal("invalid_window"))
+		})
+	})
+
+	Describe("feeds", func() {
+		type atomFeed struct {
+			Title   string `xml:"title"`
+			Updated string `xml:"updated"`
+			Entries []struct {
+				Title   string `xml:"title"`
+				Updated string `xml:"updated"`
+				Link    struct {
+					Href string `xml:"href,attr"`
+				} `xml:"link"`
+				Author  string `xml:"author>name"`
+				Content string `xml:"content"`
+			} `xml:"entry"`
+		}
+
+		parseAtom := func(resp *httptest.ResponseRecorder) *atomFeed {
+			Expect(resp.Code).To(Equal(http.StatusOK))
+			Expect(resp.Header().Get("Content-Type")).To(HavePrefix("application/atom+xml"))
+
+			feed := new(atomFeed)
+			Expect(xml.Unmarshal(resp.Body.Bytes(), feed)).To(Succeed())
+			return feed
+		}
+
+		BeforeEach(func() {
+			app.AdvanceClock(time.Minute)
+			json := `{"article": {"title": "Foo bar", "description": "Foo bar article description!", "body": "Foo *bar*", "tagList": ["foobar"]}}`
+			_ = parseJSON(userClient.PostJSON("/api/articles", json), http.StatusOK)
+		})
+
+		It("returns the latest articles", func() {
+			feed := parseAtom(app.Client().Get("/feeds/articles.atom"))
+			Expect(feed.Title).To(Equal("Conduit"))
+			Expect(feed.Updated).To(Equal(app.Clock().Now().UTC().Format(time.RFC3339)))
+			Expect(feed.Entries).To(HaveLen(2))
+
+			entry := feed.Entries[0]
+			Expect(entry.Title).To(Equal("Foo bar"))
+			Expect(entry.Link.Href).To(Equal("https://conduit.test/article/foo-bar"))
+			Expect(entry.Author).To(Equal("CurrentUser"))
+			Expect(entry.Content).To(Equal("<p>Foo <em>bar</em></p>\n"))
+
+			feed = parseAtom(app.Client().Get("/feeds/articles.atom?limit=1"))
+			Expect(feed.Entries).To(HaveLen(1))
+
+			data := parseJSON(app.Client().Get("/feeds/articles.atom?limit=1000"), http.StatusBadRequest)
+			Expect(data["code"]).To(Equal("invalid_limit"))
+		})
+
+		It("filters by author and tag", func() {
+			feed := parseAtom(app.Client().Get("/feeds/@CurrentUser.atom"))
+			Expect(feed.Title).To(Equal("Articles by CurrentUser"))
+			Expect(feed.Entries).To(HaveLen(2))
+
+			feed = parseAtom(app.Client().Get("/feeds/tags/greeting.atom"))
+			Expect(feed.Entries).To(HaveLen(1))
+			Expect(feed.Entries[0].Title).To(Equal("Hello world"))
+
+			_ = parseJSON(app.Client().Get("/feeds/@nobody.atom"), http.StatusNotFound)
+			_ = parseJSON(app.Client().Get("/feeds/articles.json"), http.StatusNotFound)
+		})
+
+		It("returns RSS", func() {
+			resp := app.Client().Get("/feeds/tags/foobar.rss")
+			Expect(resp.Code).To(Equal(http.StatusOK))
+			Expect(resp.Header().Get("Content-Type")).To(HavePrefix("application/rss+xml"))
+
+			var rss struct {
+				Version string `xml:"version,attr"`
+				Items   []struct {
+					Title string `xml:"title"`
+					Link  string `xml:"link"`
+				} `xml:"channel>item"`
+			}
+			Expect(xml.Unmarshal(resp.Body.Bytes(), &rss)).To(Succeed())
+			Expect(rss.Version).To(Equal("2.0"))
+			Expect(rss.Items).To(HaveLen(1))
+			Expect(rss.Items[0].Link).To(Equal("https://conduit.test/article/foo-bar"))
+		})
+
+		It("supports conditional requests", func() {
+			resp := app.Client().Get("/feeds/articles.atom")
+			Expect(resp.Code).To(Equal(http.StatusOK))
+			etag := resp.Header().Get("ETag")
+			lastModified := resp.Header().Get("Last-Modified")
+			Expect(lastModified).NotTo(BeEmpty())
+
+			req := httptest.NewRequest("GET", "/feeds/articles.atom", nil)
+			req.Header.Set("If-None-Match", etag)
+			Expect(app.Client().Serve(req).Code).To(Equal(http.StatusNotModified))
+
+			req = httptest.NewRequest("GET", "/feeds/articles.atom", nil)
+			req.Header.Set("If-Modified-Since", lastModified)
+			Expect(app.Client().Serve(req).Code).To(Equal(http.StatusNotModified))
+
+			app.AdvanceClock(time.Hour)
+			json := `{"article": {"body": "Updated body."}}`
+			_ = parseJSON(userClient.PutJSON("/api/articles/foo-bar", json), http.StatusOK)
+
+			req = httptest.NewRequest("GET", "/feeds/articles.atom", nil)
+			req.Header.Set("If-None-Match", etag)
+			Expect(app.Client().Serve(req).Code).To(Equal(http.StatusOK))
+
+			req = httptest.NewRequest("GET", "/feeds/articles.atom", nil)
+			req.Header.Set("If-Modified-Since", lastModified)
+			Expect(app.Client().Serve(req).Code).To(Equal(http.StatusOK))
+		})
+	})
+
+	Describe("sitemap", func() {
+		type urlset struct {
+			URLs []struct {
+				Loc     string `xml:"loc"`
+				LastMod string `xml:"lastmod"`
+			} `xml:"url"`
+		}
+
+		parseXML := func(resp *httptest.ResponseRecorder, v interface{}) {
+			Expect(resp.Code).To(Equal(http.StatusOK))
+			Expect(resp.Header().Get("Content-Type")).To(HavePrefix("application/xml"))
+			Expect(xml.Unmarshal(resp.Body.Bytes(), v)).To(Succeed())
+		}
+
+		BeforeEach(func() {
+			app.AdvanceClock(time.Minute)
+			json := `{"article": {"title": "Foo bar", "description": "Foo bar article description!", "body": "Foo bar article body."}}`
+			_ = parseJSON(userClient.PostJSON("/api/articles", json), http.StatusOK)
+		})
+
+		It("lists articles with lastmod", func() {
+			var sitemap urlset
+			parseXML(app.Client().Get("/sitemap.xml"), &sitemap)
+			Expect(sitemap.URLs).To(HaveLen(3))
+			Expect(sitemap.URLs[0].Loc).To(Equal("https://conduit.test/"))
+			Expect(sitemap.URLs[1].Loc).To(Equal("https://conduit.test/article/hello-world"))
+			Expect(sitemap.URLs[2].Loc).To(Equal("https://conduit.test/article/foo-bar"))
+			Expect(sitemap.URLs[2].LastMod).To(Equal(app.Clock().Now().UTC().Format(time.RFC3339)))
+		})
+
+		It("shards large sitemaps", func() {
+			app.Config().Sitemap.ShardSize = 1
+
+			var index struct {
+				Sitemaps []struct {
+					Loc string `xml:"loc"`
+				} `xml:"sitemap"`
+			}
+			parseXML(app.Client().Get("/sitemap.xml"), &index)
+			Expect(index.Sitemaps).To(HaveLen(2))
+			Expect(index.Sitemaps[1].Loc).To(Equal("https://conduit.test/sitemaps/articles-2.xml"))
+
+			var shard urlset
+			parseXML(app.Client().Get("/sitemaps/articles-2.xml"), &shard)
+			Expect(shard.URLs).To(HaveLen(1))
+			Expect(shard.URLs[0].Loc).To(Equal("https://conduit.test/article/foo-bar"))
+
+			_ = parseJSON(app.Client().Get("/sitemaps/articles-3.xml"), http.StatusNotFound)
+			_ = parseJSON(app.Client().Get("/sitemaps/tags-1.xml"), http.StatusNotFound)
+		})
+
+		It("serves robots.txt", func() {
+			resp := app.Client().Get("/robots.txt")
+			Expect(resp.Code).To(Equal(http.StatusOK))
+			Expect(resp.Body.String()).To(ContainSubstring("Disallow: /api/"))
+			Expect(resp.Body.String()).To(ContainSubstring("Sitemap: https://conduit.test/sitemap.xml"))
+		})
+	})
+
+	Describe("bookmarks", func() {
+		listBookmarks := func(query string) []interface{} {
+			data := parseJSON(userClient.Get("/api/user/bookmarks"+query), http.StatusOK)
+			return data["articles"].([]interface{})
+		}
+
+		createFolder := func(name string) uint64 {
+			json := fmt.Sprintf(`{"folder": {"name": %q}}`, name)
+			data := parseJSON(userClient.PostJSON("/api/user/bookmarks/folders", json), http.StatusOK)
+			return uint64(data["folder"].(map[string]interface{})["id"].(float64))
+		}
+
+		It("bookmarks articles privately", func() {
+			url := fmt.Sprintf("/api/articles/%s/bookmark", slug)
+			data := parseJSON(userClient.Post(url, ""), http.StatusOK)
+			Expect(data["article"]).To(MatchAllKeys(testbed.ExtendKeys(helloArticleKeys, Keys{
+				"bookmarked": Equal(true),
+			})))
+
+			data = parseJSON(userClient.Get("/api/articles/"+slug), http.StatusOK)
+			Expect(data["article"]).To(MatchKeys(IgnoreExtras, Keys{
+				"bookmarked":     Equal(true),
+				"favorited":      Equal(false),
+				"favoritesCount": Equal(float64(0)),
+			}))
+
+			data = parseJSON(app.Client().Get("/api/articles/"+slug), http.StatusOK)
+			Expect(data["article"]).To(MatchKeys(IgnoreExtras, Keys{
+				"bookmarked": Equal(false),
+			}))
+
+			articles := listBookmarks("")
+			Expect(articles).To(HaveLen(1))
+			Expect(articles[0]).To(MatchKeys(IgnoreExtras, Keys{
+				"slug":       Equal(slug),
+				"bookmarked": Equal(true),
+			}))
+
+			data = parseJSON(userClient.Delete(url), http.StatusOK)
+			Expect(data["article"]).To(MatchKeys(IgnoreExtras, Keys{
+				"bookmarked": Equal(false),
+			}))
+			Expect(listBookmarks("")).To(BeEmpty())
+		})
+
+		It("paginates bookmarks", func() {
+			json := `{"article": {"title": "Foo bar", "description": "Foo bar article description!", "body": "Foo bar article body."}}`
+			_ = parseJSON(userClient.PostJSON("/api/articles", json), http.StatusOK)
+
+			_ = parseJSON(userClient.Post("/api/articles/hello-world/bookmark", ""), http.StatusOK)
+			app.AdvanceClock(time.Minute)
+			_ = parseJSON(userClient.Post("/api/articles/foo-bar/bookmark", ""), http.StatusOK)
+
+			data := parseJSON(userClient.Get("/api/user/bookmarks?limit=1"), http.StatusOK)
+			Expect(data["articlesCount"]).To(Equal(float64(2)))
+			Expect(data["articles"]).To(HaveLen(1))
+			Expect(data["articles"].([]interface{})[0]).To(MatchKeys(IgnoreExtras, Keys{
+				"slug": Equal("foo-bar"),
+			}))
+
+			articles := listBookmarks("?limit=1&offset=1")
+			Expect(articles).To(HaveLen(1))
+			Expect(articles[0]).To(MatchKeys(IgnoreExtras, Keys{
+				"slug": Equal("hello-world"),
+			}))
+
+			data = parseJSON(userClient.Get("/api/user/bookmarks?limit=0"), http.StatusBadRequest)
+			Expect(data["code"]).To(Equal("invalid_limit"))
+			data = parseJSON(userClient.Get("/api/user/bookmarks?offset=-1"), http.StatusBadRequest)
+			Expect(data["code"]).To(Equal("invalid_offset"))
+		})
+
+		It("organizes bookmarks in folders", func() {
+			folderID := createFolder("Later")
+
+			data := parseJSON(userClient.PostJSON("/api/user/bookmarks/folders", `{"folder": {"name": "Later"}}`),
+				http.StatusConflict)
+			Expect(data["code"]).To(Equal("folder_exists"))
+
+			url := fmt.Sprintf("/api/articles/%s/bookmark", slug)
+			json := fmt.Sprintf(`{"bookmark": {"folderId": %d}}`, folderID)
+			_ = parseJSON(userClient.PostJSON(url, json), http.StatusOK)
+
+			Expect(listBookmarks(fmt.Sprintf("?folder=%d", folderID))).To(HaveLen(1))
+
+			data = parseJSON(userClient.Get("/api/user/bookmarks/folders"), http.StatusOK)
+			Expect(data["folders"]).To(ConsistOf(MatchAllKeys(Keys{
+				"id":             Equal(float64(folderID)),
+				"name":           Equal("Later"),
+				"bookmarksCount": Equal(float64(1)),
+				"createdAt":      Not(BeEmpty()),
+			})))
+
+			resp := userClient.Delete(fmt.Sprintf("/api/user/bookmarks/folders/%d", folderID))
+			Expect(resp.Code).To(Equal(http.StatusOK))
+
+			_ = parseJSON(userClient.Get(fmt.Sprintf("/api/user/bookmarks?folder=%d", folderID)), http.StatusNotFound)
+			Expect(listBookmarks("")).To(HaveLen(1))
+		})
+	})
+
+	Describe("blocking and muting", func() {
+		var other *org.User
+		var otherClient testbed.Client
+
+		BeforeEach(func() {
+			other = createFollowedUser()
+			otherClient = app.Client().WithToken(other.ID)
+		})
+
+		It("hides articles and comments by muted users", func() {
+			json := `{"article": {"title": "Foo bar", "description": "Foo bar article description!", "body": "Foo bar article body."}}`
+			_ = parseJSON(otherClient.PostJSON("/api/articles", json), http.StatusOK)
+
+			url := fmt.Sprintf("/api/articles/%s/comments", slug)
+			_ = parseJSON(otherClient.PostJSON(url, `{"comment": {"body": "First!"}}`), http.StatusOK)
+
+			data := parseJSON(userClient.Get("/api/articles/feed"), http.StatusOK)
+			Expect(data["articles"]).To(HaveLen(1))
+
+			_ = parseJSON(userClient.Post("/api/profiles/FollowedUser/mute", ""), http.StatusOK)
+
+			data = parseJSON(userClient.Get("/api/articles/feed"), http.StatusOK)
+			Expect(data["articles"]).To(BeEmpty())
+			data = parseJSON(userClient.Get(url), http.StatusOK)
+			Expect(data["comments"]).To(BeEmpty())
+			data = parseJSON(app.Client().Get(url), http.StatusOK)
+			Expect(data["comments"]).To(HaveLen(1))
+
+			data = parseJSON(userClient.Get("/api/user/mutes"), http.StatusOK)
+			Expect(data["profiles"]).To(ConsistOf(MatchKeys(IgnoreExtras, Keys{
+				"username": Equal("FollowedUser"),
+			})))
+
+			_ = parseJSON(userClient.Delete("/api/profiles/FollowedUser/mute"), http.StatusOK)
+			data = parseJSON(userClient.Get(url), http.StatusOK)
+			Expect(data["comments"]).To(HaveLen(1))
+		})
+
+		It("stops blocked users from interacting", func() {
+			_ = parseJSON(otherClient.Post("/api/profiles/CurrentUser/follow", ""), http.StatusOK)
+
+			data := parseJSON(userClient.Post("/api/profiles/FollowedUser/block", ""), http.StatusOK)
+			Expect(data["profile"]).To(MatchKeys(IgnoreExtras, Keys{
+				"following":      Equal(false),
+				"followingCount": Equal(float64(0)),
+			}))
+
+			url := fmt.Sprintf("/api/articles/%s/comments", slug)
+			data = parseJSON(otherClient.PostJSON(url, `{"comment": {"body": "Boo"}}`), http.StatusForbidden)
+			Expect(data["code"]).To(Equal("blocked"))
+			data = parseJSON(otherClient.Post(fmt.Sprintf("/api/articles/%s/favorite", slug), ""), http.StatusForbidden)
+			Expect(data["code"]).To(Equal("blocked"))
+			data = parseJSON(otherClient.Post("/api/profiles/CurrentUser/follow", ""), http.StatusForbidden)
+			Expect(data["code"]).To(Equal("blocked"))
+
+			data = parseJSON(userClient.Get("/api/user/blocks"), http.StatusOK)
+			Expect(data["profiles"]).To(HaveLen(1))
+
+			_ = parseJSON(userClient.Delete("/api/profiles/FollowedUser/block"), http.StatusOK)
+			_ = parseJSON(otherClient.PostJSON(url, `{"comment": {"body": "Sorry"}}`), http.StatusOK)
+		})
+
+		It("waits for a block in progress", func() {
+			codes := make(chan int, 2)
+			err := app.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+				if _, err := org.NewUserService(app.App).Block(ctx, user, "FollowedUser"); err != nil {
+					return err
+				}
+
+				go func() {
+					defer GinkgoRecover()
+					url := fmt.Sprintf("/api/articles/%s/comments", slug)
+					codes <- otherClient.PostJSON(url, `{"comment": {"body": "Boo"}}`).Code
+				}()
+				go func() {
+					defer GinkgoRecover()
+					codes <- otherClient.Post(fmt.Sprintf("/api/articles/%s/favorite", slug), "").Code
+				}()
+
+				Consistently(codes, 200*time.Millisecond).ShouldNot(Receive())
+				return nil
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			for i := 0; i < cap(codes); i++ {
+				Eventually(codes).Should(Receive(Equal(http.StatusForbidden)))
+			}
+		})
+	})
+
+	Describe("moderation", func() {
+		var reporter *org.User
+		var reporterClient, moderatorClient testbed.Client
+
+		report := func(url, reason string) map[string]interface{} {
+			json := fmt.Sprintf(`{"report": {"reason": %q}}`, reason)
+			data := parseJSON(reporterClient.PostJSON(url, json), http.StatusOK)
+			return data["report"].(map[string]interface{})
+		}
+
+		resolve := func(report map[string]interface{}, action string) map[string]interface{} {
+			url := fmt.Sprintf("/api/moderation/reports/%d/resolve", int(report["id"].(float64)))
+			json := fmt.Sprintf(`{"resolution": {"action": %q}}`, action)
+			data := parseJSON(moderatorClient.PostJSON(url, json), http.StatusOK)
+			return data["report"].(map[string]interface{})
+		}
+
+		BeforeEach(func() {
+			reporter = &org.User{
+				Username:     "reporter",
+				Email:        "reporter@bar.com",
+				PasswordHash: "#",
+			}
+			moderator := &org.User{
+				Username:     "moderator",
+				Email:        "moderator@bar.com",
+				PasswordHash: "#",
+				IsModerator:  true,
+			}
+			_, err := app.DB().NewInsert().Model(reporter).Exec(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = app.DB().NewInsert().Model(moderator).Exec(ctx)
+			Expect(err).NotTo(HaveOccurred())
+
+			reporterClient = app.Client().WithToken(reporter.ID)
+			moderatorClient = app.Client().WithToken(moderator.ID)
+		})
+
+		It("queues reports for moderators", func() {
+			got := report("/api/articles/"+slug+"/report", "Spam")
+			Expect(got).To(MatchKeys(IgnoreExtras, Keys{
+				"targetType": Equal("article"),
+				"reason":     Equal("Spam"),
+				"status":     Equal("open"),
+				"reporter":   profileKeys("reporter", false),
+				"author":     profileKeys("CurrentUser", false),
+			}))
+
+			data := parseJSON(reporterClient.PostJSON("/api/articles/"+slug+"/report", `{"report": {"reason": "Spam"}}`),
+				http.StatusConflict)
+			Expect(data["code"]).To(Equal("already_reported"))
+			data = parseJSON(userClient.PostJSON("/api/articles/"+slug+"/report", `{"report": {"reason": "Spam"}}`),
+				http.StatusUnprocessableEntity)
+			Expect(data["code"]).To(Equal("invalid_report"))
+
+			_ = parseJSON(reporterClient.Get("/api/moderation/reports"), http.StatusForbidden)
+
+			data = parseJSON(moderatorClient.Get("/api/moderation/reports"), http.StatusOK)
+			Expect(data["reportsCount"]).To(Equal(float64(1)))
+		})
+
+		It("requires a session to moderate", func() {
+			json := `{"token": {"name": "ci", "scopes": ["reports:write"]}}`
+			data := parseJSON(moderatorClient.PostJSON("/api/user/tokens", json), http.StatusOK)
+			token := data["token"].(map[string]interface{})["token"].(string)
+
+			data = parseJSON(app.Client().WithAuthToken(token).Get("/api/moderation/reports"), http.StatusForbidden)
+			Expect(data["code"]).To(Equal("session_required"))
+		})
+
+		It("hides articles and notifies the reporter", func() {
+			got := resolve(report("/api/articles/"+slug+"/report", "Spam"), "hide")
+			Expect(got["status"]).To(Equal("hidden"))
+
+			_ = parseJSON(app.Client().Get("/api/articles/"+slug), http.StatusNotFound)
+			data := parseJSON(app.Client().Get("/api/articles"), http.StatusOK)
+			Expect(data["articles"]).To(BeEmpty())
+
+			_ = parseJSON(app.Client().Get("/api/articles/"+slug+"/comments"), http.StatusNotFound)
+			_ = parseJSON(reporterClient.PostJSON("/api/articles/"+slug+"/comments", `{"comment": {"body": "Hi"}}`),
+				http.StatusNotFound)
+			_ = parseJSON(userClient.PutJSON("/api/articles/"+slug, `{"article": {"title": "Back"}}`),
+				http.StatusNotFound)
+
+			data = parseJSON(app.Client().Get("/api/tags/"), http.StatusOK)
+			Expect(data["tags"]).To(BeEmpty())
+			data = parseJSON(app.Client().Get("/api/tags/greeting"), http.StatusOK)
+			Expect(data["tag"]).To(HaveKeyWithValue("articlesCount", float64(0)))
+
+			data = parseJSON(reporterClient.Get("/api/user/notifications"), http.StatusOK)
+			Expect(data["notifications"]).To(ConsistOf(MatchKeys(IgnoreExtras, Keys{
+				"kind":    Equal("report_resolved"),
+				"message": ContainSubstring("article was hidden"),
+			})))
+
+			data = parseJSON(moderatorClient.Get("/api/moderation/reports"), http.StatusOK)
+			Expect(data["reports"]).To(BeEmpty())
+			data = parseJSON(moderatorClient.Get("/api/moderation/reports?status=hidden"), http.StatusOK)
+			Expect(data["reports"]).To(HaveLen(1))
+		})
+
+		It("hides and deletes comments", func() {
+			url := fmt.Sprintf("/api/articles/%s/comments", slug)
+			data := parseJSON(userClient.PostJSON(url, `{"comment": {"body": "First"}}`), http.StatusOK)
+			first := int(data["comment"].(map[string]interface{})["id"].(float64))
+			data = parseJSON(userClient.PostJSON(url, `{"comment": {"body": "Second"}}`), http.StatusOK)
+			second := int(data["comment"].(map[string]interface{})["id"].(float64))
+
+			resolve(report(fmt.Sprintf("%s/%d/report", url, first), "Rude"), "hide")
+			resolve(report(fmt.Sprintf("%s/%d/report", url, second), "Rude"), "delete")
+
+			data = parseJSON(app.Client().Get(url), http.StatusOK)
+			Expect(data["comments"]).To(BeEmpty())
+		})
+
+		It("suspends authors", func() {
+			got := report("/api/profiles/CurrentUser/report", "Harassment")
+			data := parseJSON(moderatorClient.PostJSON(
+				fmt.Sprintf("/api/moderation/reports/%d/resolve", int(got["id"].(float64))),
+				`{"resolution": {"action": "hide"}}`), http.StatusUnprocessableEntity)
+			Expect(data["code"]).To(Equal("invalid_action"))
+
+			got = resolve(got, "suspend")
+			Expect(got["status"]).To(Equal("suspended"))
+
+			data = parseJSON(userClient.Get("/api/user/"), http.StatusForbidden)
+			Expect(data["code"]).To(Equal("suspended"))
+		})
+
+		It("doesn't suspend admins and moderators", func() {
+			admin := &org.User{
+				Username:     "admin",
+				Email:        "admin@bar.com",
+				PasswordHash: "#",
+				IsAdmin:      true,
+			}
+			_, err := app.DB().NewInsert().Model(admin).Exec(ctx)
+			Expect(err).NotTo(HaveOccurred())
+
+			for _, username := range []string{"admin", "moderator"} {
+				got := report("/api/profiles/"+username+"/report", "Harassment")
+				data := parseJSON(moderatorClient.PostJSON(
+					fmt.Sprintf("/api/moderation/reports/%d/resolve", int(got["id"].(float64))),
+					`{"resolution": {"action": "suspend"}}`), http.StatusForbidden)
+				Expect(data["code"]).To(Equal("suspend_staff"))
+			}
+
+			data := parseJSON(app.Client().WithToken(admin.ID).Get("/api/user/"), http.StatusOK)
+			Expect(data["user"]).To(HaveKeyWithValue("username", "admin"))
+			data = parseJSON(moderatorClient.Get("/api/moderation/reports"), http.StatusOK)
+			Expect(data["reportsCount"]).To(Equal(float64(2)))
+		})
+
+		It("dismisses reports", func() {
+			got := resolve(report("/api/articles/"+slug+"/report", "Boring"), "dismiss")
+			Expect(got["status"]).To(Equal("dismissed"))
+
+			_ = parseJSON(app.Client().Get("/api/articles/"+slug), http.StatusOK)
+
+			data := parseJSON(moderatorClient.PostJSON(
+				fmt.Sprintf("/api/moderation/reports/%d/resolve", int(got["id"].(float64))),
+				`{"resolution": {"action": "hide"}}`), http.StatusConflict)
+			Expect(data["code"]).To(Equal("report_resolved"))
+		})
+	})
+})
+
+var _ = Describe("conformance", func() {
+	It("passes the RealWorld API suite", func() {
+		ctx := context.Background()
+		app := testbed.StartApp(ctx)
+		app.TruncateDB(ctx)
+
+		report := testbed.NewConformance(app.Router()).Run(ctx)
+
+		var buf bytes.Buffer
+		_, _ = report.WriteTo(&buf)
+		Expect(report.Failed()).To(Equal(0), buf.String())
 	})
 })
 
+var _ = Describe("openapi", func() {
+	var ctx context.Context
+	var app *testbed.TestApp
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		app = testbed.StartApp(ctx)
+	})
+
+	It("serves the spec generated from routes", func() {
+		resp := app.Client().Get("/api/openapi.json")
+		data := parseJSON(resp, http.StatusOK)
+		Expect(data["openapi"]).To(HavePrefix("3."))
+		Expect(data["paths"]).To(HaveKey("/api/articles/{slug}"))
+		Expect(data["paths"]).To(HaveKey("/api/articles/{slug}/comments/{id}"))
+
+		schemas := data["components"].(map[string]interface{})["schemas"]
+		Expect(schemas).To(HaveKey("Article"))
+		Expect(schemas).To(HaveKey("Profile"))
+	})
+
+	It("reports responses that drift from the spec", func() {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"tags": "greeting"}`))
+		})
+
+		var errs []error
+		mw := openapi.NewValidator(app.OpenAPI()).Middleware(handler, func(err error) {
+			errs = append(errs, err)
+		})
+		mw.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/tags/", nil))
+		Expect(errs).To(HaveLen(1))
+		Expect(errs[0].Error()).To(ContainSubstring("tags"))
+
+		errs = nil
+		mw.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/unknown", nil))
+		Expect(errs).To(HaveLen(1))
+	})
+})
+
+func profileKeys(username string, following bool) types.GomegaMatcher {
+	return MatchAllKeys(Keys{
+		"username":       Equal(username),
+		"bio":            Equal(""),
+		"image":          Equal("/api/profiles/" + username + "/avatar.svg"),
+		"following":      Equal(following),
+		"followersCount": BeNumerically(">=", 0),
+		"followingCount": BeNumerically(">=", 0),
+	})
+}
+
 func parseJSON(resp *httptest.ResponseRecorder, code int) map[string]interface{} {
 	out := make(map[string]interface{})
 	err := json.Unmarshal(resp.Body.Bytes(), &out)
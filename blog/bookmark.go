@@ -0,0 +1,28 @@
+package blog
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Bookmark is a private reading list entry, unlike FavoriteArticle,
+// which is public via Article.FavoritesCount.
+type Bookmark struct {
+	bun.BaseModel `bun:"bookmarks,alias:bm"`
+
+	UserID    uint64    `bun:",pk"`
+	ArticleID uint64    `bun:",pk"`
+	FolderID  uint64    `bun:",nullzero"`
+	CreatedAt time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+type BookmarkFolder struct {
+	bun.BaseModel `bun:"bookmark_folders,alias:bf"`
+
+	ID             uint64    `json:"id"`
+	UserID         uint64    `json:"-"`
+	Name           string    `json:"name"`
+	BookmarksCount int       `json:"bookmarksCount" bun:",scanonly"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
@@ -0,0 +1,153 @@
+package blog
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/uptrace/bun-realworld-app/bunapp"
+	"github.com/uptrace/bun-realworld-app/httputil"
+	"github.com/uptrace/bun-realworld-app/org"
+	"github.com/uptrace/bunrouter"
+)
+
+type BookmarkHandler struct {
+	app     *bunapp.App
+	service *BookmarkService
+}
+
+func NewBookmarkHandler(app *bunapp.App) BookmarkHandler {
+	return BookmarkHandler{
+		app:     app,
+		service: NewBookmarkService(app),
+	}
+}
+
+func (h BookmarkHandler) Bookmark(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+	user := org.UserFromContext(ctx)
+
+	// The body is optional: without it the bookmark is kept outside of folders.
+	var in BookmarkRequest
+	if err := httputil.UnmarshalJSON(w, req, &in, 10<<kb); err != nil && err != io.EOF {
+		return err
+	}
+
+	var folderID uint64
+	if in.Bookmark != nil {
+		folderID = in.Bookmark.FolderID
+	}
+
+	f, err := decodeArticleFilter(h.app, req)
+	if err != nil {
+		return err
+	}
+
+	article, err := h.service.Bookmark(ctx, user, f, folderID)
+	if err != nil {
+		return err
+	}
+
+	return bunrouter.JSON(w, bunrouter.H{
+		"article": article,
+	})
+}
+
+func (h BookmarkHandler) Unbookmark(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+	user := org.UserFromContext(ctx)
+
+	f, err := decodeArticleFilter(h.app, req)
+	if err != nil {
+		return err
+	}
+
+	article, err := h.service.Unbookmark(ctx, user, f)
+	if err != nil {
+		return err
+	}
+
+	return bunrouter.JSON(w, bunrouter.H{
+		"article": article,
+	})
+}
+
+func (h BookmarkHandler) List(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+	user := org.UserFromContext(ctx)
+	query := req.URL.Query()
+
+	var folderID uint64
+	if s := query.Get("folder"); s != "" {
+		id, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return ErrFolderNotFound
+		}
+		folderID = id
+	}
+
+	limit, offset, err := parsePage(query.Get("limit"), query.Get("offset"))
+	if err != nil {
+		return err
+	}
+
+	articles, count, err := h.service.List(ctx, user, folderID, limit, offset)
+	if err != nil {
+		return err
+	}
+
+	return bunrouter.JSON(w, bunrouter.H{
+		"articles":      articles,
+		"articlesCount": count,
+	})
+}
+
+func (h BookmarkHandler) Folders(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+	user := org.UserFromContext(ctx)
+
+	folders, err := h.service.Folders(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	return bunrouter.JSON(w, bunrouter.H{
+		"folders": folders,
+	})
+}
+
+func (h BookmarkHandler) CreateFolder(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+	user := org.UserFromContext(ctx)
+
+	var in BookmarkFolderRequest
+	if err := httputil.UnmarshalJSON(w, req, &in, 10<<kb); err != nil {
+		return err
+	}
+
+	if in.Folder == nil {
+		return errors.New(`JSON field "folder" is required`)
+	}
+
+	folder, err := h.service.CreateFolder(ctx, user, in.Folder.Name)
+	if err != nil {
+		return err
+	}
+
+	return bunrouter.JSON(w, bunrouter.H{
+		"folder": folder,
+	})
+}
+
+func (h BookmarkHandler) DeleteFolder(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+	user := org.UserFromContext(ctx)
+
+	id, err := strconv.ParseUint(req.Param("id"), 10, 64)
+	if err != nil {
+		return ErrFolderNotFound
+	}
+
+	return h.service.DeleteFolder(ctx, user, id)
+}
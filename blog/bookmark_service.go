@@ -0,0 +1,214 @@
+package blog
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/uptrace/bun-realworld-app/bunapp"
+	"github.com/uptrace/bun-realworld-app/httputil/httperror"
+	"github.com/uptrace/bun-realworld-app/org"
+)
+
+const maxFolderNameLen = 100
+
+var (
+	ErrFolderNotFound = httperror.NotFound("bookmark folder not found")
+	ErrFolderExists   = httperror.New(http.StatusConflict,
+		"folder_exists", "bookmark folder with this name already exists")
+)
+
+// BookmarkService manages bookmarks and bookmark folders of users.
+type BookmarkService struct {
+	app      *bunapp.App
+	articles *ArticleService
+}
+
+func NewBookmarkService(app *bunapp.App) *BookmarkService {
+	return &BookmarkService{
+		app:      app,
+		articles: NewArticleService(app),
+	}
+}
+
+// Bookmark adds the article to the bookmarks of the user or moves it to the folder.
+// A zero folderID keeps the bookmark outside of folders.
+func (s *BookmarkService) Bookmark(
+	ctx context.Context, user *org.User, f *ArticleFilter, folderID uint64,
+) (*Article, error) {
+	f.UserID = user.ID
+
+	article, err := s.articles.Get(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+
+	if folderID != 0 {
+		if _, err := s.selectFolder(ctx, user, folderID); err != nil {
+			return nil, err
+		}
+	}
+
+	bookmark := &Bookmark{
+		UserID:    user.ID,
+		ArticleID: article.ID,
+		FolderID:  folderID,
+		CreatedAt: s.app.Clock().Now(),
+	}
+	if _, err := s.app.IDB(ctx).NewInsert().
+		Model(bookmark).
+		On("CONFLICT (user_id, article_id) DO UPDATE").
+		Set("folder_id = EXCLUDED.folder_id").
+		Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	article.Bookmarked = true
+	return article, nil
+}
+
+func (s *BookmarkService) Unbookmark(
+	ctx context.Context, user *org.User, f *ArticleFilter,
+) (*Article, error) {
+	f.UserID = user.ID
+
+	article, err := s.articles.Get(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.app.IDB(ctx).NewDelete().
+		Model((*Bookmark)(nil)).
+		Where("user_id = ?", user.ID).
+		Where("article_id = ?", article.ID).
+		Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	article.Bookmarked = false
+	return article, nil
+}
+
+// List returns a page of the articles bookmarked by the user, most recently
+// bookmarked first, and the total number of bookmarks.
+func (s *BookmarkService) List(
+	ctx context.Context, user *org.User, folderID uint64, limit, offset int,
+) ([]*Article, int, error) {
+	if folderID != 0 {
+		if _, err := s.selectFolder(ctx, user, folderID); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	f := &ArticleFilter{
+		app:       s.app,
+		UserID:    user.ID,
+		Bookmarks: true,
+		FolderID:  folderID,
+	}
+
+	articles := make([]*Article, 0)
+	count, err := s.app.IDB(ctx).NewSelect().
+		Model(&articles).
+		ColumnExpr("?TableColumns").
+		Apply(f.query).
+		OrderExpr("b.created_at DESC, a.id DESC").
+		Limit(limit).
+		Offset(offset).
+		ScanAndCount(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	renderArticles(s.app, articles...)
+	return articles, count, nil
+}
+
+// Folders returns the folders of the user ordered by name.
+func (s *BookmarkService) Folders(ctx context.Context, user *org.User) ([]*BookmarkFolder, error) {
+	subq := s.app.DB().NewSelect().
+		Model((*Bookmark)(nil)).
+		ColumnExpr("count(*)").
+		Where("bm.folder_id = bf.id")
+
+	folders := make([]*BookmarkFolder, 0)
+	if err := s.app.IDB(ctx).NewSelect().
+		Model(&folders).
+		ColumnExpr("bf.*").
+		ColumnExpr("(?) AS bookmarks_count", subq).
+		Where("bf.user_id = ?", user.ID).
+		OrderExpr("bf.name ASC").
+		Scan(ctx); err != nil {
+		return nil, err
+	}
+	return folders, nil
+}
+
+func (s *BookmarkService) CreateFolder(
+	ctx context.Context, user *org.User, name string,
+) (*BookmarkFolder, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxFolderNameLen {
+		return nil, httperror.New(http.StatusUnprocessableEntity, "invalid_folder",
+			"folder name must have from 1 to %d characters", maxFolderNameLen)
+	}
+
+	folder := &BookmarkFolder{
+		UserID:    user.ID,
+		Name:      name,
+		CreatedAt: s.app.Clock().Now(),
+	}
+	res, err := s.app.IDB(ctx).NewInsert().
+		Model(folder).
+		On("CONFLICT (user_id, name) DO NOTHING").
+		Returning("id").
+		Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, ErrFolderExists
+	}
+	return folder, nil
+}
+
+// DeleteFolder deletes the folder. Its bookmarks are kept outside of folders.
+func (s *BookmarkService) DeleteFolder(ctx context.Context, user *org.User, id uint64) error {
+	res, err := s.app.IDB(ctx).NewDelete().
+		Model((*BookmarkFolder)(nil)).
+		Where("id = ?", id).
+		Where("user_id = ?", user.ID).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrFolderNotFound
+	}
+	return nil
+}
+
+func (s *BookmarkService) selectFolder(
+	ctx context.Context, user *org.User, id uint64,
+) (*BookmarkFolder, error) {
+	folder := new(BookmarkFolder)
+	if err := s.app.IDB(ctx).NewSelect().
+		Model(folder).
+		Where("id = ?", id).
+		Where("user_id = ?", user.ID).
+		Scan(ctx); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrFolderNotFound
+		}
+		return nil, err
+	}
+	return folder, nil
+}
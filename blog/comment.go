@@ -10,8 +10,9 @@ import (
 type Comment struct {
 	bun.BaseModel `bun:"comments,alias:c"`
 
-	ID   uint64 `json:"id"`
-	Body string `json:"body"`
+	ID       uint64 `json:"id"`
+	Body     string `json:"body"`
+	BodyHTML string `json:"bodyHtml" bun:"-"`
 
 	Author   *org.Profile `json:"author" bun:"rel:belongs-to"`
 	AuthorID uint64       `json:"-"`
@@ -20,4 +21,6 @@ type Comment struct {
 
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
+	// HiddenAt is set by moderators.
+	HiddenAt time.Time `json:"-" bun:",nullzero"`
 }
@@ -11,39 +11,25 @@ import (
 )
 
 type CommentHandler struct {
-	app *bunapp.App
+	app     *bunapp.App
+	service *CommentService
 }
 
 func NewCommentHandler(app *bunapp.App) CommentHandler {
 	return CommentHandler{
-		app: app,
+		app:     app,
+		service: NewCommentService(app),
 	}
 }
 
 func (h CommentHandler) List(w http.ResponseWriter, req bunrouter.Request) error {
 	ctx := req.Context()
 
-	article, err := SelectArticle(ctx, h.app, req.Param("slug"))
+	comments, err := h.service.List(ctx, org.UserFromContext(ctx), req.Param("slug"))
 	if err != nil {
 		return err
 	}
 
-	var userID uint64
-	if user := org.UserFromContext(ctx); user != nil {
-		userID = user.ID
-	}
-
-	comments := make([]*Comment, 0)
-	if err := h.app.DB().NewSelect().
-		Model(&comments).
-		ColumnExpr("c.*").
-		Relation("Author").
-		Apply(authorFollowingColumn(h.app, userID)).
-		Where("article_id = ?", article.ID).
-		Scan(ctx); err != nil {
-		return err
-	}
-
 	return bunrouter.JSON(w, bunrouter.H{
 		"comments": comments,
 	})
@@ -52,30 +38,13 @@ func (h CommentHandler) List(w http.ResponseWriter, req bunrouter.Request) error
 func (h CommentHandler) Show(w http.ResponseWriter, req bunrouter.Request) error {
 	ctx := req.Context()
 
-	article, err := SelectArticle(ctx, h.app, req.Param("slug"))
-	if err != nil {
-		return err
-	}
-
 	id, err := req.Params().Uint64("id")
 	if err != nil {
 		return err
 	}
 
-	var userID uint64
-	if user := org.UserFromContext(ctx); user != nil {
-		userID = user.ID
-	}
-
-	comment := new(Comment)
-	if err := h.app.DB().NewSelect().
-		Model(comment).
-		ColumnExpr("c.*").
-		Relation("Author").
-		Apply(authorFollowingColumn(h.app, userID)).
-		Where("c.id = ?", id).
-		Where("article_id = ?", article.ID).
-		Scan(ctx); err != nil {
+	comment, err := h.service.Get(ctx, org.UserFromContext(ctx), req.Param("slug"), id)
+	if err != nil {
 		return err
 	}
 
@@ -88,15 +57,7 @@ func (h CommentHandler) Create(w http.ResponseWriter, req bunrouter.Request) err
 	ctx := req.Context()
 	user := org.UserFromContext(ctx)
 
-	article, err := SelectArticle(ctx, h.app, req.Param("slug"))
-	if err != nil {
-		return err
-	}
-
-	var in struct {
-		Comment *Comment `json:"comment"`
-	}
-
+	var in CommentRequest
 	if err := httputil.UnmarshalJSON(w, req, &in, 10<<kb); err != nil {
 		return err
 	}
@@ -105,20 +66,12 @@ func (h CommentHandler) Create(w http.ResponseWriter, req bunrouter.Request) err
 		return errors.New(`JSON field "comment" is required`)
 	}
 
-	comment := in.Comment
+	comment := &Comment{Body: in.Comment.Body}
 
-	comment.AuthorID = user.ID
-	comment.ArticleID = article.ID
-	comment.CreatedAt = h.app.Clock().Now()
-	comment.UpdatedAt = h.app.Clock().Now()
-
-	if _, err := h.app.DB().NewInsert().
-		Model(comment).
-		Exec(ctx); err != nil {
+	if err := h.service.Create(ctx, user, req.Param("slug"), comment); err != nil {
 		return err
 	}
 
-	comment.Author = org.NewProfile(user)
 	return bunrouter.JSON(w, bunrouter.H{
 		"comment": comment,
 	})
@@ -128,18 +81,10 @@ func (h CommentHandler) Delete(w http.ResponseWriter, req bunrouter.Request) err
 	ctx := req.Context()
 	user := org.UserFromContext(ctx)
 
-	article, err := SelectArticle(ctx, h.app, req.Param("slug"))
+	id, err := req.Params().Uint64("id")
 	if err != nil {
 		return err
 	}
 
-	if _, err := h.app.DB().NewDelete().
-		Model((*Comment)(nil)).
-		Where("author_id = ?", user.ID).
-		Where("article_id = ?", article.ID).
-		Exec(ctx); err != nil {
-		return err
-	}
-
-	return nil
+	return h.service.Delete(ctx, user, req.Param("slug"), id)
 }
@@ -0,0 +1,175 @@
+package blog
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun-realworld-app/bunapp"
+	"github.com/uptrace/bun-realworld-app/httputil/httperror"
+	"github.com/uptrace/bun-realworld-app/org"
+)
+
+var ErrCommentNotFound = httperror.NotFound("comment not found")
+
+// CommentService manages article comments independently of HTTP.
+// The viewer may be nil for anonymous users.
+type CommentService struct {
+	app *bunapp.App
+}
+
+func NewCommentService(app *bunapp.App) *CommentService {
+	return &CommentService{
+		app: app,
+	}
+}
+
+func (s *CommentService) List(ctx context.Context, viewer *org.User, slug string) ([]*Comment, error) {
+	article, err := SelectArticle(ctx, s.app, slug)
+	if err != nil {
+		return nil, articleErr(err)
+	}
+
+	comments := make([]*Comment, 0)
+	if err := s.app.IDB(ctx).NewSelect().
+		Model(&comments).
+		ColumnExpr("c.*").
+		Relation("Author").
+		Apply(authorFollowingColumn(s.app, viewerID(viewer))).
+		Apply(excludeMuted(s.app, viewerID(viewer), "c.author_id")).
+		Where("article_id = ?", article.ID).
+		Where("c.hidden_at IS NULL").
+		Scan(ctx); err != nil {
+		return nil, err
+	}
+	renderComments(s.app, comments...)
+	return comments, nil
+}
+
+func (s *CommentService) Get(
+	ctx context.Context, viewer *org.User, slug string, id uint64,
+) (*Comment, error) {
+	article, err := SelectArticle(ctx, s.app, slug)
+	if err != nil {
+		return nil, articleErr(err)
+	}
+
+	comment := new(Comment)
+	if err := s.app.IDB(ctx).NewSelect().
+		Model(comment).
+		ColumnExpr("c.*").
+		Relation("Author").
+		Apply(authorFollowingColumn(s.app, viewerID(viewer))).
+		Where("c.id = ?", id).
+		Where("article_id = ?", article.ID).
+		Where("c.hidden_at IS NULL").
+		Scan(ctx); err != nil {
+		return nil, commentErr(err)
+	}
+	renderComments(s.app, comment)
+	return comment, nil
+}
+
+// Create adds the comment by the user to the article with the slug.
+func (s *CommentService) Create(
+	ctx context.Context, user *org.User, slug string, comment *Comment,
+) error {
+	article, err := SelectArticle(ctx, s.app, slug)
+	if err != nil {
+		return articleErr(err)
+	}
+
+	comment.AuthorID = user.ID
+	comment.ArticleID = article.ID
+	comment.CreatedAt = s.app.Clock().Now()
+	comment.UpdatedAt = s.app.Clock().Now()
+
+	if err := s.app.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if err := checkBlocked(ctx, tx, s.app, article, user); err != nil {
+			return err
+		}
+
+		_, err := tx.NewInsert().
+			Model(comment).
+			Exec(ctx)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	comment.Author = org.NewProfile(user)
+	renderComments(s.app, comment)
+	return nil
+}
+
+// Delete deletes the comment with the id. Only the author can delete the comment.
+func (s *CommentService) Delete(ctx context.Context, user *org.User, slug string, id uint64) error {
+	article, err := SelectArticle(ctx, s.app, slug)
+	if err != nil {
+		return articleErr(err)
+	}
+
+	comment := new(Comment)
+	if err := s.app.IDB(ctx).NewSelect().
+		Model(comment).
+		Where("id = ?", id).
+		Where("article_id = ?", article.ID).
+		Scan(ctx); err != nil {
+		return commentErr(err)
+	}
+	if comment.AuthorID != user.ID {
+		return ErrForbidden
+	}
+
+	if _, err := s.app.IDB(ctx).NewDelete().
+		Model((*Comment)(nil)).
+		Where("id = ?", comment.ID).
+		Exec(ctx); err != nil {
+		return err
+	}
+	return nil
+}
+
+func commentErr(err error) error {
+	if err == sql.ErrNoRows {
+		return ErrCommentNotFound
+	}
+	return err
+}
+
+// checkBlocked returns org.ErrBlocked when the author of the article blocked the user.
+// It locks the author until tx ends, so the author can't block the user before
+// the caller writes in tx.
+func checkBlocked(
+	ctx context.Context, tx bun.Tx, app *bunapp.App, article *Article, user *org.User,
+) error {
+	if err := org.ShareLockUser(ctx, tx, article.AuthorID); err != nil {
+		return err
+	}
+
+	blocked, err := org.IsBlocked(ctx, app, article.AuthorID, user.ID)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return org.ErrBlocked
+	}
+	return nil
+}
+
+// excludeMuted skips the rows whose author, the column, is muted by the viewer.
+func excludeMuted(app *bunapp.App, viewerID uint64, column string) func(*bun.SelectQuery) *bun.SelectQuery {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		if viewerID == 0 {
+			return q
+		}
+		return q.Where("? NOT IN (?)", bun.Ident(column), org.MutedUserIDs(app, viewerID))
+	}
+}
+
+func viewerID(viewer *org.User) uint64 {
+	if viewer == nil {
+		return 0
+	}
+	return viewer.ID
+}
@@ -0,0 +1,407 @@
+package blog
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun-realworld-app/bunapp"
+	"github.com/uptrace/bun-realworld-app/httputil"
+	"github.com/uptrace/bun-realworld-app/httputil/httperror"
+	"github.com/uptrace/bun-realworld-app/org"
+	"github.com/uptrace/bunrouter"
+)
+
+const (
+	atomContentType = "application/atom+xml; charset=utf-8"
+	rssContentType  = "application/rss+xml; charset=utf-8"
+)
+
+var errFeedNotFound = httperror.NotFound("feed not found")
+
+// Latest returns the most recently created articles matching the filter.
+func (s *ArticleService) Latest(ctx context.Context, f *ArticleFilter) ([]*Article, error) {
+	f.app = s.app
+
+	articles := make([]*Article, 0)
+	if err := s.app.IDB(ctx).NewSelect().
+		Model(&articles).
+		ColumnExpr("?TableColumns").
+		Apply(f.query).
+		OrderExpr("a.created_at DESC, a.id DESC").
+		Limit(f.Pager.GetLimit()).
+		Scan(ctx); err != nil {
+		return nil, err
+	}
+	renderArticles(s.app, articles...)
+	return articles, nil
+}
+
+//------------------------------------------------------------------------------
+
+// FeedHandler serves Atom and RSS 2.0 feeds, e.g. /feeds/articles.atom,
+// /feeds/@username.rss and /feeds/tags/golang.atom.
+type FeedHandler struct {
+	app     *bunapp.App
+	service *ArticleService
+}
+
+func NewFeedHandler(app *bunapp.App) FeedHandler {
+	return FeedHandler{
+		app:     app,
+		service: NewArticleService(app),
+	}
+}
+
+// Articles serves the global feed and per-author feeds.
+func (h FeedHandler) Articles(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+
+	name, format, err := splitFeedName(req.Param("feed"))
+	if err != nil {
+		return err
+	}
+
+	f := &ArticleFilter{app: h.app}
+	meta := feedMeta{
+		title: "Conduit",
+		link:  publicURL(h.app, "/"),
+	}
+
+	switch {
+	case name == "articles":
+	case strings.HasPrefix(name, "@") && len(name) > 1:
+		user, err := org.SelectUserByUsername(ctx, h.app, name[1:])
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return org.ErrUserNotFound
+			}
+			return err
+		}
+
+		f.Author = user.Username
+		meta.title = "Articles by " + user.Username
+		meta.link = profileURL(h.app, user.Username)
+	default:
+		return errFeedNotFound
+	}
+
+	return h.serve(w, req, f, meta, format)
+}
+
+// Tag serves per-tag feeds. Tag aliases resolve to the canonical tag.
+func (h FeedHandler) Tag(w http.ResponseWriter, req bunrouter.Request) error {
+	name, format, err := splitFeedName(req.Param("feed"))
+	if err != nil {
+		return err
+	}
+
+	tag, err := normalizeTag(name)
+	if err != nil {
+		return errFeedNotFound
+	}
+
+	f := &ArticleFilter{
+		app: h.app,
+		Tag: tag,
+	}
+	meta := feedMeta{
+		title: "Articles tagged " + tag,
+		link:  tagURL(h.app, tag),
+	}
+	return h.serve(w, req, f, meta, format)
+}
+
+type feedMeta struct {
+	title string
+	link  string
+}
+
+func (h FeedHandler) serve(
+	w http.ResponseWriter, req bunrouter.Request, f *ArticleFilter, meta feedMeta, format string,
+) error {
+	limit, err := parseFeedLimit(h.app, req.URL.Query().Get("limit"))
+	if err != nil {
+		return err
+	}
+	f.Pager.Limit = limit
+
+	lastModified, err := h.service.LastModified(req.Context())
+	if err != nil {
+		return err
+	}
+
+	articles, err := h.service.Latest(req.Context(), f)
+	if err != nil {
+		return err
+	}
+
+	var updated time.Time
+	for _, article := range articles {
+		if article.UpdatedAt.After(updated) {
+			updated = article.UpdatedAt
+		}
+	}
+
+	self := publicURL(h.app, req.URL.Path)
+
+	var body []byte
+	var contentType string
+	switch format {
+	case "atom":
+		contentType = atomContentType
+		body, err = encodeXML(newAtomFeed(h.app, meta, self, updated, articles))
+	case "rss":
+		contentType = rssContentType
+		body, err = encodeXML(newRSSFeed(h.app, meta, self, updated, articles))
+	}
+	if err != nil {
+		return err
+	}
+
+	maxAge := h.app.Config().HTTPCache.MaxAge
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+	return httputil.Conditional(w, req, contentType, body, lastModified)
+}
+
+// splitFeedName splits e.g. "articles.atom" into "articles" and "atom".
+func splitFeedName(s string) (name, format string, err error) {
+	i := strings.LastIndexByte(s, '.')
+	if i <= 0 {
+		return "", "", errFeedNotFound
+	}
+
+	name, format = s[:i], s[i+1:]
+	if format != "atom" && format != "rss" {
+		return "", "", errFeedNotFound
+	}
+	return name, format, nil
+}
+
+func parseFeedLimit(app *bunapp.App, s string) (int, error) {
+	cfg := &app.Config().Feeds
+
+	maxLimit := cfg.MaxLimit
+	if maxLimit == 0 {
+		maxLimit = 100
+	}
+
+	if s == "" {
+		if cfg.Limit > 0 && cfg.Limit < maxLimit {
+			return cfg.Limit, nil
+		}
+		return maxLimit, nil
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 || n > maxLimit {
+		return 0, httperror.BadRequest("invalid_limit", "limit must be between 1 and %d", maxLimit)
+	}
+	return n, nil
+}
+
+func encodeXML(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	if err := xml.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+//------------------------------------------------------------------------------
+
+// publicURL returns the absolute URL of the path on the frontend.
+func publicURL(app *bunapp.App, path string) string {
+	return strings.TrimSuffix(app.Config().PublicURL, "/") + path
+}
+
+func articleURL(app *bunapp.App, slug string) string {
+	return publicURL(app, "/article/"+url.PathEscape(slug))
+}
+
+func profileURL(app *bunapp.App, username string) string {
+	return publicURL(app, "/profile/"+url.PathEscape(username))
+}
+
+func tagURL(app *bunapp.App, tag string) string {
+	return publicURL(app, "/tag/"+url.PathEscape(tag))
+}
+
+// articleURN is a permanent id of the article, which survives renames.
+func articleURN(article *Article) string {
+	return "urn:conduit:article:" + strconv.FormatUint(article.ID, 10)
+}
+
+//------------------------------------------------------------------------------
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Links      []atomLink     `xml:"link"`
+	Author     atomPerson     `xml:"author"`
+	Categories []atomCategory `xml:"category"`
+	Summary    string         `xml:"summary,omitempty"`
+	Content    atomText       `xml:"content"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+	URI  string `xml:"uri,omitempty"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+func newAtomFeed(
+	app *bunapp.App, meta feedMeta, self string, updated time.Time, articles []*Article,
+) *atomFeed {
+	feed := &atomFeed{
+		ID:      self,
+		Title:   meta.title,
+		Updated: atomTime(updated),
+		Links: []atomLink{
+			{Rel: "self", Type: "application/atom+xml", Href: self},
+			{Rel: "alternate", Type: "text/html", Href: meta.link},
+		},
+		Entries: make([]atomEntry, 0, len(articles)),
+	}
+
+	for _, article := range articles {
+		entry := atomEntry{
+			ID:        articleURN(article),
+			Title:     article.Title,
+			Published: atomTime(article.CreatedAt),
+			Updated:   atomTime(article.UpdatedAt),
+			Links: []atomLink{
+				{Rel: "alternate", Type: "text/html", Href: articleURL(app, article.Slug)},
+			},
+			Summary: article.Excerpt,
+			Content: atomText{Type: "html", Body: article.BodyHTML},
+		}
+		if article.Author != nil {
+			entry.Author = atomPerson{
+				Name: article.Author.Username,
+				URI:  profileURL(app, article.Author.Username),
+			}
+		}
+		for _, tag := range article.TagList {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	return feed
+}
+
+// atomTime formats RFC 3339 timestamps. Empty feeds use the Unix epoch,
+// so they don't change on every request.
+func atomTime(tm time.Time) string {
+	if tm.IsZero() {
+		tm = time.Unix(0, 0)
+	}
+	return tm.UTC().Format(time.RFC3339)
+}
+
+//------------------------------------------------------------------------------
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	AtomLink      rssSelf   `xml:"http://www.w3.org/2005/Atom link"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssSelf struct {
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+	Href string `xml:"href,attr"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        rssGUID  `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Creator     string   `xml:"http://purl.org/dc/elements/1.1/ creator,omitempty"`
+	Categories  []string `xml:"category"`
+	Description string   `xml:"description"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+func newRSSFeed(
+	app *bunapp.App, meta feedMeta, self string, updated time.Time, articles []*Article,
+) *rssFeed {
+	feed := &rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:       meta.title,
+			Link:        meta.link,
+			Description: meta.title,
+			AtomLink:    rssSelf{Rel: "self", Type: "application/rss+xml", Href: self},
+			Items:       make([]rssItem, 0, len(articles)),
+		},
+	}
+	if !updated.IsZero() {
+		feed.Channel.LastBuildDate = updated.UTC().Format(time.RFC1123Z)
+	}
+
+	for _, article := range articles {
+		item := rssItem{
+			Title:       article.Title,
+			Link:        articleURL(app, article.Slug),
+			GUID:        rssGUID{Value: articleURN(article)},
+			PubDate:     article.CreatedAt.UTC().Format(time.RFC1123Z),
+			Categories:  article.TagList,
+			Description: article.BodyHTML,
+		}
+		if article.Author != nil {
+			item.Creator = article.Author.Username
+		}
+		feed.Channel.Items = append(feed.Channel.Items, item)
+	}
+
+	return feed
+}
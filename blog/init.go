@@ -2,8 +2,10 @@ package blog
 
 import (
 	"context"
+	"net/http"
 
 	"github.com/uptrace/bun-realworld-app/bunapp"
+	"github.com/uptrace/bun-realworld-app/httputil/openapi"
 	"github.com/uptrace/bun-realworld-app/org"
 )
 
@@ -13,29 +15,202 @@ func init() {
 		tagHandler := NewTagHandler(app)
 		articleHandler := NewArticleHandler(app)
 		commentHandler := NewCommentHandler(app)
+		feedHandler := NewFeedHandler(app)
+		sitemapHandler := NewSitemapHandler(app)
+		bookmarkHandler := NewBookmarkHandler(app)
+		reportHandler := NewReportHandler(app)
 
-		g := app.APIRouter().WithMiddleware(middleware.User)
+		app.Site().GET("/feeds/:feed", feedHandler.Articles,
+			openapi.Summary("Atom or RSS feed of all articles or articles by an author"),
+			openapi.Tags("feeds"),
+			openapi.Query("limit", "Max number of articles"),
+			openapi.ReturnsBinary(http.StatusOK, "application/atom+xml"),
+			openapi.ReturnsBinary(http.StatusOK, "application/rss+xml"),
+			openapi.Returns(http.StatusNotModified, nil))
+		app.Site().GET("/feeds/tags/:feed", feedHandler.Tag,
+			openapi.Summary("Atom or RSS feed of articles with a tag"),
+			openapi.Tags("feeds"),
+			openapi.Query("limit", "Max number of articles"),
+			openapi.ReturnsBinary(http.StatusOK, "application/atom+xml"),
+			openapi.ReturnsBinary(http.StatusOK, "application/rss+xml"),
+			openapi.Returns(http.StatusNotModified, nil))
 
-		g.GET("/tags/", tagHandler.List)
+		app.Site().GET("/robots.txt", sitemapHandler.Robots,
+			openapi.Summary("Robots exclusion rules"),
+			openapi.Tags("seo"),
+			openapi.ReturnsBinary(http.StatusOK, "text/plain"))
+		app.Site().GET("/sitemap.xml", sitemapHandler.Sitemap,
+			openapi.Summary("Sitemap or sitemap index when there are too many articles"),
+			openapi.Tags("seo"),
+			openapi.ReturnsBinary(http.StatusOK, "application/xml"))
+		app.Site().GET("/sitemaps/:name", sitemapHandler.Shard,
+			openapi.Summary("Sitemap shard, e.g. articles-2.xml"),
+			openapi.Tags("seo"),
+			openapi.ReturnsBinary(http.StatusOK, "application/xml"))
 
-		g.GET("/articles", articleHandler.List)
-		g.GET("/articles/feed", articleHandler.Feed)
-		g.GET("/articles/:slug", articleHandler.Show)
+		g := app.API().WithMiddleware(middleware.User, openapi.Tags("articles"))
 
-		g.GET("/articles/:slug/comments", commentHandler.List)
-		g.GET("/articles/:slug/comments/:id", commentHandler.Show)
+		g.GET("/tags/", tagHandler.List,
+			openapi.Summary("List tags"),
+			openapi.Tags("tags"),
+			openapi.Query("window", "Return trending tags for the window, e.g. 7d"),
+			openapi.Query("limit", "Max number of tags"),
+			openapi.Returns(http.StatusOK, TagsResponse{}),
+			openapi.Returns(http.StatusNotModified, nil))
+		g.GET("/tags/:tag", tagHandler.Show,
+			openapi.Summary("Get a tag"),
+			openapi.Tags("tags"),
+			openapi.Returns(http.StatusOK, TagResponse{}),
+			openapi.Returns(http.StatusNotModified, nil))
 
-		g = g.WithMiddleware(middleware.MustUser)
+		g.GET("/articles", articleHandler.List,
+			openapi.Summary("List articles"),
+			openapi.Query("tag", "Filter by tag"),
+			openapi.Query("author", "Filter by author username"),
+			openapi.Query("favorited", "Filter by username of a user who favorited the article"),
+			openapi.Returns(http.StatusOK, ArticlesResponse{}),
+			openapi.Returns(http.StatusNotModified, nil))
+		g.GET("/articles/feed", articleHandler.Feed,
+			openapi.Summary("List articles by followed users"),
+			openapi.Returns(http.StatusOK, ArticlesResponse{}))
+		g.GET("/articles/:slug", articleHandler.Show,
+			openapi.Summary("Get an article"),
+			openapi.Returns(http.StatusOK, ArticleResponse{}),
+			openapi.Returns(http.StatusMovedPermanently, RedirectResponse{}),
+			openapi.Returns(http.StatusNotModified, nil))
 
-		g.POST("/articles", articleHandler.Create)
-		g.PUT("/articles/:slug", articleHandler.Update)
-		g.DELETE("/articles/:slug", articleHandler.Delete)
+		g.GET("/articles/:slug/comments", commentHandler.List,
+			openapi.Summary("List comments"),
+			openapi.Tags("comments"),
+			openapi.Returns(http.StatusOK, CommentsResponse{}))
+		g.GET("/articles/:slug/comments/:id", commentHandler.Show,
+			openapi.Summary("Get a comment"),
+			openapi.Tags("comments"),
+			openapi.Returns(http.StatusOK, CommentResponse{}))
 
-		g.POST("/articles/:slug/favorite", articleHandler.Favorite)
-		g.DELETE("/articles/:slug/favorite", articleHandler.Unfavorite)
+		g = g.WithMiddleware(middleware.MustUser, openapi.Security("token"))
 
-		g.POST("/articles/:slug/comments", commentHandler.Create)
-		g.DELETE("/articles/:slug/comments/:id", commentHandler.Delete)
+		{
+			g := g.WithMiddleware(middleware.RequireScope(org.ScopeArticlesWrite))
+
+			g.POST("/articles", articleHandler.Create,
+				openapi.Summary("Create an article"),
+				openapi.Request(ArticleRequest{}),
+				openapi.Returns(http.StatusOK, ArticleResponse{}))
+			g.PUT("/articles/:slug", articleHandler.Update,
+				openapi.Summary("Update an article"),
+				openapi.Request(ArticleRequest{}),
+				openapi.Returns(http.StatusOK, ArticleResponse{}))
+			g.DELETE("/articles/:slug", articleHandler.Delete,
+				openapi.Summary("Delete an article"),
+				openapi.Returns(http.StatusOK, nil))
+		}
+
+		{
+			g := g.WithMiddleware(middleware.RequireScope(org.ScopeFavoritesWrite))
+
+			g.POST("/articles/:slug/favorite", articleHandler.Favorite,
+				openapi.Summary("Favorite an article"),
+				openapi.Returns(http.StatusOK, ArticleResponse{}))
+			g.DELETE("/articles/:slug/favorite", articleHandler.Unfavorite,
+				openapi.Summary("Unfavorite an article"),
+				openapi.Returns(http.StatusOK, ArticleResponse{}))
+		}
+
+		g.GET("/user/bookmarks", bookmarkHandler.List,
+			openapi.Summary("List bookmarked articles"),
+			openapi.Tags("bookmarks"),
+			openapi.Query("folder", "Filter by folder id"),
+			openapi.Query("limit", "Max number of articles"),
+			openapi.Query("offset", "Number of articles to skip"),
+			openapi.Returns(http.StatusOK, ArticlesResponse{}))
+		g.GET("/user/bookmarks/folders", bookmarkHandler.Folders,
+			openapi.Summary("List bookmark folders"),
+			openapi.Tags("bookmarks"),
+			openapi.Returns(http.StatusOK, BookmarkFoldersResponse{}))
+
+		{
+			g := g.WithMiddleware(middleware.RequireScope(org.ScopeBookmarksWrite), openapi.Tags("bookmarks"))
+
+			g.POST("/articles/:slug/bookmark", bookmarkHandler.Bookmark,
+				openapi.Summary("Bookmark an article or move the bookmark to a folder"),
+				openapi.Request(BookmarkRequest{}),
+				openapi.Returns(http.StatusOK, ArticleResponse{}))
+			g.DELETE("/articles/:slug/bookmark", bookmarkHandler.Unbookmark,
+				openapi.Summary("Remove a bookmark"),
+				openapi.Returns(http.StatusOK, ArticleResponse{}))
+			g.POST("/user/bookmarks/folders", bookmarkHandler.CreateFolder,
+				openapi.Summary("Create a bookmark folder"),
+				openapi.Request(BookmarkFolderRequest{}),
+				openapi.Returns(http.StatusOK, BookmarkFolderResponse{}))
+			g.DELETE("/user/bookmarks/folders/:id", bookmarkHandler.DeleteFolder,
+				openapi.Summary("Delete a bookmark folder"),
+				openapi.Returns(http.StatusOK, nil))
+		}
+
+		{
+			g := g.WithMiddleware(middleware.RequireScope(org.ScopeCommentsWrite), openapi.Tags("comments"))
+
+			g.POST("/articles/:slug/comments", commentHandler.Create,
+				openapi.Summary("Add a comment"),
+				openapi.Request(CommentRequest{}),
+				openapi.Returns(http.StatusOK, CommentResponse{}))
+			g.DELETE("/articles/:slug/comments/:id", commentHandler.Delete,
+				openapi.Summary("Delete a comment"),
+				openapi.Returns(http.StatusOK, nil))
+		}
+
+		{
+			g := g.WithMiddleware(middleware.RequireScope(org.ScopeReportsWrite), openapi.Tags("moderation"))
+
+			g.POST("/articles/:slug/report", reportHandler.Article,
+				openapi.Summary("Report an article"),
+				openapi.Request(ReportRequest{}),
+				openapi.Returns(http.StatusOK, ReportResponse{}))
+			g.POST("/articles/:slug/comments/:id/report", reportHandler.Comment,
+				openapi.Summary("Report a comment"),
+				openapi.Request(ReportRequest{}),
+				openapi.Returns(http.StatusOK, ReportResponse{}))
+			g.POST("/profiles/:username/report", reportHandler.Profile,
+				openapi.Summary("Report a user"),
+				openapi.Request(ReportRequest{}),
+				openapi.Returns(http.StatusOK, ReportResponse{}))
+		}
+
+		{
+			g := g.WithMiddleware(middleware.MustSession).
+				WithMiddleware(middleware.MustModerator, openapi.Tags("moderation"))
+
+			g.GET("/moderation/reports", reportHandler.List,
+				openapi.Summary("List reports in the moderation queue"),
+				openapi.Query("status", "Filter by status, open by default"),
+				openapi.Query("limit", "Max number of reports"),
+				openapi.Query("offset", "Number of reports to skip"),
+				openapi.Returns(http.StatusOK, ReportsResponse{}))
+			g.POST("/moderation/reports/:id/resolve", reportHandler.Resolve,
+				openapi.Summary("Resolve a report with a moderation action"),
+				openapi.Request(ResolutionRequest{}),
+				openapi.Returns(http.StatusOK, ReportResponse{}))
+		}
+
+		g = g.WithMiddleware(middleware.MustSession)
+		g = g.WithMiddleware(middleware.MustAdmin, openapi.Tags("admin"))
+
+		g.PUT("/admin/tags/:tag", tagHandler.Update,
+			openapi.Summary("Update a tag description"),
+			openapi.Request(TagRequest{}),
+			openapi.Returns(http.StatusOK, TagResponse{}))
+		g.POST("/admin/tags/:tag/aliases", tagHandler.AddAlias,
+			openapi.Summary("Add a tag alias"),
+			openapi.Request(TagAliasRequest{}),
+			openapi.Returns(http.StatusOK, TagResponse{}))
+		g.DELETE("/admin/tags/:tag/aliases/:alias", tagHandler.DeleteAlias,
+			openapi.Summary("Delete a tag alias"),
+			openapi.Returns(http.StatusOK, TagResponse{}))
+		g.POST("/admin/tags/:tag/merge", tagHandler.Merge,
+			openapi.Summary("Merge tags into the tag"),
+			openapi.Request(TagMergeRequest{}),
+			openapi.Returns(http.StatusOK, TagResponse{}))
 
 		return nil
 	})
@@ -0,0 +1,99 @@
+package blog
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"time"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/uptrace/bun-realworld-app/bunapp"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+// Rendered HTML is keyed by the content hash, so it never needs invalidation.
+const markdownCacheTTL = 24 * time.Hour
+
+var (
+	// Raw HTML is allowed by the renderer and then filtered by the sanitizer,
+	// so safe inline HTML like <sup> survives.
+	markdownParser = goldmark.New(
+		goldmark.WithExtensions(
+			// Same as extension.GFM, but tables use the align attribute
+			// instead of the style attribute that the sanitizer removes.
+			extension.NewTable(extension.WithTableCellAlignMethod(extension.TableCellAlignAttribute)),
+			extension.Strikethrough,
+			extension.Linkify,
+			extension.TaskList,
+		),
+		// Heading ids are referenced by the table of contents.
+		goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+		goldmark.WithRendererOptions(html.WithUnsafe()),
+	)
+
+	sanitizer          = newSanitizer(false)
+	highlightSanitizer = newSanitizer(true)
+)
+
+// newSanitizer returns the allowlist for user content. With highlight,
+// code blocks keep language-* classes for client-side syntax highlighting.
+func newSanitizer(highlight bool) *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+
+	// GFM tables and task lists.
+	p.AllowAttrs("align").Matching(regexp.MustCompile(`^(left|center|right)$`)).
+		OnElements("th", "td")
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").OnElements("input")
+
+	if highlight {
+		p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+#-]+$`)).
+			OnElements("code")
+	}
+	return p
+}
+
+// renderMarkdown converts CommonMark with GFM extensions to sanitized HTML.
+func renderMarkdown(app *bunapp.App, body string) string {
+	if body == "" {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(body))
+	key := "blog:markdown:" + hex.EncodeToString(sum[:16])
+
+	if v, ok := app.Cache().Get(key); ok {
+		return v.(string)
+	}
+
+	var buf bytes.Buffer
+	if err := markdownParser.Convert([]byte(body), &buf); err != nil {
+		// Rendering only fails when writing to the buffer fails.
+		return ""
+	}
+
+	policy := sanitizer
+	if app.Config().Markdown.Highlight {
+		policy = highlightSanitizer
+	}
+	out := string(policy.SanitizeBytes(buf.Bytes()))
+
+	app.Cache().Set(key, out, markdownCacheTTL)
+	return out
+}
+
+func renderArticles(app *bunapp.App, articles ...*Article) {
+	for _, article := range articles {
+		article.BodyHTML = renderMarkdown(app, article.Body)
+	}
+}
+
+func renderComments(app *bunapp.App, comments ...*Comment) {
+	for _, comment := range comments {
+		comment.BodyHTML = renderMarkdown(app, comment.Body)
+	}
+}
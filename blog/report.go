@@ -0,0 +1,338 @@
+package blog
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun-realworld-app/bunapp"
+	"github.com/uptrace/bun-realworld-app/httputil/httperror"
+	"github.com/uptrace/bun-realworld-app/org"
+)
+
+// Report target types.
+const (
+	TargetArticle = "article"
+	TargetComment = "comment"
+	TargetProfile = "profile"
+)
+
+// Report statuses. Resolved reports have the status of the moderation action.
+const (
+	ReportOpen      = "open"
+	ReportDismissed = "dismissed"
+	ReportHidden    = "hidden"
+	ReportDeleted   = "deleted"
+	ReportSuspended = "suspended"
+)
+
+// Moderation actions.
+const (
+	ActionDismiss = "dismiss"
+	ActionHide    = "hide"
+	ActionDelete  = "delete"
+	ActionSuspend = "suspend"
+)
+
+const maxReportReasonLen = 1000
+
+var (
+	ErrReportNotFound = httperror.NotFound("report not found")
+	ErrReportExists   = httperror.New(http.StatusConflict,
+		"already_reported", "you have already reported this")
+	ErrReportResolved = httperror.New(http.StatusConflict,
+		"report_resolved", "the report is already resolved")
+)
+
+var actionStatuses = map[string]string{
+	ActionDismiss: ReportDismissed,
+	ActionHide:    ReportHidden,
+	ActionDelete:  ReportDeleted,
+	ActionSuspend: ReportSuspended,
+}
+
+// Report is a complaint about an article, a comment or a profile.
+// AuthorID is the author of the reported content or the reported user.
+type Report struct {
+	bun.BaseModel `bun:"reports,alias:r"`
+
+	ID         uint64 `json:"id"`
+	TargetType string `json:"targetType"`
+	TargetID   uint64 `json:"targetId"`
+	Reason     string `json:"reason"`
+	Status     string `json:"status"`
+
+	Reporter   *org.Profile `json:"reporter" bun:"rel:belongs-to"`
+	ReporterID uint64       `json:"-"`
+	Author     *org.Profile `json:"author" bun:"rel:belongs-to"`
+	AuthorID   uint64       `json:"-"`
+
+	ResolvedByID uint64 `json:"-" bun:",nullzero"`
+
+	CreatedAt  time.Time `json:"createdAt"`
+	ResolvedAt time.Time `json:"resolvedAt,omitempty" bun:",nullzero"`
+}
+
+// ReportService files reports and resolves them for moderators.
+type ReportService struct {
+	app *bunapp.App
+}
+
+func NewReportService(app *bunapp.App) *ReportService {
+	return &ReportService{
+		app: app,
+	}
+}
+
+func (s *ReportService) ReportArticle(
+	ctx context.Context, user *org.User, slug, reason string,
+) (*Report, error) {
+	article, err := SelectArticle(ctx, s.app, slug)
+	if err != nil {
+		return nil, articleErr(err)
+	}
+	return s.create(ctx, user, &Report{
+		TargetType: TargetArticle,
+		TargetID:   article.ID,
+		AuthorID:   article.AuthorID,
+		Reason:     reason,
+	})
+}
+
+func (s *ReportService) ReportComment(
+	ctx context.Context, user *org.User, slug string, id uint64, reason string,
+) (*Report, error) {
+	article, err := SelectArticle(ctx, s.app, slug)
+	if err != nil {
+		return nil, articleErr(err)
+	}
+
+	comment := new(Comment)
+	if err := s.app.IDB(ctx).NewSelect().
+		Model(comment).
+		Where("id = ?", id).
+		Where("article_id = ?", article.ID).
+		Scan(ctx); err != nil {
+		return nil, commentErr(err)
+	}
+
+	return s.create(ctx, user, &Report{
+		TargetType: TargetComment,
+		TargetID:   comment.ID,
+		AuthorID:   comment.AuthorID,
+		Reason:     reason,
+	})
+}
+
+func (s *ReportService) ReportProfile(
+	ctx context.Context, user *org.User, username, reason string,
+) (*Report, error) {
+	reported, err := org.SelectUserByUsername(ctx, s.app, username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, org.ErrUserNotFound
+		}
+		return nil, err
+	}
+	return s.create(ctx, user, &Report{
+		TargetType: TargetProfile,
+		TargetID:   reported.ID,
+		AuthorID:   reported.ID,
+		Reason:     reason,
+	})
+}
+
+func (s *ReportService) create(ctx context.Context, user *org.User, report *Report) (*Report, error) {
+	report.Reason = strings.TrimSpace(report.Reason)
+	if report.Reason == "" || utf8.RuneCountInString(report.Reason) > maxReportReasonLen {
+		return nil, httperror.New(http.StatusUnprocessableEntity, "invalid_report",
+			"reason must have from 1 to %d characters", maxReportReasonLen)
+	}
+	if report.AuthorID == user.ID {
+		return nil, httperror.New(http.StatusUnprocessableEntity, "invalid_report",
+			"you can't report yourself")
+	}
+
+	report.ReporterID = user.ID
+	report.Status = ReportOpen
+	report.CreatedAt = s.app.Clock().Now()
+
+	res, err := s.app.IDB(ctx).NewInsert().
+		Model(report).
+		On("CONFLICT (reporter_id, target_type, target_id) WHERE status = 'open' DO NOTHING").
+		Returning("id").
+		Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, ErrReportExists
+	}
+	return s.get(ctx, report.ID)
+}
+
+// List returns a page of the reports with the status, oldest first, and the total number of reports.
+func (s *ReportService) List(ctx context.Context, status string, limit, offset int) ([]*Report, int, error) {
+	reports := make([]*Report, 0)
+	count, err := s.app.IDB(ctx).NewSelect().
+		Model(&reports).
+		Relation("Reporter").
+		Relation("Author").
+		Where("r.status = ?", status).
+		OrderExpr("r.created_at ASC, r.id ASC").
+		Limit(limit).
+		Offset(offset).
+		ScanAndCount(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	return reports, count, nil
+}
+
+// Resolve applies the moderation action to the reported content and resolves
+// all open reports about it. The reporters are notified about the outcome.
+func (s *ReportService) Resolve(
+	ctx context.Context, moderator *org.User, id uint64, action string,
+) (*Report, error) {
+	status, ok := actionStatuses[action]
+	if !ok {
+		return nil, httperror.New(http.StatusUnprocessableEntity, "invalid_action",
+			"action must be one of dismiss, hide, delete or suspend")
+	}
+
+	var report Report
+	var reporterIDs []uint64
+
+	if err := s.app.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if err := tx.NewSelect().
+			Model(&report).
+			Where("id = ?", id).
+			For("UPDATE").
+			Scan(ctx); err != nil {
+			if err == sql.ErrNoRows {
+				return ErrReportNotFound
+			}
+			return err
+		}
+		if report.Status != ReportOpen {
+			return ErrReportResolved
+		}
+
+		if err := s.apply(ctx, tx, &report, action); err != nil {
+			return err
+		}
+
+		reporterIDs = reporterIDs[:0]
+		if err := tx.NewSelect().
+			Model((*Report)(nil)).
+			Column("reporter_id").
+			Where("target_type = ?", report.TargetType).
+			Where("target_id = ?", report.TargetID).
+			Where("status = ?", ReportOpen).
+			Scan(ctx, &reporterIDs); err != nil {
+			return err
+		}
+
+		if _, err := tx.NewUpdate().
+			Model((*Report)(nil)).
+			Set("status = ?", status).
+			Set("resolved_by_id = ?", moderator.ID).
+			Set("resolved_at = ?", s.app.Clock().Now()).
+			Where("target_type = ?", report.TargetType).
+			Where("target_id = ?", report.TargetID).
+			Where("status = ?", ReportOpen).
+			Exec(ctx); err != nil {
+			return err
+		}
+
+		msg := resolutionMessage(report.TargetType, status)
+		for _, reporterID := range reporterIDs {
+			if err := org.Notify(ctx, s.app, reporterID, org.NotificationReportResolved, msg); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return s.get(ctx, id)
+}
+
+func (s *ReportService) apply(ctx context.Context, tx bun.Tx, report *Report, action string) error {
+	if report.TargetType == TargetProfile && (action == ActionHide || action == ActionDelete) {
+		return httperror.New(http.StatusUnprocessableEntity, "invalid_action",
+			"profiles can only be dismissed or suspended")
+	}
+
+	switch action {
+	case ActionHide:
+		q := tx.NewUpdate().
+			Set("hidden_at = ?", s.app.Clock().Now()).
+			Where("id = ?", report.TargetID).
+			Where("hidden_at IS NULL")
+		if report.TargetType != TargetArticle {
+			_, err := q.Model((*Comment)(nil)).Exec(ctx)
+			return err
+		}
+		if _, err := q.Model((*Article)(nil)).Exec(ctx); err != nil {
+			return err
+		}
+		return s.app.Invalidate(ctx, popularTagsCacheKey)
+	case ActionDelete:
+		if report.TargetType == TargetArticle {
+			if _, err := tx.NewDelete().
+				Model((*Article)(nil)).
+				Where("id = ?", report.TargetID).
+				Exec(ctx); err != nil {
+				return err
+			}
+			return s.app.Invalidate(ctx, popularTagsCacheKey)
+		}
+		_, err := tx.NewDelete().
+			Model((*Comment)(nil)).
+			Where("id = ?", report.TargetID).
+			Exec(ctx)
+		return err
+	case ActionSuspend:
+		return org.NewUserService(s.app).Suspend(ctx, report.AuthorID)
+	}
+	return nil
+}
+
+func (s *ReportService) get(ctx context.Context, id uint64) (*Report, error) {
+	report := new(Report)
+	if err := s.app.IDB(ctx).NewSelect().
+		Model(report).
+		Relation("Reporter").
+		Relation("Author").
+		Where("r.id = ?", id).
+		Scan(ctx); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrReportNotFound
+		}
+		return nil, err
+	}
+	return report, nil
+}
+
+func resolutionMessage(targetType, status string) string {
+	switch status {
+	case ReportHidden:
+		return fmt.Sprintf("Thank you for your report. The %s was hidden.", targetType)
+	case ReportDeleted:
+		return fmt.Sprintf("Thank you for your report. The %s was deleted.", targetType)
+	case ReportSuspended:
+		return "Thank you for your report. The author was suspended."
+	default:
+		return fmt.Sprintf("Thank you for your report. We reviewed the %s and took no action.", targetType)
+	}
+}
@@ -0,0 +1,152 @@
+package blog
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/uptrace/bun-realworld-app/bunapp"
+	"github.com/uptrace/bun-realworld-app/httputil"
+	"github.com/uptrace/bun-realworld-app/httputil/httperror"
+	"github.com/uptrace/bun-realworld-app/org"
+	"github.com/uptrace/bunrouter"
+)
+
+type ReportHandler struct {
+	app     *bunapp.App
+	service *ReportService
+}
+
+func NewReportHandler(app *bunapp.App) ReportHandler {
+	return ReportHandler{
+		app:     app,
+		service: NewReportService(app),
+	}
+}
+
+func (h ReportHandler) Article(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+
+	reason, err := decodeReportReason(w, req)
+	if err != nil {
+		return err
+	}
+
+	report, err := h.service.ReportArticle(ctx, org.UserFromContext(ctx), req.Param("slug"), reason)
+	if err != nil {
+		return err
+	}
+
+	return bunrouter.JSON(w, bunrouter.H{
+		"report": report,
+	})
+}
+
+func (h ReportHandler) Comment(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+
+	id, err := req.Params().Uint64("id")
+	if err != nil {
+		return ErrCommentNotFound
+	}
+
+	reason, err := decodeReportReason(w, req)
+	if err != nil {
+		return err
+	}
+
+	report, err := h.service.ReportComment(ctx, org.UserFromContext(ctx), req.Param("slug"), id, reason)
+	if err != nil {
+		return err
+	}
+
+	return bunrouter.JSON(w, bunrouter.H{
+		"report": report,
+	})
+}
+
+func (h ReportHandler) Profile(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+
+	reason, err := decodeReportReason(w, req)
+	if err != nil {
+		return err
+	}
+
+	report, err := h.service.ReportProfile(ctx, org.UserFromContext(ctx), req.Param("username"), reason)
+	if err != nil {
+		return err
+	}
+
+	return bunrouter.JSON(w, bunrouter.H{
+		"report": report,
+	})
+}
+
+// List returns the moderation queue. Open reports are listed by default.
+func (h ReportHandler) List(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+	query := req.URL.Query()
+
+	status := query.Get("status")
+	switch status {
+	case "":
+		status = ReportOpen
+	case ReportOpen, ReportDismissed, ReportHidden, ReportDeleted, ReportSuspended:
+	default:
+		return httperror.BadRequest("invalid_status", "unknown report status: %q", status)
+	}
+
+	limit, offset, err := parsePage(query.Get("limit"), query.Get("offset"))
+	if err != nil {
+		return err
+	}
+
+	reports, count, err := h.service.List(ctx, status, limit, offset)
+	if err != nil {
+		return err
+	}
+
+	return bunrouter.JSON(w, bunrouter.H{
+		"reports":      reports,
+		"reportsCount": count,
+	})
+}
+
+func (h ReportHandler) Resolve(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+
+	id, err := req.Params().Uint64("id")
+	if err != nil {
+		return ErrReportNotFound
+	}
+
+	var in ResolutionRequest
+	if err := httputil.UnmarshalJSON(w, req, &in, 10<<kb); err != nil {
+		return err
+	}
+
+	if in.Resolution == nil {
+		return errors.New(`JSON field "resolution" is required`)
+	}
+
+	report, err := h.service.Resolve(ctx, org.UserFromContext(ctx), id, in.Resolution.Action)
+	if err != nil {
+		return err
+	}
+
+	return bunrouter.JSON(w, bunrouter.H{
+		"report": report,
+	})
+}
+
+func decodeReportReason(w http.ResponseWriter, req bunrouter.Request) (string, error) {
+	var in ReportRequest
+	if err := httputil.UnmarshalJSON(w, req, &in, 10<<kb); err != nil {
+		return "", err
+	}
+
+	if in.Report == nil {
+		return "", errors.New(`JSON field "report" is required`)
+	}
+	return in.Report.Reason, nil
+}
@@ -0,0 +1,218 @@
+package blog
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun-realworld-app/bunapp"
+	"github.com/uptrace/bun-realworld-app/httputil/httperror"
+	"github.com/uptrace/bunrouter"
+)
+
+const (
+	sitemapNS = "http://www.sitemaps.org/schemas/sitemap/0.9"
+	// maxSitemapURLs is the limit set by the sitemaps protocol.
+	maxSitemapURLs = 50000
+)
+
+var errSitemapNotFound = httperror.NotFound("sitemap not found")
+
+// SitemapHandler serves /sitemap.xml and /robots.txt. When there are more
+// articles than fit in a sitemap, /sitemap.xml is an index of shards
+// served at /sitemaps/articles-N.xml.
+type SitemapHandler struct {
+	app *bunapp.App
+}
+
+func NewSitemapHandler(app *bunapp.App) SitemapHandler {
+	return SitemapHandler{
+		app: app,
+	}
+}
+
+func (h SitemapHandler) Robots(w http.ResponseWriter, req bunrouter.Request) error {
+	h.setCacheControl(w)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, err := fmt.Fprintf(w, "User-agent: *\nDisallow: /api/\n\nSitemap: %s\n",
+		publicURL(h.app, "/sitemap.xml"))
+	return err
+}
+
+func (h SitemapHandler) Sitemap(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+
+	var count int
+	if err := h.app.IDB(ctx).NewSelect().
+		Model((*Article)(nil)).
+		ColumnExpr("count(*)").
+		Where("hidden_at IS NULL").
+		Scan(ctx, &count); err != nil {
+		return err
+	}
+
+	if count <= h.shardSize() {
+		return h.writeURLSet(ctx, w, 1)
+	}
+	return h.writeIndex(ctx, w)
+}
+
+func (h SitemapHandler) Shard(w http.ResponseWriter, req bunrouter.Request) error {
+	name := req.Param("name")
+	if !strings.HasPrefix(name, "articles-") || !strings.HasSuffix(name, ".xml") {
+		return errSitemapNotFound
+	}
+
+	shard, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, "articles-"), ".xml"))
+	if err != nil || shard < 1 {
+		return errSitemapNotFound
+	}
+
+	return h.writeURLSet(req.Context(), w, shard)
+}
+
+// writeURLSet streams the articles of the shard, so memory use does not
+// depend on the number of articles.
+func (h SitemapHandler) writeURLSet(ctx context.Context, w http.ResponseWriter, shard int) error {
+	db := h.app.IDB(ctx)
+	size := h.shardSize()
+
+	if shard > 1 {
+		exists, err := db.NewSelect().
+			Model((*Article)(nil)).
+			Column("id").
+			Where("hidden_at IS NULL").
+			OrderExpr("id ASC").
+			Limit(1).
+			Offset((shard - 1) * size).
+			Exists(ctx)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return errSitemapNotFound
+		}
+	}
+
+	rows, err := db.NewSelect().
+		Model((*Article)(nil)).
+		Column("slug", "updated_at").
+		Where("hidden_at IS NULL").
+		OrderExpr("id ASC").
+		Limit(size).
+		Offset((shard - 1) * size).
+		Rows(ctx)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	type sitemapURL struct {
+		XMLName xml.Name `xml:"url"`
+		Loc     string   `xml:"loc"`
+		LastMod string   `xml:"lastmod,omitempty"`
+	}
+
+	h.writeHeader(w, "urlset")
+	enc := xml.NewEncoder(w)
+
+	if shard == 1 {
+		if err := enc.Encode(sitemapURL{Loc: publicURL(h.app, "/")}); err != nil {
+			return err
+		}
+	}
+
+	for rows.Next() {
+		var slug string
+		var updatedAt time.Time
+		if err := rows.Scan(&slug, &updatedAt); err != nil {
+			return err
+		}
+
+		if err := enc.Encode(sitemapURL{
+			Loc:     articleURL(h.app, slug),
+			LastMod: updatedAt.UTC().Format(time.RFC3339),
+		}); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if err := enc.Flush(); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "</urlset>\n")
+	return err
+}
+
+func (h SitemapHandler) writeIndex(ctx context.Context, w http.ResponseWriter) error {
+	var shards []struct {
+		Shard   int
+		LastMod time.Time
+	}
+	db := h.app.IDB(ctx)
+	subq := db.NewSelect().
+		Model((*Article)(nil)).
+		ColumnExpr("(row_number() OVER (ORDER BY a.id) - 1) / ? AS shard", h.shardSize()).
+		Column("updated_at").
+		Where("a.hidden_at IS NULL")
+
+	if err := db.NewSelect().
+		TableExpr("(?) AS s", subq).
+		ColumnExpr("s.shard + 1 AS shard").
+		ColumnExpr("max(s.updated_at) AS last_mod").
+		GroupExpr("s.shard").
+		OrderExpr("s.shard ASC").
+		Scan(ctx, &shards); err != nil {
+		return err
+	}
+
+	type sitemap struct {
+		XMLName xml.Name `xml:"sitemap"`
+		Loc     string   `xml:"loc"`
+		LastMod string   `xml:"lastmod"`
+	}
+
+	h.writeHeader(w, "sitemapindex")
+	enc := xml.NewEncoder(w)
+	for _, s := range shards {
+		if err := enc.Encode(sitemap{
+			Loc:     publicURL(h.app, fmt.Sprintf("/sitemaps/articles-%d.xml", s.Shard)),
+			LastMod: s.LastMod.UTC().Format(time.RFC3339),
+		}); err != nil {
+			return err
+		}
+	}
+	if err := enc.Flush(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "</sitemapindex>\n")
+	return err
+}
+
+func (h SitemapHandler) writeHeader(w http.ResponseWriter, root string) {
+	h.setCacheControl(w)
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	fmt.Fprintf(w, "%s<%s xmlns=%q>\n", xml.Header, root, sitemapNS)
+}
+
+func (h SitemapHandler) setCacheControl(w http.ResponseWriter) {
+	maxAge := h.app.Config().HTTPCache.MaxAge
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+}
+
+func (h SitemapHandler) shardSize() int {
+	size := h.app.Config().Sitemap.ShardSize
+	if size <= 0 || size >= maxSitemapURLs {
+		// The home page is also listed in the first shard.
+		return maxSitemapURLs - 1
+	}
+	return size
+}
@@ -0,0 +1,119 @@
+package blog
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gosimple/slug"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun-realworld-app/bunapp"
+	"github.com/uptrace/bun/driver/pgdriver"
+)
+
+const (
+	maxSlugLength = 100
+	// slugMaxRetries is how many times a slug taken by a concurrent request is regenerated.
+	slugMaxRetries = 3
+)
+
+// ArticleSlugHistory keeps old slugs of renamed articles, so old URLs
+// can be redirected.
+type ArticleSlugHistory struct {
+	bun.BaseModel `bun:"article_slug_history,alias:ash"`
+
+	Slug      string `bun:",pk"`
+	ArticleID uint64
+	CreatedAt time.Time
+}
+
+// makeSlug returns the title slug that is not used by other articles,
+// now or in the past, appending -2, -3 and so on to resolve collisions.
+func makeSlug(ctx context.Context, app *bunapp.App, title string, articleID uint64) (string, error) {
+	base := slug.Make(title)
+	if len(base) > maxSlugLength {
+		base = strings.TrimRight(base[:maxSlugLength], "-")
+	}
+	if base == "" {
+		base = "article"
+	}
+
+	// Slugs only contain [a-z0-9-], so they are safe to use in LIKE patterns.
+	pattern := base + "-%"
+
+	var taken []string
+	if err := app.IDB(ctx).NewSelect().
+		Model((*Article)(nil)).
+		Column("a.slug").
+		Where("a.slug = ? OR a.slug LIKE ?", base, pattern).
+		Where("a.id != ?", articleID).
+		UnionAll(app.IDB(ctx).NewSelect().
+			Model((*ArticleSlugHistory)(nil)).
+			Column("ash.slug").
+			Where("ash.slug = ? OR ash.slug LIKE ?", base, pattern).
+			Where("ash.article_id != ?", articleID)).
+		Scan(ctx, &taken); err != nil && err != sql.ErrNoRows {
+		return "", err
+	}
+
+	used := make(map[string]bool, len(taken))
+	for _, s := range taken {
+		used[s] = true
+	}
+
+	if !used[base] {
+		return base, nil
+	}
+	for n := 2; ; n++ {
+		if s := base + "-" + strconv.Itoa(n); !used[s] {
+			return s, nil
+		}
+	}
+}
+
+// isSlugConflict reports whether a concurrent request took the same slug.
+func isSlugConflict(err error) bool {
+	var pgErr pgdriver.Error
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return pgErr.Field('C') == "23505" && pgErr.Field('n') == "articles_slug_idx"
+}
+
+// renameSlug moves the old slug to the history and frees the new one,
+// e.g. when the article gets its old title back.
+func renameSlug(ctx context.Context, app *bunapp.App, article *Article, oldSlug string) error {
+	db := app.IDB(ctx)
+
+	if _, err := db.NewDelete().
+		Model((*ArticleSlugHistory)(nil)).
+		Where("slug = ?", article.Slug).
+		Where("article_id = ?", article.ID).
+		Exec(ctx); err != nil {
+		return err
+	}
+
+	if _, err := db.NewInsert().
+		Model(&ArticleSlugHistory{
+			Slug:      oldSlug,
+			ArticleID: article.ID,
+			CreatedAt: app.Clock().Now(),
+		}).
+		Exec(ctx); err != nil {
+		return err
+	}
+	return nil
+}
+
+// retrySlugConflict runs fn again when a concurrent request took the slug.
+func retrySlugConflict(fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= slugMaxRetries || !isSlugConflict(err) {
+			return err
+		}
+	}
+}
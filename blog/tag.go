@@ -0,0 +1,104 @@
+package blog
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun-realworld-app/bunapp"
+	"github.com/uptrace/bun-realworld-app/httputil/httperror"
+)
+
+const maxTagLength = 100
+
+var ErrTagNotFound = httperror.NotFound("tag not found")
+
+type Tag struct {
+	bun.BaseModel `bun:"tags,alias:tg"`
+
+	Slug        string    `bun:",pk"`
+	Description string    `bun:",notnull"`
+	CreatedAt   time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+// TagAlias redirects an alternative spelling, e.g. golang, to the canonical tag.
+type TagAlias struct {
+	bun.BaseModel `bun:"tag_aliases,alias:ta"`
+
+	Alias string `bun:",pk"`
+	Tag   string
+}
+
+// normalizeTag lowercases the tag and replaces whitespace with dashes,
+// so "Go " and "go" are the same tag.
+func normalizeTag(tag string) (string, error) {
+	slug := strings.Join(strings.Fields(strings.ToLower(tag)), "-")
+	if slug == "" {
+		return "", httperror.New(http.StatusUnprocessableEntity,
+			"invalid_tag", "tag %q is empty", tag)
+	}
+	if utf8.RuneCountInString(slug) > maxTagLength {
+		return "", httperror.New(http.StatusUnprocessableEntity,
+			"invalid_tag", "tag must be at most %d characters long", maxTagLength)
+	}
+	return slug, nil
+}
+
+// resolveTags normalizes the tags, replaces aliases with canonical tags and
+// removes duplicates. Tags that don't exist yet are created.
+func resolveTags(ctx context.Context, app *bunapp.App, names []string) ([]string, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	slugs := make([]string, 0, len(names))
+	for _, name := range names {
+		slug, err := normalizeTag(name)
+		if err != nil {
+			return nil, err
+		}
+		slugs = append(slugs, slug)
+	}
+
+	var aliases []TagAlias
+	if err := app.IDB(ctx).NewSelect().
+		Model(&aliases).
+		Where("alias IN (?)", bun.In(slugs)).
+		Scan(ctx); err != nil {
+		return nil, err
+	}
+
+	canonical := make(map[string]string, len(aliases))
+	for _, a := range aliases {
+		canonical[a.Alias] = a.Tag
+	}
+
+	seen := make(map[string]bool, len(slugs))
+	tags := make([]Tag, 0, len(slugs))
+	for _, slug := range slugs {
+		if tag, ok := canonical[slug]; ok {
+			slug = tag
+		}
+		if seen[slug] {
+			continue
+		}
+		seen[slug] = true
+		tags = append(tags, Tag{Slug: slug})
+	}
+
+	if _, err := app.IDB(ctx).NewInsert().
+		Model(&tags).
+		On("CONFLICT DO NOTHING").
+		Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	resolved := make([]string, len(tags))
+	for i := range tags {
+		resolved[i] = tags[i].Slug
+	}
+	return resolved, nil
+}
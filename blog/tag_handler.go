@@ -1,37 +1,177 @@
 package blog
 
 import (
-	"database/sql"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/uptrace/bun-realworld-app/bunapp"
+	"github.com/uptrace/bun-realworld-app/httputil"
+	"github.com/uptrace/bun-realworld-app/httputil/httperror"
 	"github.com/uptrace/bunrouter"
 )
 
 type TagHandler struct {
-	app *bunapp.App
+	app     *bunapp.App
+	service *ArticleService
+	tags    *TagService
 }
 
 func NewTagHandler(app *bunapp.App) TagHandler {
 	return TagHandler{
-		app: app,
+		app:     app,
+		service: NewArticleService(app),
+		tags:    NewTagService(app),
 	}
 }
 
 func (h TagHandler) List(w http.ResponseWriter, req bunrouter.Request) error {
 	ctx := req.Context()
+	query := req.URL.Query()
 
-	tags := make([]string, 0)
-	if err := h.app.DB().NewSelect().
-		Model((*ArticleTag)(nil)).
-		ColumnExpr("tag").
-		GroupExpr("tag").
-		OrderExpr("count(tag) DESC").
-		Scan(ctx, &tags); err != nil && err != sql.ErrNoRows {
+	limit, err := parseTagsLimit(query.Get("limit"))
+	if err != nil {
+		return err
+	}
+
+	resp := TagsResponse{
+		Tags: make([]string, 0),
+	}
+	// Trending tags are computed by a job, so only popular tags have the time.
+	var lastModified time.Time
+
+	if window := query.Get("window"); window != "" {
+		if limit == 0 {
+			limit = defaultTrendingLimit
+		}
+
+		resp.Trending, err = h.tags.Trending(ctx, window, limit)
+		if err != nil {
+			return err
+		}
+		for _, tag := range resp.Trending {
+			resp.Tags = append(resp.Tags, tag.Tag)
+		}
+	} else {
+		lastModified, err = h.service.LastModified(ctx)
+		if err != nil {
+			return err
+		}
+
+		resp.Tags, err = h.service.Tags(ctx)
+		if err != nil {
+			return err
+		}
+		if limit > 0 && len(resp.Tags) > limit {
+			resp.Tags = resp.Tags[:limit]
+		}
+	}
+
+	// Tags are the same for all users.
+	maxAge := h.app.Config().HTTPCache.MaxAge
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+
+	return httputil.ConditionalJSON(w, req, resp, lastModified)
+}
+
+func (h TagHandler) Show(w http.ResponseWriter, req bunrouter.Request) error {
+	tag, err := h.tags.Get(req.Context(), req.Param("tag"))
+	if err != nil {
+		return err
+	}
+
+	maxAge := h.app.Config().HTTPCache.MaxAge
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+
+	return httputil.ConditionalJSON(w, req, bunrouter.H{
+		"tag": tag,
+	}, time.Time{})
+}
+
+func (h TagHandler) Update(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+
+	var in TagRequest
+	if err := httputil.UnmarshalJSON(w, req, &in, 10<<kb); err != nil {
+		return err
+	}
+
+	if in.Tag == nil {
+		return errors.New(`JSON field "tag" is required`)
+	}
+
+	if err := h.tags.SetDescription(ctx, req.Param("tag"), in.Tag.Description); err != nil {
+		return err
+	}
+
+	return h.respond(w, req)
+}
+
+func (h TagHandler) AddAlias(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+
+	var in TagAliasRequest
+	if err := httputil.UnmarshalJSON(w, req, &in, 10<<kb); err != nil {
+		return err
+	}
+
+	if err := h.tags.AddAlias(ctx, req.Param("tag"), in.Alias); err != nil {
+		return err
+	}
+
+	return h.respond(w, req)
+}
+
+func (h TagHandler) DeleteAlias(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+
+	if err := h.tags.DeleteAlias(ctx, req.Param("tag"), req.Param("alias")); err != nil {
+		return err
+	}
+
+	return h.respond(w, req)
+}
+
+func (h TagHandler) Merge(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+
+	var in TagMergeRequest
+	if err := httputil.UnmarshalJSON(w, req, &in, 10<<kb); err != nil {
+		return err
+	}
+
+	tag, err := h.tags.Merge(ctx, req.Param("tag"), in.Tags)
+	if err != nil {
 		return err
 	}
 
 	return bunrouter.JSON(w, bunrouter.H{
-		"tags": tags,
+		"tag": tag,
 	})
 }
+
+func (h TagHandler) respond(w http.ResponseWriter, req bunrouter.Request) error {
+	tag, err := h.tags.Get(req.Context(), req.Param("tag"))
+	if err != nil {
+		return err
+	}
+
+	return bunrouter.JSON(w, bunrouter.H{
+		"tag": tag,
+	})
+}
+
+// parseTagsLimit returns 0 when the limit is not set.
+func parseTagsLimit(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	limit, err := strconv.Atoi(s)
+	if err != nil || limit < 1 || limit > maxTagsLimit {
+		return 0, httperror.BadRequest("invalid_limit",
+			"limit must be a number between 1 and %d", maxTagsLimit)
+	}
+	return limit, nil
+}
@@ -0,0 +1,258 @@
+package blog
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun-realworld-app/bunapp"
+	"github.com/uptrace/bun-realworld-app/httputil/httperror"
+)
+
+const relatedTagsLimit = 10
+
+var ErrTagExists = httperror.New(http.StatusConflict,
+	"tag_exists", "alias is already used by a tag")
+
+// TagPage describes a tag with the number of articles and related tags.
+type TagPage struct {
+	Slug          string        `json:"slug"`
+	Description   string        `json:"description"`
+	ArticlesCount int           `json:"articlesCount"`
+	Aliases       []string      `json:"aliases"`
+	RelatedTags   []*RelatedTag `json:"relatedTags"`
+}
+
+// RelatedTag is a tag that is used together with another tag.
+type RelatedTag struct {
+	Tag           string `json:"tag"`
+	ArticlesCount int    `json:"articlesCount"`
+}
+
+// TagService manages canonical tags and their aliases.
+type TagService struct {
+	app *bunapp.App
+}
+
+func NewTagService(app *bunapp.App) *TagService {
+	return &TagService{
+		app: app,
+	}
+}
+
+// Get returns the tag with the slug or with the alias.
+func (s *TagService) Get(ctx context.Context, slug string) (*TagPage, error) {
+	tag, err := s.selectTag(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &TagPage{
+		Slug:        tag.Slug,
+		Description: tag.Description,
+		Aliases:     make([]string, 0),
+		RelatedTags: make([]*RelatedTag, 0),
+	}
+
+	db := s.app.IDB(ctx)
+
+	page.ArticlesCount, err = db.NewSelect().
+		Model((*ArticleTag)(nil)).
+		Join("JOIN articles AS a ON a.id = t.article_id").
+		Where("t.tag = ?", tag.Slug).
+		Where("a.hidden_at IS NULL").
+		Count(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.NewSelect().
+		Model((*TagAlias)(nil)).
+		Column("ta.alias").
+		Where("ta.tag = ?", tag.Slug).
+		OrderExpr("ta.alias ASC").
+		Scan(ctx, &page.Aliases); err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	if err := db.NewSelect().
+		Model((*ArticleTag)(nil)).
+		ColumnExpr("t2.tag").
+		ColumnExpr("count(*) AS articles_count").
+		Join("JOIN article_tags AS t2 ON t2.article_id = t.article_id AND t2.tag != t.tag").
+		Join("JOIN articles AS a ON a.id = t.article_id").
+		Where("t.tag = ?", tag.Slug).
+		Where("a.hidden_at IS NULL").
+		GroupExpr("t2.tag").
+		OrderExpr("articles_count DESC, t2.tag ASC").
+		Limit(relatedTagsLimit).
+		Scan(ctx, &page.RelatedTags); err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// SetDescription updates the description of the tag.
+func (s *TagService) SetDescription(ctx context.Context, slug, description string) error {
+	tag, err := s.selectTag(ctx, slug)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.app.IDB(ctx).NewUpdate().
+		Model(tag).
+		Set("description = ?", description).
+		WherePK().
+		Exec(ctx)
+	return err
+}
+
+// AddAlias makes the alias resolve to the tag. Existing tags can't be used
+// as aliases, merge them instead.
+func (s *TagService) AddAlias(ctx context.Context, slug, alias string) error {
+	alias, err := normalizeTag(alias)
+	if err != nil {
+		return err
+	}
+
+	return s.app.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		tag, err := s.selectTag(ctx, slug)
+		if err != nil {
+			return err
+		}
+
+		exists, err := tx.NewSelect().
+			Model((*Tag)(nil)).
+			Where("slug = ?", alias).
+			Exists(ctx)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return ErrTagExists
+		}
+
+		_, err = tx.NewInsert().
+			Model(&TagAlias{Alias: alias, Tag: tag.Slug}).
+			On("CONFLICT (alias) DO UPDATE").
+			Set("tag = EXCLUDED.tag").
+			Exec(ctx)
+		return err
+	})
+}
+
+// DeleteAlias removes the alias of the tag.
+func (s *TagService) DeleteAlias(ctx context.Context, slug, alias string) error {
+	tag, err := s.selectTag(ctx, slug)
+	if err != nil {
+		return err
+	}
+
+	alias, _ = normalizeTag(alias)
+	_, err = s.app.IDB(ctx).NewDelete().
+		Model((*TagAlias)(nil)).
+		Where("alias = ?", alias).
+		Where("tag = ?", tag.Slug).
+		Exec(ctx)
+	return err
+}
+
+// Merge moves articles and aliases of the tags into the tag with the slug.
+// Merged tags are deleted and become aliases of the tag.
+func (s *TagService) Merge(ctx context.Context, slug string, from []string) (*TagPage, error) {
+	if err := s.app.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		into, err := s.selectTag(ctx, slug)
+		if err != nil {
+			return err
+		}
+
+		seen := make(map[string]bool, len(from))
+		merged := make([]string, 0, len(from))
+		for _, name := range from {
+			tag, err := s.selectTag(ctx, name)
+			if err != nil {
+				return err
+			}
+			if tag.Slug != into.Slug && !seen[tag.Slug] {
+				seen[tag.Slug] = true
+				merged = append(merged, tag.Slug)
+			}
+		}
+		if len(merged) == 0 {
+			return nil
+		}
+
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO article_tags (article_id, tag)
+			SELECT article_id, ? FROM article_tags WHERE tag IN (?)
+			ON CONFLICT DO NOTHING
+		`, into.Slug, bun.In(merged)); err != nil {
+			return err
+		}
+
+		if _, err := tx.NewDelete().
+			Model((*ArticleTag)(nil)).
+			Where("tag IN (?)", bun.In(merged)).
+			Exec(ctx); err != nil {
+			return err
+		}
+
+		if _, err := tx.NewUpdate().
+			Model((*TagAlias)(nil)).
+			Set("tag = ?", into.Slug).
+			Where("tag IN (?)", bun.In(merged)).
+			Exec(ctx); err != nil {
+			return err
+		}
+
+		if _, err := tx.NewDelete().
+			Model((*Tag)(nil)).
+			Where("slug IN (?)", bun.In(merged)).
+			Exec(ctx); err != nil {
+			return err
+		}
+
+		aliases := make([]TagAlias, len(merged))
+		for i, tag := range merged {
+			aliases[i] = TagAlias{Alias: tag, Tag: into.Slug}
+		}
+		if _, err := tx.NewInsert().
+			Model(&aliases).
+			Exec(ctx); err != nil {
+			return err
+		}
+
+		return s.app.Invalidate(ctx, popularTagsCacheKey)
+	}); err != nil {
+		return nil, err
+	}
+
+	return s.Get(ctx, slug)
+}
+
+// selectTag returns the tag with the slug, resolving aliases.
+func (s *TagService) selectTag(ctx context.Context, slug string) (*Tag, error) {
+	slug, err := normalizeTag(slug)
+	if err != nil {
+		return nil, ErrTagNotFound
+	}
+
+	aliasq := s.app.IDB(ctx).NewSelect().
+		Model((*TagAlias)(nil)).
+		Column("ta.tag").
+		Where("ta.alias = ?", slug)
+
+	tag := new(Tag)
+	if err := s.app.IDB(ctx).NewSelect().
+		Model(tag).
+		Where("slug = coalesce((?), ?)", aliasq, slug).
+		Scan(ctx); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrTagNotFound
+		}
+		return nil, err
+	}
+	return tag, nil
+}
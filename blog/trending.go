@@ -0,0 +1,157 @@
+package blog
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun-realworld-app/bunapp"
+	"github.com/uptrace/bun-realworld-app/httputil/httperror"
+)
+
+const (
+	defaultTrendingLimit = 20
+	maxTagsLimit         = 100
+
+	defaultTrendingInterval = 10 * time.Minute
+	// trendingLockID is the advisory lock that lets only one instance
+	// compute trending tags at a time.
+	trendingLockID = 4210
+)
+
+// TrendingTag is a tag scored by recent article activity within a window.
+type TrendingTag struct {
+	bun.BaseModel `bun:"trending_tags,alias:tt"`
+
+	Period        string    `json:"-" bun:",pk"`
+	Tag           string    `json:"tag" bun:",pk"`
+	Score         float64   `json:"score"`
+	ArticlesCount int       `json:"articlesCount"`
+	ComputedAt    time.Time `json:"-"`
+}
+
+// Trending returns the tags with the highest scores in the window,
+// as of the last ComputeTrending run.
+func (s *TagService) Trending(ctx context.Context, window string, limit int) ([]*TrendingTag, error) {
+	if !s.isWindow(window) {
+		return nil, httperror.BadRequest("invalid_window", "window must be one of: %s",
+			strings.Join(s.app.Config().TrendingTags.Windows, ", "))
+	}
+
+	tags := make([]*TrendingTag, 0)
+	if err := s.app.IDB(ctx).NewSelect().
+		Model(&tags).
+		Where("period = ?", window).
+		OrderExpr("score DESC, tag ASC").
+		Limit(limit).
+		Scan(ctx); err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// ComputeTrending recomputes trending tags for all configured windows.
+// Each article published in the window adds 1 plus its favorites and comments
+// to the score of its tags, halved every half a window as the article ages.
+func (s *TagService) ComputeTrending(ctx context.Context) error {
+	now := s.app.Clock().Now()
+
+	return s.app.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var locked bool
+		if err := tx.QueryRowContext(ctx,
+			"SELECT pg_try_advisory_xact_lock(?)", trendingLockID).Scan(&locked); err != nil {
+			return err
+		}
+		if !locked {
+			// Another instance is computing the same scores.
+			return nil
+		}
+
+		for _, window := range s.app.Config().TrendingTags.Windows {
+			dur, err := parseWindow(window)
+			if err != nil {
+				return err
+			}
+
+			if _, err := tx.NewDelete().
+				Model((*TrendingTag)(nil)).
+				Where("period = ?", window).
+				Exec(ctx); err != nil {
+				return err
+			}
+
+			if _, err := tx.ExecContext(ctx, `
+				INSERT INTO trending_tags (period, tag, score, articles_count, computed_at)
+				SELECT ?0, t.tag,
+					sum((1 + a.favorites_count + a.comments_count) *
+						exp(-ln(2) * extract(epoch FROM ?1::timestamptz - a.created_at) / ?2)),
+					count(*), ?1
+				FROM article_tags AS t
+				JOIN articles AS a ON a.id = t.article_id
+				WHERE a.created_at > ?3 AND a.hidden_at IS NULL
+				GROUP BY t.tag
+			`, window, now, (dur / 2).Seconds(), now.Add(-dur)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *TagService) isWindow(window string) bool {
+	for _, w := range s.app.Config().TrendingTags.Windows {
+		if w == window {
+			return true
+		}
+	}
+	return false
+}
+
+// parseWindow works like time.ParseDuration, but also accepts days, e.g. 7d.
+func parseWindow(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err == nil && days > 0 {
+			return time.Duration(days) * 24 * time.Hour, nil
+		}
+	}
+	return time.ParseDuration(s)
+}
+
+// StartTrendingTags computes trending tags now and then periodically
+// until the app is stopped.
+func StartTrendingTags(app *bunapp.App) {
+	interval := app.Config().TrendingTags.Interval
+	if interval == 0 {
+		interval = defaultTrendingInterval
+	}
+
+	service := NewTagService(app)
+	done := make(chan struct{})
+
+	app.OnStop("blog.trendingTags", func(ctx context.Context, app *bunapp.App) error {
+		close(done)
+		return nil
+	})
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := service.ComputeTrending(app.Context()); err != nil {
+				log.Printf("blog: ComputeTrending failed: %s", err)
+			}
+
+			select {
+			case <-ticker.C:
+			case <-done:
+				return
+			}
+		}
+	}()
+}
@@ -13,6 +13,7 @@ import (
 
 	"github.com/benbjohnson/clock"
 	"github.com/uptrace/bun"
+	"github.com/uptrace/bun-realworld-app/httputil/openapi"
 	"github.com/uptrace/bun/dialect/pgdialect"
 	"github.com/uptrace/bun/driver/pgdriver"
 	"github.com/uptrace/bun/extra/bundebug"
@@ -42,9 +43,13 @@ type App struct {
 	onAfterStop appHooks
 
 	clock clock.Clock
+	cache Cache
 
 	router    *bunrouter.Router
 	apiRouter *bunrouter.Group
+	openapi   *openapi.Spec
+	api       *openapi.Group
+	site      *openapi.Group
 
 	// lazy init
 	dbOnce sync.Once
@@ -52,11 +57,19 @@ type App struct {
 }
 
 func New(ctx context.Context, cfg *AppConfig) *App {
+	cacheSize := cfg.Cache.Size
+	if cacheSize == 0 {
+		cacheSize = 10000
+	}
+
 	app := &App{
 		cfg:    cfg,
 		stopCh: make(chan struct{}),
 		clock:  clock.New(),
 	}
+	app.cache = NewLRUCache(cacheSize, func() time.Time {
+		return app.Clock().Now()
+	})
 	app.ctx = ContextWithApp(ctx, app)
 	app.initRouter()
 	return app
@@ -133,6 +146,20 @@ func (app *App) APIRouter() *bunrouter.Group {
 	return app.apiRouter
 }
 
+// API is the APIRouter that documents registered routes in the OpenAPI spec.
+func (app *App) API() *openapi.Group {
+	return app.api
+}
+
+// Site documents routes outside of /api, e.g. feeds, in the OpenAPI spec.
+func (app *App) Site() *openapi.Group {
+	return app.site
+}
+
+func (app *App) OpenAPI() *openapi.Spec {
+	return app.openapi
+}
+
 func (app *App) DB() *bun.DB {
 	app.dbOnce.Do(func() {
 		sqldb := sql.OpenDB(pgdriver.NewConnector(pgdriver.WithDSN(app.cfg.PGX.DSN)))
@@ -0,0 +1,184 @@
+package bunapp
+
+import (
+	"container/list"
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/driver/pgdriver"
+)
+
+// cacheChannel is the Postgres channel used to broadcast invalidated keys.
+const cacheChannel = "bunapp_cache_invalidate"
+
+// cachePurgePayload asks the listeners to purge the whole cache.
+const cachePurgePayload = "*"
+
+// Cache stores values shared by requests. Cached values must not be modified.
+type Cache interface {
+	Get(key string) (interface{}, bool)
+	Set(key string, value interface{}, ttl time.Duration)
+	Delete(keys ...string)
+	Purge()
+}
+
+// LRUCache is an in-process Cache that evicts the least recently used entries
+// when it is full.
+type LRUCache struct {
+	mu    sync.Mutex
+	size  int
+	now   func() time.Time
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+var _ Cache = (*LRUCache)(nil)
+
+type lruEntry struct {
+	key       string
+	value     interface{}
+	expiresAt time.Time
+}
+
+// NewLRUCache creates a cache that uses now to expire entries.
+func NewLRUCache(size int, now func() time.Time) *LRUCache {
+	return &LRUCache{
+		size:  size,
+		now:   now,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+func (c *LRUCache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := el.Value.(*lruEntry)
+	if c.now().After(entry.expiresAt) {
+		c.remove(el)
+		return nil, false
+	}
+
+	c.ll.MoveToFront(el)
+	return entry.value, true
+}
+
+func (c *LRUCache) Set(key string, value interface{}, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := c.now().Add(ttl)
+	if el, ok := c.items[key]; ok {
+		entry := el.Value.(*lruEntry)
+		entry.value = value
+		entry.expiresAt = expiresAt
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&lruEntry{
+		key:       key,
+		value:     value,
+		expiresAt: expiresAt,
+	})
+	for c.ll.Len() > c.size {
+		c.remove(c.ll.Back())
+	}
+}
+
+func (c *LRUCache) Delete(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		if el, ok := c.items[key]; ok {
+			c.remove(el)
+		}
+	}
+}
+
+func (c *LRUCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ll.Init()
+	c.items = make(map[string]*list.Element)
+}
+
+func (c *LRUCache) remove(el *list.Element) {
+	c.ll.Remove(el)
+	delete(c.items, el.Value.(*lruEntry).key)
+}
+
+//------------------------------------------------------------------------------
+
+func (app *App) Cache() Cache {
+	return app.cache
+}
+
+func (app *App) SetCache(cache Cache) {
+	app.cache = cache
+}
+
+// Invalidate deletes the keys from the cache and notifies other instances
+// that listen for invalidations. Inside RunInTx the keys are deleted and
+// the notification is sent when the transaction commits, so concurrent
+// requests can't cache uncommitted values again.
+func (app *App) Invalidate(ctx context.Context, keys ...string) error {
+	app.AfterCommit(ctx, func() {
+		app.cache.Delete(keys...)
+	})
+	return app.notifyCache(ctx, strings.Join(keys, "\n"))
+}
+
+// InvalidateAll is like Invalidate, but purges the whole cache.
+func (app *App) InvalidateAll(ctx context.Context) error {
+	app.AfterCommit(ctx, app.cache.Purge)
+	return app.notifyCache(ctx, cachePurgePayload)
+}
+
+func (app *App) notifyCache(ctx context.Context, payload string) error {
+	if _, err := app.IDB(ctx).ExecContext(ctx,
+		"NOTIFY ?, ?", bun.Ident(cacheChannel), payload); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ListenInvalidations deletes the keys invalidated by other instances,
+// and by this instance once the transactions commit, until the app is stopped.
+// Notifications sent while the connection is being restored are lost,
+// so cached values must have a TTL.
+func (app *App) ListenInvalidations() {
+	ln := pgdriver.NewListener(app.DB())
+	if err := ln.Listen(app.ctx, cacheChannel); err != nil {
+		log.Printf("cache: Listen failed: %s", err)
+	}
+
+	app.OnStop("cache.listener", func(ctx context.Context, app *App) error {
+		return ln.Close()
+	})
+
+	go func() {
+		for n := range ln.Channel() {
+			if n.Channel != cacheChannel {
+				continue
+			}
+			if n.Payload == cachePurgePayload {
+				app.cache.Purge()
+				continue
+			}
+			app.cache.Delete(strings.Split(n.Payload, "\n")...)
+		}
+	}()
+}
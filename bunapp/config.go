@@ -5,6 +5,7 @@ import (
 	"io/fs"
 	"path"
 	"sync"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -33,10 +34,136 @@ type AppConfig struct {
 
 	Debug     bool   `yaml:"debug"`
 	SecretKey string `yaml:"secret_key"`
+	// PublicURL is the URL of the frontend, which is used to build absolute
+	// links to articles and profiles, e.g. in feeds.
+	PublicURL string `yaml:"public_url"`
 
 	PGX struct {
 		DSN string `yaml:"dsn"`
 	} `yaml:"pgx"`
+
+	Login struct {
+		// FreeAttempts is the number of failed logins allowed before delays kick in.
+		FreeAttempts int           `yaml:"free_attempts"`
+		BaseDelay    time.Duration `yaml:"base_delay"`
+		MaxDelay     time.Duration `yaml:"max_delay"`
+		// ResetAfter forgets failures when there were no new ones for this long.
+		ResetAfter time.Duration `yaml:"reset_after"`
+
+		LockoutAttempts   int           `yaml:"lockout_attempts"`
+		IPLockoutAttempts int           `yaml:"ip_lockout_attempts"`
+		LockoutDuration   time.Duration `yaml:"lockout_duration"`
+	} `yaml:"login"`
+
+	Password struct {
+		// Algorithm is used to hash new passwords: argon2id (default) or bcrypt.
+		Algorithm string `yaml:"algorithm"`
+		Argon2id  struct {
+			Memory      uint32 `yaml:"memory"` // in KiB
+			Iterations  uint32 `yaml:"iterations"`
+			Parallelism uint8  `yaml:"parallelism"`
+			SaltLength  uint32 `yaml:"salt_length"`
+			KeyLength   uint32 `yaml:"key_length"`
+		} `yaml:"argon2id"`
+		Bcrypt struct {
+			Cost int `yaml:"cost"`
+		} `yaml:"bcrypt"`
+
+		MinLength int `yaml:"min_length"`
+		MaxLength int `yaml:"max_length"`
+		// BreachedList is a path in the embedded FS to a list of leaked passwords.
+		BreachedList string `yaml:"breached_list"`
+	} `yaml:"password"`
+
+	OIDC struct {
+		Providers []OIDCProviderConfig `yaml:"providers"`
+	} `yaml:"oidc"`
+
+	Cache struct {
+		// Size is the max number of entries in the in-process cache.
+		Size int `yaml:"size"`
+	} `yaml:"cache"`
+
+	HTTPCache struct {
+		// MaxAge is how long shared caches may serve anonymous responses
+		// without revalidation.
+		MaxAge time.Duration `yaml:"max_age"`
+	} `yaml:"http_cache"`
+
+	Markdown struct {
+		// Highlight keeps language-* classes on code blocks for syntax highlighting.
+		Highlight bool `yaml:"highlight"`
+	} `yaml:"markdown"`
+
+	Media struct {
+		// Storage is local or s3.
+		Storage string `yaml:"storage"`
+		// MaxSize is the max size of an uploaded file in bytes.
+		MaxSize int64 `yaml:"max_size"`
+		// UserQuota is the max total size of files uploaded by a user in bytes.
+		UserQuota int64 `yaml:"user_quota"`
+		// Larger images are resized to fit MaxDimension.
+		MaxDimension  int `yaml:"max_dimension"`
+		ThumbnailSize int `yaml:"thumbnail_size"`
+
+		Local struct {
+			Dir string `yaml:"dir"`
+		} `yaml:"local"`
+		S3 S3Config `yaml:"s3"`
+	} `yaml:"media"`
+
+	Feeds struct {
+		// Limit is the number of items in a feed unless the limit query param is set.
+		Limit int `yaml:"limit"`
+		// MaxLimit caps the limit query param.
+		MaxLimit int `yaml:"max_limit"`
+	} `yaml:"feeds"`
+
+	Sitemap struct {
+		// ShardSize is the max number of articles per sitemap. Defaults to
+		// the limit of the sitemaps protocol.
+		ShardSize int `yaml:"shard_size"`
+	} `yaml:"sitemap"`
+
+	TrendingTags struct {
+		// Interval is how often the trending tags are recomputed.
+		Interval time.Duration `yaml:"interval"`
+		// Windows are the supported values of the window query param, e.g. 7d.
+		Windows []string `yaml:"windows"`
+	} `yaml:"trending_tags"`
+}
+
+type OIDCProviderConfig struct {
+	Name         string   `yaml:"name"`
+	Issuer       string   `yaml:"issuer"`
+	ClientID     string   `yaml:"client_id"`
+	ClientSecret string   `yaml:"client_secret"`
+	RedirectURL  string   `yaml:"redirect_url"`
+	Scopes       []string `yaml:"scopes"`
+	// LinkByEmail links new identities to the users with the same verified email.
+	// Only enable it for providers that own the email domains they verify.
+	// Admins and moderators can only link identities while logged in.
+	LinkByEmail bool `yaml:"link_by_email"`
+}
+
+type S3Config struct {
+	Endpoint  string `yaml:"endpoint"`
+	Region    string `yaml:"region"`
+	Bucket    string `yaml:"bucket"`
+	AccessKey string `yaml:"access_key"`
+	SecretKey string `yaml:"secret_key"`
+	// PublicURL is the base URL of public files, e.g. a CDN.
+	// Defaults to the bucket URL.
+	PublicURL string `yaml:"public_url"`
+}
+
+func (cfg *AppConfig) OIDCProvider(name string) (*OIDCProviderConfig, bool) {
+	for i := range cfg.OIDC.Providers {
+		if p := &cfg.OIDC.Providers[i]; p.Name == name {
+			return p, true
+		}
+	}
+	return nil, false
 }
 
 func ReadConfig(fsys fs.FS, service, env string) (*AppConfig, error) {
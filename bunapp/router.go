@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/uptrace/bun-realworld-app/httputil/httperror"
+	"github.com/uptrace/bun-realworld-app/httputil/openapi"
 	"github.com/uptrace/bunrouter"
 	"github.com/uptrace/bunrouter/extra/bunrouterotel"
 	"github.com/uptrace/bunrouter/extra/reqlog"
@@ -22,6 +23,23 @@ func (app *App) initRouter() {
 		bunrouter.WithMiddleware(corsMiddleware),
 		bunrouter.WithMiddleware(errorHandler),
 	)
+
+	app.openapi = openapi.NewSpec("Conduit API", "1.0.0")
+	app.openapi.SetDefaultResponse(httperror.Error{})
+	app.openapi.AddSecurityScheme("token", &openapi.SecurityScheme{
+		Type:        "apiKey",
+		In:          "header",
+		Name:        "Authorization",
+		Description: `"Token <jwt>" or "Token <personal access token>"`,
+	})
+
+	app.api = openapi.NewGroup(app.apiRouter, app.openapi, "/api")
+	app.site = openapi.NewGroup(
+		app.router.NewGroup("", bunrouter.WithMiddleware(errorHandler)), app.openapi, "")
+	app.api.GET("/openapi.json", bunrouter.HTTPHandler(app.openapi),
+		openapi.Summary("OpenAPI document"),
+		openapi.Tags("meta"),
+		openapi.Returns(http.StatusOK, openapi.Document{}))
 }
 
 func errorHandler(next bunrouter.HandlerFunc) bunrouter.HandlerFunc {
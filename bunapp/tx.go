@@ -0,0 +1,102 @@
+package bunapp
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/driver/pgdriver"
+)
+
+const txMaxRetries = 3
+
+type txCtxKey struct{}
+
+// txState is the transaction in progress with the hooks to run after it commits.
+type txState struct {
+	tx          bun.Tx
+	afterCommit []func()
+}
+
+func txFromContext(ctx context.Context) (bun.Tx, bool) {
+	state, ok := ctx.Value(txCtxKey{}).(*txState)
+	if !ok {
+		return bun.Tx{}, false
+	}
+	return state.tx, true
+}
+
+// AfterCommit runs fn once the transaction started by RunInTx commits.
+// fn is dropped when the transaction rolls back. Outside of RunInTx fn runs immediately.
+func (app *App) AfterCommit(ctx context.Context, fn func()) {
+	if state, ok := ctx.Value(txCtxKey{}).(*txState); ok {
+		state.afterCommit = append(state.afterCommit, fn)
+		return
+	}
+	fn()
+}
+
+// IDB returns the transaction started by RunInTx or the DB when there is none.
+// Use it for queries that must see uncommitted changes made by the caller.
+func (app *App) IDB(ctx context.Context) bun.IDB {
+	if tx, ok := txFromContext(ctx); ok {
+		return tx
+	}
+	return app.DB()
+}
+
+// RunInTx runs fn in a transaction that is propagated via the context so
+// functions using App.IDB join it. When a transaction is already in progress,
+// fn runs in it. Serialization failures and deadlocks are retried, so fn
+// must not have side effects outside the database; use AfterCommit for them.
+func (app *App) RunInTx(
+	ctx context.Context, opts *sql.TxOptions, fn func(ctx context.Context, tx bun.Tx) error,
+) error {
+	if tx, ok := txFromContext(ctx); ok {
+		return fn(ctx, tx)
+	}
+
+	for attempt := 0; ; attempt++ {
+		state := new(txState)
+		err := app.DB().RunInTx(ctx, opts, func(ctx context.Context, tx bun.Tx) error {
+			state.tx = tx
+			ctx = context.WithValue(ctx, txCtxKey{}, state)
+			return fn(ctx, tx)
+		})
+		if err == nil {
+			for _, fn := range state.afterCommit {
+				fn()
+			}
+			return nil
+		}
+		if attempt >= txMaxRetries || !isRetryableTxError(err) {
+			return err
+		}
+
+		backoff := time.Duration(10<<uint(attempt)) * time.Millisecond
+		backoff += time.Duration(rand.Int63n(int64(backoff)))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+}
+
+func isRetryableTxError(err error) bool {
+	var pgErr pgdriver.Error
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	switch pgErr.Field('C') {
+	case "40001", // serialization_failure
+		"40P01": // deadlock_detected
+		return true
+	default:
+		return false
+	}
+}
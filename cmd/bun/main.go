@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
@@ -8,11 +9,14 @@ import (
 	"strings"
 	"time"
 
-	_ "github.com/uptrace/bun-realworld-app/blog"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun-realworld-app/blog"
 	"github.com/uptrace/bun-realworld-app/bunapp"
 	"github.com/uptrace/bun-realworld-app/cmd/bun/migrations"
+	_ "github.com/uptrace/bun-realworld-app/gql"
 	"github.com/uptrace/bun-realworld-app/httputil"
-	_ "github.com/uptrace/bun-realworld-app/org"
+	_ "github.com/uptrace/bun-realworld-app/media"
+	"github.com/uptrace/bun-realworld-app/org"
 	"github.com/uptrace/bun/migrate"
 	"github.com/urfave/cli/v2"
 )
@@ -27,16 +31,19 @@ func main() {
 				Usage: "environment",
 			},
 		},
-		Commands: []*cli.Command{
-			apiCommand,
-			newDBCommand(migrations.Migrations),
-		},
+		Commands: commands,
 	}
 	if err := app.Run(os.Args); err != nil {
 		log.Fatal(err)
 	}
 }
 
+// commands are extended by the files built with optional tags, for example, selftest.
+var commands = []*cli.Command{
+	apiCommand,
+	newDBCommand(migrations.Migrations),
+}
+
 var apiCommand = &cli.Command{
 	Name:  "api",
 	Usage: "start API server",
@@ -54,6 +61,9 @@ var apiCommand = &cli.Command{
 		}
 		defer app.Stop()
 
+		app.ListenInvalidations()
+		blog.StartTrendingTags(app)
+
 		var handler http.Handler
 		handler = app.Router()
 		handler = httputil.PanicHandler{Next: handler}
@@ -247,6 +257,49 @@ func newDBCommand(migrations *migrate.Migrations) *cli.Command {
 					return nil
 				},
 			},
+			{
+				Name:  "recount",
+				Usage: "recompute favorite, comment and follow counters",
+				Action: func(c *cli.Context) error {
+					ctx, app, err := bunapp.StartCLI(c)
+					if err != nil {
+						return err
+					}
+					defer app.Stop()
+
+					if err := app.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+						if err := blog.NewArticleService(app).Recount(ctx); err != nil {
+							return err
+						}
+						return org.NewUserService(app).Recount(ctx)
+					}); err != nil {
+						return err
+					}
+
+					fmt.Printf("recounted articles and users\n")
+					return nil
+				},
+			},
+			{
+				Name:  "reanalyze",
+				Usage: "recompute word count, reading time, table of contents and excerpt of articles",
+				Action: func(c *cli.Context) error {
+					ctx, app, err := bunapp.StartCLI(c)
+					if err != nil {
+						return err
+					}
+					defer app.Stop()
+
+					if err := app.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+						return blog.NewArticleService(app).Reanalyze(ctx)
+					}); err != nil {
+						return err
+					}
+
+					fmt.Printf("reanalyzed articles\n")
+					return nil
+				},
+			},
 			{
 				Name:  "mark_applied",
 				Usage: "mark migrations as applied without actually running them",
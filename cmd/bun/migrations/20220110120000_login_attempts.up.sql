@@ -0,0 +1,27 @@
+ALTER TABLE users
+ADD COLUMN is_admin boolean NOT NULL DEFAULT false;
+
+--bun:split
+
+CREATE TABLE login_attempts (
+  scope varchar(50) NOT NULL,
+  key varchar(500) NOT NULL,
+  failures int4 NOT NULL DEFAULT 0,
+  last_failed_at timestamptz NOT NULL,
+  locked_until timestamptz,
+
+  PRIMARY KEY (scope, key)
+);
+
+--bun:split
+
+CREATE TABLE notifications (
+  id int8 PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY,
+  user_id int8 NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  kind varchar(100) NOT NULL,
+  message text NOT NULL,
+
+  created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX notifications_user_id_idx ON notifications (user_id);
@@ -0,0 +1,16 @@
+ALTER TABLE users
+ADD COLUMN totp_secret varchar(100),
+ADD COLUMN totp_enabled boolean NOT NULL DEFAULT false,
+ADD COLUMN totp_last_step int8 NOT NULL DEFAULT 0;
+
+--bun:split
+
+CREATE TABLE recovery_codes (
+  id int8 PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY,
+  user_id int8 NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  code_hash varchar(100) NOT NULL,
+  used_at timestamptz
+);
+
+CREATE UNIQUE INDEX recovery_codes_user_id_code_hash_idx
+ON recovery_codes (user_id, code_hash);
@@ -0,0 +1,28 @@
+CREATE TABLE user_identities (
+  id int8 PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY,
+  user_id int8 NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  provider varchar(100) NOT NULL,
+  subject varchar(500) NOT NULL,
+  email varchar(500),
+
+  created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX user_identities_provider_subject_idx
+ON user_identities (provider, subject);
+
+CREATE INDEX user_identities_user_id_idx ON user_identities (user_id);
+
+--bun:split
+
+CREATE TABLE oidc_states (
+  state varchar(100) PRIMARY KEY,
+  provider varchar(100) NOT NULL,
+  nonce varchar(100) NOT NULL,
+  code_verifier varchar(100) NOT NULL,
+  user_id int8 REFERENCES users (id) ON DELETE CASCADE,
+
+  expires_at timestamptz NOT NULL
+);
+
+CREATE INDEX oidc_states_expires_at_idx ON oidc_states (expires_at);
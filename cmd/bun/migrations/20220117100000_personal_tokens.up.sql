@@ -0,0 +1,15 @@
+CREATE TABLE personal_tokens (
+  id int8 PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY,
+  user_id int8 NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  name varchar(100) NOT NULL,
+  token_hash varchar(100) NOT NULL,
+  token_prefix varchar(20) NOT NULL,
+  scopes text[] NOT NULL,
+
+  created_at timestamptz NOT NULL DEFAULT now(),
+  last_used_at timestamptz,
+  expires_at timestamptz
+);
+
+CREATE UNIQUE INDEX personal_tokens_token_hash_idx ON personal_tokens (token_hash);
+CREATE INDEX personal_tokens_user_id_idx ON personal_tokens (user_id);
@@ -0,0 +1,70 @@
+ALTER TABLE articles
+  ADD COLUMN favorites_count int4 NOT NULL DEFAULT 0,
+  ADD COLUMN comments_count int4 NOT NULL DEFAULT 0;
+
+ALTER TABLE users
+  ADD COLUMN followers_count int4 NOT NULL DEFAULT 0,
+  ADD COLUMN following_count int4 NOT NULL DEFAULT 0;
+
+--bun:split
+
+CREATE FUNCTION favorite_articles_count() RETURNS trigger AS $$
+BEGIN
+  IF TG_OP = 'INSERT' THEN
+    UPDATE articles SET favorites_count = favorites_count + 1 WHERE id = NEW.article_id;
+  ELSE
+    UPDATE articles SET favorites_count = favorites_count - 1 WHERE id = OLD.article_id;
+  END IF;
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER favorite_articles_count
+AFTER INSERT OR DELETE ON favorite_articles
+FOR EACH ROW EXECUTE FUNCTION favorite_articles_count();
+
+--bun:split
+
+CREATE FUNCTION comments_count() RETURNS trigger AS $$
+BEGIN
+  IF TG_OP = 'INSERT' THEN
+    UPDATE articles SET comments_count = comments_count + 1 WHERE id = NEW.article_id;
+  ELSE
+    UPDATE articles SET comments_count = comments_count - 1 WHERE id = OLD.article_id;
+  END IF;
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER comments_count
+AFTER INSERT OR DELETE ON comments
+FOR EACH ROW EXECUTE FUNCTION comments_count();
+
+--bun:split
+
+CREATE FUNCTION follow_users_count() RETURNS trigger AS $$
+BEGIN
+  IF TG_OP = 'INSERT' THEN
+    UPDATE users SET followers_count = followers_count + 1 WHERE id = NEW.followed_user_id;
+    UPDATE users SET following_count = following_count + 1 WHERE id = NEW.user_id;
+  ELSE
+    UPDATE users SET followers_count = followers_count - 1 WHERE id = OLD.followed_user_id;
+    UPDATE users SET following_count = following_count - 1 WHERE id = OLD.user_id;
+  END IF;
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER follow_users_count
+AFTER INSERT OR DELETE ON follow_users
+FOR EACH ROW EXECUTE FUNCTION follow_users_count();
+
+--bun:split
+
+UPDATE articles AS a SET
+  favorites_count = (SELECT count(*) FROM favorite_articles AS fa WHERE fa.article_id = a.id),
+  comments_count = (SELECT count(*) FROM comments AS c WHERE c.article_id = a.id);
+
+UPDATE users AS u SET
+  followers_count = (SELECT count(*) FROM follow_users AS fu WHERE fu.followed_user_id = u.id),
+  following_count = (SELECT count(*) FROM follow_users AS fu WHERE fu.user_id = u.id);
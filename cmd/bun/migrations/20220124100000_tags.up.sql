@@ -0,0 +1,42 @@
+CREATE TABLE tags (
+  slug varchar(100) PRIMARY KEY,
+  description text NOT NULL DEFAULT '',
+
+  created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE TABLE tag_aliases (
+  alias varchar(100) PRIMARY KEY,
+  tag varchar(100) NOT NULL REFERENCES tags (slug) ON UPDATE CASCADE ON DELETE CASCADE
+);
+
+CREATE INDEX tag_aliases_tag_idx ON tag_aliases (tag);
+
+--bun:split
+
+-- Normalize existing tags the same way as blog.normalizeTag.
+CREATE TABLE article_tags_normalized AS
+SELECT DISTINCT article_id, lower(regexp_replace(btrim(tag, E' \t\n\r'), E'\\s+', '-', 'g')) AS tag
+FROM article_tags
+WHERE btrim(coalesce(tag, ''), E' \t\n\r') != '';
+
+TRUNCATE article_tags;
+
+INSERT INTO article_tags (article_id, tag)
+SELECT article_id, left(tag, 100) FROM article_tags_normalized
+ON CONFLICT DO NOTHING;
+
+DROP TABLE article_tags_normalized;
+
+INSERT INTO tags (slug)
+SELECT DISTINCT tag FROM article_tags;
+
+--bun:split
+
+ALTER TABLE article_tags
+  ALTER COLUMN tag TYPE varchar(100),
+  ALTER COLUMN tag SET NOT NULL,
+  ADD CONSTRAINT article_tags_tag_fkey
+    FOREIGN KEY (tag) REFERENCES tags (slug) ON UPDATE CASCADE;
+
+CREATE INDEX article_tags_tag_idx ON article_tags (tag);
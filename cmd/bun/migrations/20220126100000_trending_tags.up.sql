@@ -0,0 +1,11 @@
+CREATE TABLE trending_tags (
+  period varchar(16) NOT NULL,
+  tag varchar(100) NOT NULL REFERENCES tags (slug) ON UPDATE CASCADE ON DELETE CASCADE,
+  score float8 NOT NULL,
+  articles_count int4 NOT NULL,
+  computed_at timestamptz NOT NULL,
+
+  PRIMARY KEY (period, tag)
+);
+
+CREATE INDEX trending_tags_period_score_idx ON trending_tags (period, score DESC);
@@ -0,0 +1,12 @@
+CREATE UNIQUE INDEX articles_slug_idx ON articles (slug);
+
+--bun:split
+
+CREATE TABLE article_slug_history (
+  slug varchar(500) PRIMARY KEY,
+  article_id int8 NOT NULL REFERENCES articles (id) ON DELETE CASCADE,
+
+  created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX article_slug_history_article_id_idx ON article_slug_history (article_id);
@@ -0,0 +1,10 @@
+ALTER TABLE articles
+  ADD COLUMN word_count int4 NOT NULL DEFAULT 0,
+  ADD COLUMN reading_time_minutes int4 NOT NULL DEFAULT 0,
+  ADD COLUMN toc jsonb NOT NULL DEFAULT '[]',
+  ADD COLUMN excerpt text NOT NULL DEFAULT '';
+
+--bun:split
+
+-- Run `bun db reanalyze` to compute the stats of existing articles.
+UPDATE articles SET excerpt = description;
@@ -0,0 +1,15 @@
+CREATE TABLE media (
+  id int8 PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY,
+  user_id int8 NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  key varchar(100) NOT NULL UNIQUE,
+  thumbnail_key varchar(100) NOT NULL,
+  content_type varchar(100) NOT NULL,
+  size int8 NOT NULL,
+  thumbnail_size int8 NOT NULL,
+  width int4 NOT NULL,
+  height int4 NOT NULL,
+
+  created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX media_user_id_idx ON media (user_id);
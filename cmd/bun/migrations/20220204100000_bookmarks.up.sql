@@ -0,0 +1,24 @@
+CREATE TABLE bookmark_folders (
+  id int8 PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY,
+  user_id int8 NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  name varchar(100) NOT NULL,
+
+  created_at timestamptz NOT NULL DEFAULT now(),
+
+  UNIQUE (user_id, name)
+);
+
+--bun:split
+
+CREATE TABLE bookmarks (
+  user_id int8 NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  article_id int8 NOT NULL REFERENCES articles (id) ON DELETE CASCADE,
+  folder_id int8 REFERENCES bookmark_folders (id) ON DELETE SET NULL,
+
+  created_at timestamptz NOT NULL DEFAULT now(),
+
+  PRIMARY KEY (user_id, article_id)
+);
+
+CREATE INDEX bookmarks_user_id_created_at_idx ON bookmarks (user_id, created_at DESC);
+CREATE INDEX bookmarks_folder_id_idx ON bookmarks (folder_id);
@@ -0,0 +1,21 @@
+CREATE TABLE block_users (
+  user_id int8 NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  blocked_user_id int8 NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+
+  created_at timestamptz NOT NULL DEFAULT now(),
+
+  PRIMARY KEY (user_id, blocked_user_id)
+);
+
+CREATE INDEX block_users_blocked_user_id_idx ON block_users (blocked_user_id);
+
+--bun:split
+
+CREATE TABLE mute_users (
+  user_id int8 NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  muted_user_id int8 NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+
+  created_at timestamptz NOT NULL DEFAULT now(),
+
+  PRIMARY KEY (user_id, muted_user_id)
+);
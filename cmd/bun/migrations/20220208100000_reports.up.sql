@@ -0,0 +1,32 @@
+ALTER TABLE users
+  ADD COLUMN is_moderator boolean NOT NULL DEFAULT false,
+  ADD COLUMN suspended_at timestamptz;
+
+ALTER TABLE articles
+  ADD COLUMN hidden_at timestamptz;
+
+ALTER TABLE comments
+  ADD COLUMN hidden_at timestamptz;
+
+--bun:split
+
+CREATE TABLE reports (
+  id int8 PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY,
+  reporter_id int8 NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  target_type varchar(20) NOT NULL,
+  target_id int8 NOT NULL,
+  author_id int8 NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  reason text NOT NULL,
+  status varchar(20) NOT NULL DEFAULT 'open',
+  resolved_by_id int8 REFERENCES users (id) ON DELETE SET NULL,
+
+  created_at timestamptz NOT NULL DEFAULT now(),
+  resolved_at timestamptz
+);
+
+CREATE INDEX reports_status_created_at_idx ON reports (status, created_at);
+CREATE INDEX reports_target_idx ON reports (target_type, target_id);
+
+-- A user can only have one open report per target.
+CREATE UNIQUE INDEX reports_open_reporter_target_idx
+ON reports (reporter_id, target_type, target_id) WHERE status = 'open';
@@ -0,0 +1,79 @@
+ALTER TABLE articles ADD COLUMN changed_at timestamptz NOT NULL DEFAULT now();
+ALTER TABLE users ADD COLUMN changed_at timestamptz NOT NULL DEFAULT now();
+
+CREATE INDEX articles_changed_at_idx ON articles (changed_at);
+CREATE INDEX users_changed_at_idx ON users (changed_at);
+
+--bun:split
+
+-- changed_at moves by at least a second on every change, because
+-- Last-Modified has a second precision.
+CREATE FUNCTION touch_changed_at() RETURNS trigger AS $$
+BEGIN
+  IF TG_OP = 'INSERT' THEN
+    NEW.changed_at := clock_timestamp();
+  ELSE
+    NEW.changed_at := greatest(clock_timestamp(), OLD.changed_at + interval '1 second');
+  END IF;
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER articles_changed_at
+BEFORE INSERT OR UPDATE ON articles
+FOR EACH ROW EXECUTE FUNCTION touch_changed_at();
+
+CREATE TRIGGER users_changed_at
+BEFORE INSERT OR UPDATE ON users
+FOR EACH ROW EXECUTE FUNCTION touch_changed_at();
+
+--bun:split
+
+-- Deleted articles leave lists, so they touch their authors.
+CREATE FUNCTION articles_touch_author() RETURNS trigger AS $$
+BEGIN
+  UPDATE users SET changed_at = changed_at WHERE id = OLD.author_id;
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER articles_touch_author
+AFTER DELETE ON articles
+FOR EACH ROW EXECUTE FUNCTION articles_touch_author();
+
+--bun:split
+
+-- Tags and aliases move articles between tag lists, so they touch the articles.
+CREATE FUNCTION article_tags_touch_article() RETURNS trigger AS $$
+BEGIN
+  IF TG_OP IN ('UPDATE', 'DELETE') THEN
+    UPDATE articles SET changed_at = changed_at WHERE id = OLD.article_id;
+  END IF;
+  IF TG_OP IN ('INSERT', 'UPDATE') THEN
+    UPDATE articles SET changed_at = changed_at WHERE id = NEW.article_id;
+  END IF;
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER article_tags_touch_article
+AFTER INSERT OR UPDATE OR DELETE ON article_tags
+FOR EACH ROW EXECUTE FUNCTION article_tags_touch_article();
+
+CREATE FUNCTION tag_aliases_touch_articles() RETURNS trigger AS $$
+BEGIN
+  IF TG_OP IN ('UPDATE', 'DELETE') THEN
+    UPDATE articles SET changed_at = changed_at
+    WHERE id IN (SELECT article_id FROM article_tags WHERE tag = OLD.tag);
+  END IF;
+  IF TG_OP IN ('INSERT', 'UPDATE') THEN
+    UPDATE articles SET changed_at = changed_at
+    WHERE id IN (SELECT article_id FROM article_tags WHERE tag = NEW.tag);
+  END IF;
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER tag_aliases_touch_articles
+AFTER INSERT OR UPDATE OR DELETE ON tag_aliases
+FOR EACH ROW EXECUTE FUNCTION tag_aliases_touch_articles();
@@ -0,0 +1,55 @@
+//go:build selftest
+// +build selftest
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/uptrace/bun-realworld-app/bunapp"
+	"github.com/uptrace/bun-realworld-app/testbed"
+	"github.com/urfave/cli/v2"
+)
+
+// The conformance runner lives behind the selftest build tag to keep testbed
+// out of the production binary.
+func init() {
+	commands = append(commands, selftestCommand)
+}
+
+var selftestCommand = &cli.Command{
+	Name:  "selftest",
+	Usage: "run RealWorld API conformance tests",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "addr",
+			Usage: "test a live server instead of the in-process router",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		var conformance *testbed.Conformance
+
+		if addr := c.String("addr"); addr != "" {
+			conformance = testbed.NewRemoteConformance(addr)
+		} else {
+			_, app, err := bunapp.StartCLI(c)
+			if err != nil {
+				return err
+			}
+			defer app.Stop()
+
+			conformance = testbed.NewConformance(app.Router())
+		}
+
+		report := conformance.Run(c.Context)
+		if _, err := report.WriteTo(os.Stdout); err != nil {
+			return err
+		}
+
+		if n := report.Failed(); n > 0 {
+			return fmt.Errorf("%d conformance steps failed", n)
+		}
+		return nil
+	},
+}
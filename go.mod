@@ -8,6 +8,8 @@ require (
 	github.com/dgrijalva/jwt-go v3.2.0+incompatible
 	github.com/go-pg/urlstruct v1.0.1
 	github.com/gosimple/slug v1.12.0
+	github.com/graph-gophers/graphql-go v1.3.0
+	github.com/microcosm-cc/bluemonday v1.0.18
 	github.com/onsi/ginkgo v1.16.4
 	github.com/onsi/gomega v1.17.0
 	github.com/uptrace/bun v1.0.21
@@ -19,6 +21,7 @@ require (
 	github.com/uptrace/bunrouter/extra/bunrouterotel v1.0.10
 	github.com/uptrace/bunrouter/extra/reqlog v1.0.10
 	github.com/urfave/cli/v2 v2.3.0
+	github.com/yuin/goldmark v1.4.13
 	go4.org v0.0.0-20201209231011-d4a079459e60
 	golang.org/x/crypto v0.0.0-20211215153901-e495a2d5b3d3
 	golang.org/x/text v0.3.7 // indirect
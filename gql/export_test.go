@@ -0,0 +1,33 @@
+package gql
+
+import "context"
+
+func LoadBatches(keys []uint64) (int, error) {
+	var calls int
+	l := newLoader(func(ctx context.Context, keys []uint64) (map[uint64]interface{}, error) {
+		calls++
+		m := make(map[uint64]interface{}, len(keys))
+		for _, k := range keys {
+			m[k] = k
+		}
+		return m, nil
+	})
+
+	ctx := context.Background()
+	errc := make(chan error, len(keys))
+	for _, key := range keys {
+		go func(key uint64) {
+			v, err := l.Load(ctx, key)
+			if err == nil && v.(uint64) != key {
+				err = context.Canceled
+			}
+			errc <- err
+		}(key)
+	}
+	for range keys {
+		if err := <-errc; err != nil {
+			return 0, err
+		}
+	}
+	return calls, nil
+}
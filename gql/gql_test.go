@@ -0,0 +1,218 @@
+package gql_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/uptrace/bun-realworld-app/gql"
+	"github.com/uptrace/bun-realworld-app/org"
+	"github.com/uptrace/bun-realworld-app/testbed"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestGinkgo(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "gql")
+}
+
+var _ = Describe("loader", func() {
+	It("batches concurrent loads", func() {
+		calls, err := gql.LoadBatches([]uint64{1, 2, 3, 2, 1, 4, 5})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(calls).To(Equal(1))
+	})
+})
+
+var _ = Describe("graphql", func() {
+	var ctx context.Context
+	var app *testbed.TestApp
+	var author, reader *org.User
+
+	exec := func(client testbed.Client, query string, vars map[string]interface{}) map[string]interface{} {
+		b, err := json.Marshal(map[string]interface{}{
+			"query":     query,
+			"variables": vars,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		return parseJSON(client.PostJSON("/api/graphql", string(b)), http.StatusOK)
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		app = testbed.StartApp(ctx)
+		app.TruncateDB(ctx)
+
+		author = &org.User{Username: "author", Email: "author@gg.cn", PasswordHash: "#"}
+		reader = &org.User{Username: "reader", Email: "reader@gg.cn", PasswordHash: "#"}
+		_, err := app.DB().NewInsert().Model(author).Exec(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = app.DB().NewInsert().Model(reader).Exec(ctx)
+		Expect(err).NotTo(HaveOccurred())
+
+		for i := 0; i < 3; i++ {
+			data := exec(app.Client().WithToken(author.ID), `
+				mutation($input: ArticleInput!) {
+					createArticle(input: $input) { slug }
+				}`, map[string]interface{}{
+				"input": map[string]interface{}{
+					"title":       fmt.Sprintf("Article %d", i),
+					"description": "Description",
+					"body":        "Body",
+					"tagList":     []string{"go", fmt.Sprintf("tag%d", i)},
+				},
+			})
+			Expect(data).NotTo(HaveKey("errors"))
+		}
+	})
+
+	AfterEach(func() {
+		app.Stop()
+	})
+
+	It("returns articles with authors, tags and comments", func() {
+		client := app.Client().WithToken(reader.ID)
+
+		data := exec(client, `mutation { follow(username: "author") { following } }`, nil)
+		Expect(data).NotTo(HaveKey("errors"))
+
+		data = exec(client, `{
+			articles(tag: "go") {
+				title
+				tagList
+				author { username following }
+				comments { body }
+			}
+		}`, nil)
+		Expect(data).NotTo(HaveKey("errors"))
+
+		articles := data["data"].(map[string]interface{})["articles"].([]interface{})
+		Expect(articles).To(HaveLen(3))
+		for _, v := range articles {
+			article := v.(map[string]interface{})
+			Expect(article["tagList"]).To(ContainElement("go"))
+			Expect(article["author"]).To(Equal(map[string]interface{}{
+				"username":  "author",
+				"following": true,
+			}))
+			Expect(article["comments"]).To(BeEmpty())
+		}
+	})
+
+	It("adds comments to articles", func() {
+		data := exec(app.Client(), `{ articles(limit: 1) { slug } }`, nil)
+		slug := data["data"].(map[string]interface{})["articles"].([]interface{})[0].(map[string]interface{})["slug"]
+
+		data = exec(app.Client().WithToken(reader.ID), `
+			mutation($slug: String!) {
+				addComment(slug: $slug, body: "Nice!") { body author { username } }
+			}`, map[string]interface{}{"slug": slug})
+		Expect(data).NotTo(HaveKey("errors"))
+
+		data = exec(app.Client(), `
+			query($slug: String!) {
+				article(slug: $slug) { comments { body author { username } } }
+			}`, map[string]interface{}{"slug": slug})
+		Expect(data["data"]).To(Equal(map[string]interface{}{
+			"article": map[string]interface{}{
+				"comments": []interface{}{
+					map[string]interface{}{
+						"body":   "Nice!",
+						"author": map[string]interface{}{"username": "reader"},
+					},
+				},
+			},
+		}))
+	})
+
+	It("requires authentication for mutations", func() {
+		data := exec(app.Client(), `mutation { follow(username: "author") { following } }`, nil)
+
+		errors := data["errors"].([]interface{})
+		Expect(errors).To(HaveLen(1))
+		Expect(errors[0].(map[string]interface{})["extensions"]).
+			To(HaveKeyWithValue("status", float64(http.StatusUnauthorized)))
+	})
+
+	It("returns typed errors", func() {
+		data := exec(app.Client().WithToken(reader.ID), `
+			mutation { deleteArticle(slug: "unknown") }`, nil)
+
+		errors := data["errors"].([]interface{})
+		Expect(errors[0].(map[string]interface{})["extensions"]).
+			To(HaveKeyWithValue("code", "not_found"))
+	})
+
+	It("registers and logs in users", func() {
+		data := exec(app.Client(), `
+			mutation {
+				register(input: {username: "new", email: "new@gg.cn", password: "hello-pwd"}) {
+					user { username }
+				}
+			}`, nil)
+		Expect(data).NotTo(HaveKey("errors"))
+
+		data = exec(app.Client(), `
+			mutation {
+				login(email: "new@gg.cn", password: "hello-pwd") { user { username token } challenge { token } }
+			}`, nil)
+		Expect(data).NotTo(HaveKey("errors"))
+
+		login := data["data"].(map[string]interface{})["login"].(map[string]interface{})
+		Expect(login["challenge"]).To(BeNil())
+		Expect(login["user"]).To(HaveKeyWithValue("token", Not(BeEmpty())))
+	})
+
+	It("completes 2FA challenges", func() {
+		_, err := app.DB().NewUpdate().
+			Model(reader).
+			Set("totp_enabled = TRUE").
+			WherePK().
+			Exec(ctx)
+		Expect(err).NotTo(HaveOccurred())
+
+		sum := sha256.Sum256([]byte("abcd1234"))
+		_, err = app.DB().NewInsert().
+			Model(&org.RecoveryCode{UserID: reader.ID, CodeHash: hex.EncodeToString(sum[:])}).
+			Exec(ctx)
+		Expect(err).NotTo(HaveOccurred())
+
+		challenge, err := org.CreateChallengeToken(app.App, reader.ID, org.ChallengeTTL)
+		Expect(err).NotTo(HaveOccurred())
+
+		query := `
+			mutation($challengeToken: String!, $recoveryCode: String) {
+				loginSecondFactor(challengeToken: $challengeToken, recoveryCode: $recoveryCode) {
+					user { username token }
+				}
+			}`
+		vars := map[string]interface{}{
+			"challengeToken": challenge,
+			"recoveryCode":   "wrong",
+		}
+		data := exec(app.Client(), query, vars)
+		Expect(data).To(HaveKey("errors"))
+
+		vars["recoveryCode"] = "abcd-1234"
+		data = exec(app.Client(), query, vars)
+		Expect(data).NotTo(HaveKey("errors"))
+		payload := data["data"].(map[string]interface{})["loginSecondFactor"].(map[string]interface{})
+		Expect(payload["user"]).To(HaveKeyWithValue("username", "reader"))
+		Expect(payload["user"]).To(HaveKeyWithValue("token", Not(BeEmpty())))
+	})
+})
+
+func parseJSON(resp *httptest.ResponseRecorder, code int) map[string]interface{} {
+	out := make(map[string]interface{})
+	err := json.Unmarshal(resp.Body.Bytes(), &out)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(resp.Code).To(Equal(code))
+	return out
+}
@@ -0,0 +1,77 @@
+package gql
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/graph-gophers/graphql-go"
+	"github.com/uptrace/bunrouter"
+
+	"github.com/uptrace/bun-realworld-app/bunapp"
+	"github.com/uptrace/bun-realworld-app/httputil"
+	"github.com/uptrace/bun-realworld-app/httputil/httperror"
+	"github.com/uptrace/bun-realworld-app/org"
+)
+
+const kb = 10
+
+type Handler struct {
+	app    *bunapp.App
+	schema *graphql.Schema
+}
+
+func NewHandler(app *bunapp.App) (Handler, error) {
+	schema, err := NewSchema(app)
+	if err != nil {
+		return Handler{}, err
+	}
+	return Handler{
+		app:    app,
+		schema: schema,
+	}, nil
+}
+
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+func (h Handler) Serve(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+
+	var in Request
+	if err := httputil.UnmarshalJSON(w, req, &in, 100<<kb); err != nil {
+		return err
+	}
+
+	if in.Query == "" {
+		return httperror.BadRequest("query_required", `"query" is required`)
+	}
+
+	ctx = context.WithValue(ctx, loaderCtxKey{}, newLoaders(h.app, org.UserFromContext(ctx)))
+	ctx = context.WithValue(ctx, remoteIPCtxKey{}, org.RemoteIP(req))
+
+	resp := h.schema.Exec(ctx, in.Query, in.OperationName, in.Variables)
+	for _, qerr := range resp.Errors {
+		if qerr.ResolverError == nil {
+			continue
+		}
+
+		err := qerr.ResolverError
+		httpErr := httperror.From(err)
+		if httpErr == httperror.ErrInternal {
+			log.Printf("graphql: %s: %s", qerr.Path, err)
+		}
+		org.SetRetryAfter(w, err)
+
+		qerr.Message = httpErr.Message
+		qerr.Extensions = map[string]interface{}{
+			"code":   httpErr.Code,
+			"status": httpErr.Status,
+		}
+	}
+
+	return bunrouter.JSON(w, resp)
+}
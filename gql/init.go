@@ -0,0 +1,32 @@
+package gql
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/graph-gophers/graphql-go"
+
+	"github.com/uptrace/bun-realworld-app/bunapp"
+	"github.com/uptrace/bun-realworld-app/httputil/openapi"
+	"github.com/uptrace/bun-realworld-app/org"
+)
+
+func init() {
+	bunapp.OnStart("gql.initRoutes", func(ctx context.Context, app *bunapp.App) error {
+		middleware := org.NewMiddleware(app)
+
+		handler, err := NewHandler(app)
+		if err != nil {
+			return err
+		}
+
+		g := app.API().WithMiddleware(middleware.User, openapi.Tags("graphql"))
+
+		g.POST("/graphql", handler.Serve,
+			openapi.Summary("Execute a GraphQL query"),
+			openapi.Request(Request{}),
+			openapi.Returns(http.StatusOK, graphql.Response{}))
+
+		return nil
+	})
+}
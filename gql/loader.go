@@ -0,0 +1,152 @@
+package gql
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/uptrace/bun-realworld-app/blog"
+	"github.com/uptrace/bun-realworld-app/bunapp"
+	"github.com/uptrace/bun-realworld-app/org"
+)
+
+const (
+	loaderWait     = time.Millisecond
+	loaderMaxBatch = 100
+)
+
+type loaderCtxKey struct{}
+
+// loaders are created per request so cached values never leak between viewers.
+type loaders struct {
+	profiles *loader
+	tags     *loader
+}
+
+func newLoaders(app *bunapp.App, viewer *org.User) *loaders {
+	users := org.NewUserService(app)
+	articles := blog.NewArticleService(app)
+
+	return &loaders{
+		profiles: newLoader(func(ctx context.Context, ids []uint64) (map[uint64]interface{}, error) {
+			profiles, err := users.ProfilesByID(ctx, viewer, ids)
+			if err != nil {
+				return nil, err
+			}
+			m := make(map[uint64]interface{}, len(profiles))
+			for id, p := range profiles {
+				m[id] = p
+			}
+			return m, nil
+		}),
+		tags: newLoader(func(ctx context.Context, ids []uint64) (map[uint64]interface{}, error) {
+			tags, err := articles.TagsByArticleID(ctx, ids)
+			if err != nil {
+				return nil, err
+			}
+			m := make(map[uint64]interface{}, len(tags))
+			for id, t := range tags {
+				m[id] = t
+			}
+			return m, nil
+		}),
+	}
+}
+
+func loadersFromContext(ctx context.Context) *loaders {
+	return ctx.Value(loaderCtxKey{}).(*loaders)
+}
+
+func (l *loaders) Profile(ctx context.Context, userID uint64) (*org.Profile, error) {
+	v, err := l.profiles.Load(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return nil, org.ErrUserNotFound
+	}
+	return v.(*org.Profile), nil
+}
+
+func (l *loaders) Tags(ctx context.Context, articleID uint64) ([]string, error) {
+	v, err := l.tags.Load(ctx, articleID)
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return make([]string, 0), nil
+	}
+	return v.([]string), nil
+}
+
+//------------------------------------------------------------------------------
+
+type fetchFunc func(ctx context.Context, keys []uint64) (map[uint64]interface{}, error)
+
+// loader collects keys requested by resolvers running concurrently and fetches
+// them with a single query. Values are cached for the lifetime of the loader.
+type loader struct {
+	fetch fetchFunc
+
+	mu    sync.Mutex
+	cache map[uint64]*loaderBatch
+	batch *loaderBatch
+}
+
+type loaderBatch struct {
+	keys []uint64
+	done chan struct{}
+
+	values map[uint64]interface{}
+	err    error
+}
+
+func newLoader(fetch fetchFunc) *loader {
+	return &loader{
+		fetch: fetch,
+		cache: make(map[uint64]*loaderBatch),
+	}
+}
+
+func (l *loader) Load(ctx context.Context, key uint64) (interface{}, error) {
+	l.mu.Lock()
+
+	b, ok := l.cache[key]
+	if !ok {
+		if l.batch == nil {
+			l.batch = &loaderBatch{done: make(chan struct{})}
+			go l.run(ctx, l.batch)
+		}
+
+		b = l.batch
+		b.keys = append(b.keys, key)
+		l.cache[key] = b
+
+		if len(b.keys) >= loaderMaxBatch {
+			l.batch = nil
+		}
+	}
+
+	l.mu.Unlock()
+
+	select {
+	case <-b.done:
+		return b.values[key], b.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (l *loader) run(ctx context.Context, b *loaderBatch) {
+	time.Sleep(loaderWait)
+
+	l.mu.Lock()
+	if l.batch == b {
+		l.batch = nil
+	}
+	keys := b.keys
+	l.mu.Unlock()
+
+	b.values, b.err = l.fetch(ctx, keys)
+	close(b.done)
+}
@@ -0,0 +1,284 @@
+package gql
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/graph-gophers/graphql-go"
+
+	"github.com/uptrace/bun-realworld-app/blog"
+	"github.com/uptrace/bun-realworld-app/org"
+)
+
+type remoteIPCtxKey struct{}
+
+type registerInput struct {
+	Username string
+	Email    string
+	Password string
+}
+
+func (r *Resolver) Register(
+	ctx context.Context, args struct{ Input registerInput },
+) (*authPayloadResolver, error) {
+	user := &org.User{
+		Username: args.Input.Username,
+		Email:    args.Input.Email,
+		Password: args.Input.Password,
+	}
+	if err := r.users.Create(ctx, user); err != nil {
+		return nil, err
+	}
+	return r.authPayload(user)
+}
+
+func (r *Resolver) Login(
+	ctx context.Context, args struct{ Email, Password string },
+) (*authPayloadResolver, error) {
+	ip, _ := ctx.Value(remoteIPCtxKey{}).(string)
+
+	user, err := r.users.Login(ctx, args.Email, args.Password, ip)
+	if err != nil {
+		return nil, err
+	}
+	return r.authPayload(user)
+}
+
+type loginSecondFactorArgs struct {
+	ChallengeToken string
+	Code           *string
+	RecoveryCode   *string
+}
+
+func (r *Resolver) LoginSecondFactor(
+	ctx context.Context, args loginSecondFactorArgs,
+) (*authPayloadResolver, error) {
+	var code, recoveryCode string
+	if args.Code != nil {
+		code = *args.Code
+	}
+	if args.RecoveryCode != nil {
+		recoveryCode = *args.RecoveryCode
+	}
+
+	user, err := r.users.LoginSecondFactor(ctx, args.ChallengeToken, code, recoveryCode)
+	if err != nil {
+		return nil, err
+	}
+	return r.userPayload(user)
+}
+
+func (r *Resolver) authPayload(user *org.User) (*authPayloadResolver, error) {
+	if user.TOTPEnabled {
+		token, err := org.CreateChallengeToken(r.app, user.ID, org.ChallengeTTL)
+		if err != nil {
+			return nil, err
+		}
+		return &authPayloadResolver{
+			challenge: &challengeResolver{
+				token:     token,
+				expiresAt: r.app.Clock().Now().Add(org.ChallengeTTL),
+			},
+		}, nil
+	}
+	return r.userPayload(user)
+}
+
+func (r *Resolver) userPayload(user *org.User) (*authPayloadResolver, error) {
+	token, err := org.CreateUserToken(r.app, user.ID, 24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	user.Token = token
+
+	return &authPayloadResolver{user: user}, nil
+}
+
+type updateUserInput struct {
+	Username *string
+	Email    *string
+	Bio      *string
+	Image    *string
+	Password *string
+}
+
+func (r *Resolver) UpdateUser(
+	ctx context.Context, args struct{ Input updateUserInput },
+) (*userResolver, error) {
+	user, err := mustUserWithScope(ctx, org.ScopeProfileWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	in := &org.UserUpdate{
+		Username: args.Input.Username,
+		Email:    args.Input.Email,
+		Bio:      args.Input.Bio,
+		Image:    args.Input.Image,
+		Password: args.Input.Password,
+	}
+	if err := r.users.Update(ctx, user, in); err != nil {
+		return nil, err
+	}
+	return &userResolver{user: user}, nil
+}
+
+func (r *Resolver) Follow(ctx context.Context, args struct{ Username string }) (*profileResolver, error) {
+	user, err := mustUserWithScope(ctx, org.ScopeProfileWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	profile, err := r.users.Follow(ctx, user, args.Username)
+	if err != nil {
+		return nil, err
+	}
+	return &profileResolver{r: r, profile: profile}, nil
+}
+
+func (r *Resolver) Unfollow(ctx context.Context, args struct{ Username string }) (*profileResolver, error) {
+	user, err := mustUserWithScope(ctx, org.ScopeProfileWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	profile, err := r.users.Unfollow(ctx, user, args.Username)
+	if err != nil {
+		return nil, err
+	}
+	return &profileResolver{r: r, profile: profile}, nil
+}
+
+type articleInput struct {
+	Title       string
+	Description string
+	Body        string
+	TagList     *[]string
+}
+
+func (in articleInput) article() *blog.Article {
+	article := &blog.Article{
+		Title:       in.Title,
+		Description: in.Description,
+		Body:        in.Body,
+	}
+	if in.TagList != nil {
+		article.TagList = *in.TagList
+	}
+	return article
+}
+
+func (r *Resolver) CreateArticle(
+	ctx context.Context, args struct{ Input articleInput },
+) (*articleResolver, error) {
+	user, err := mustUserWithScope(ctx, org.ScopeArticlesWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	article := args.Input.article()
+	if err := r.articles.Create(ctx, user, article); err != nil {
+		return nil, err
+	}
+	return &articleResolver{r: r, article: article}, nil
+}
+
+func (r *Resolver) UpdateArticle(
+	ctx context.Context, args struct {
+		Slug  string
+		Input articleInput
+	},
+) (*articleResolver, error) {
+	user, err := mustUserWithScope(ctx, org.ScopeArticlesWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	article, err := r.articles.Update(ctx, user, args.Slug, args.Input.article())
+	if err != nil {
+		return nil, err
+	}
+	return &articleResolver{r: r, article: article}, nil
+}
+
+func (r *Resolver) DeleteArticle(ctx context.Context, args struct{ Slug string }) (bool, error) {
+	user, err := mustUserWithScope(ctx, org.ScopeArticlesWrite)
+	if err != nil {
+		return false, err
+	}
+
+	if err := r.articles.Delete(ctx, user, args.Slug); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *Resolver) Favorite(ctx context.Context, args struct{ Slug string }) (*articleResolver, error) {
+	user, err := mustUserWithScope(ctx, org.ScopeFavoritesWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	f := articleFilter(ctx, pageArgs{})
+	f.Slug = args.Slug
+
+	article, err := r.articles.Favorite(ctx, user, f)
+	if err != nil {
+		return nil, err
+	}
+	return &articleResolver{r: r, article: article}, nil
+}
+
+func (r *Resolver) Unfavorite(ctx context.Context, args struct{ Slug string }) (*articleResolver, error) {
+	user, err := mustUserWithScope(ctx, org.ScopeFavoritesWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	f := articleFilter(ctx, pageArgs{})
+	f.Slug = args.Slug
+
+	article, err := r.articles.Unfavorite(ctx, user, f)
+	if err != nil {
+		return nil, err
+	}
+	return &articleResolver{r: r, article: article}, nil
+}
+
+func (r *Resolver) AddComment(
+	ctx context.Context, args struct{ Slug, Body string },
+) (*commentResolver, error) {
+	user, err := mustUserWithScope(ctx, org.ScopeCommentsWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	comment := &blog.Comment{Body: args.Body}
+	if err := r.comments.Create(ctx, user, args.Slug, comment); err != nil {
+		return nil, err
+	}
+	return &commentResolver{r: r, comment: comment}, nil
+}
+
+func (r *Resolver) DeleteComment(
+	ctx context.Context, args struct {
+		Slug string
+		ID   graphql.ID
+	},
+) (bool, error) {
+	user, err := mustUserWithScope(ctx, org.ScopeCommentsWrite)
+	if err != nil {
+		return false, err
+	}
+
+	id, err := strconv.ParseUint(string(args.ID), 10, 64)
+	if err != nil {
+		return false, blog.ErrCommentNotFound
+	}
+
+	if err := r.comments.Delete(ctx, user, args.Slug, id); err != nil {
+		return false, err
+	}
+	return true, nil
+}
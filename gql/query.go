@@ -0,0 +1,99 @@
+package gql
+
+import (
+	"context"
+
+	"github.com/uptrace/bun-realworld-app/blog"
+	"github.com/uptrace/bun-realworld-app/org"
+)
+
+func (r *Resolver) Viewer(ctx context.Context) *userResolver {
+	user := org.UserFromContext(ctx)
+	if user == nil {
+		return nil
+	}
+	return &userResolver{user: user}
+}
+
+func (r *Resolver) Profile(ctx context.Context, args struct{ Username string }) (*profileResolver, error) {
+	profile, err := r.users.Profile(ctx, org.UserFromContext(ctx), args.Username)
+	if err != nil {
+		if err == org.ErrUserNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &profileResolver{r: r, profile: profile}, nil
+}
+
+func (r *Resolver) Article(ctx context.Context, args struct{ Slug string }) (*articleResolver, error) {
+	f := articleFilter(ctx, pageArgs{})
+	f.Slug = args.Slug
+
+	article, err := r.articles.Get(ctx, f)
+	if err != nil {
+		if err == blog.ErrArticleNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &articleResolver{r: r, article: article}, nil
+}
+
+type articlesArgs struct {
+	Tag       *string
+	Author    *string
+	Favorited *string
+	pageArgs
+}
+
+func (r *Resolver) Articles(ctx context.Context, args articlesArgs) ([]*articleResolver, error) {
+	f := articleFilter(ctx, args.pageArgs)
+	if args.Tag != nil {
+		f.Tag = *args.Tag
+	}
+	if args.Author != nil {
+		f.Author = *args.Author
+	}
+	if args.Favorited != nil {
+		f.Favorited = *args.Favorited
+	}
+
+	articles, err := r.articles.List(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	return r.articleResolvers(articles), nil
+}
+
+func (r *Resolver) Feed(ctx context.Context, args pageArgs) ([]*articleResolver, error) {
+	if _, err := org.MustUserFromContext(ctx); err != nil {
+		return nil, err
+	}
+
+	articles, err := r.articles.Feed(ctx, articleFilter(ctx, args))
+	if err != nil {
+		return nil, err
+	}
+	return r.articleResolvers(articles), nil
+}
+
+func (r *Resolver) Comments(ctx context.Context, args struct{ Slug string }) ([]*commentResolver, error) {
+	comments, err := r.comments.List(ctx, org.UserFromContext(ctx), args.Slug)
+	if err != nil {
+		return nil, err
+	}
+	return r.commentResolvers(comments), nil
+}
+
+func (r *Resolver) Tags(ctx context.Context) ([]string, error) {
+	return r.articles.Tags(ctx)
+}
+
+func (r *Resolver) articleResolvers(articles []*blog.Article) []*articleResolver {
+	rs := make([]*articleResolver, len(articles))
+	for i, article := range articles {
+		rs[i] = &articleResolver{r: r, article: article}
+	}
+	return rs
+}
@@ -0,0 +1,85 @@
+package gql
+
+import (
+	"context"
+	_ "embed"
+
+	"github.com/go-pg/urlstruct"
+	"github.com/graph-gophers/graphql-go"
+
+	"github.com/uptrace/bun-realworld-app/blog"
+	"github.com/uptrace/bun-realworld-app/bunapp"
+	"github.com/uptrace/bun-realworld-app/org"
+)
+
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+	maxDepth     = 10
+)
+
+//go:embed schema.graphql
+var schemaString string
+
+func NewSchema(app *bunapp.App) (*graphql.Schema, error) {
+	return graphql.ParseSchema(schemaString, newResolver(app), graphql.MaxDepth(maxDepth))
+}
+
+// Resolver resolves the Query and Mutation root types.
+type Resolver struct {
+	app      *bunapp.App
+	users    *org.UserService
+	articles *blog.ArticleService
+	comments *blog.CommentService
+}
+
+func newResolver(app *bunapp.App) *Resolver {
+	return &Resolver{
+		app:      app,
+		users:    org.NewUserService(app),
+		articles: blog.NewArticleService(app),
+		comments: blog.NewCommentService(app),
+	}
+}
+
+type pageArgs struct {
+	Limit  int32
+	Offset int32
+}
+
+func (a pageArgs) pager() urlstruct.Pager {
+	pager := urlstruct.Pager{
+		Limit:    defaultLimit,
+		MaxLimit: maxLimit,
+	}
+	if a.Limit > 0 {
+		pager.Limit = int(a.Limit)
+	}
+	if a.Offset > 0 {
+		pager.Offset = int(a.Offset)
+	}
+	return pager
+}
+
+// articleFilter returns a filter that leaves authors and tags to the loaders.
+func articleFilter(ctx context.Context, page pageArgs) *blog.ArticleFilter {
+	f := &blog.ArticleFilter{
+		NoRelations: true,
+		Pager:       page.pager(),
+	}
+	if user := org.UserFromContext(ctx); user != nil {
+		f.UserID = user.ID
+	}
+	return f
+}
+
+func mustUserWithScope(ctx context.Context, scope string) (*org.User, error) {
+	user, err := org.MustUserFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := org.CheckScope(ctx, scope); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
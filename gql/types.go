@@ -0,0 +1,214 @@
+package gql
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/graph-gophers/graphql-go"
+
+	"github.com/uptrace/bun-realworld-app/blog"
+	"github.com/uptrace/bun-realworld-app/org"
+)
+
+type userResolver struct {
+	user *org.User
+}
+
+func (u *userResolver) Username() string { return u.user.Username }
+func (u *userResolver) Email() string    { return u.user.Email }
+func (u *userResolver) Bio() string      { return u.user.Bio }
+func (u *userResolver) Image() string    { return u.user.Image }
+
+func (u *userResolver) Token() *string {
+	if u.user.Token == "" {
+		return nil
+	}
+	return &u.user.Token
+}
+
+//------------------------------------------------------------------------------
+
+type authPayloadResolver struct {
+	user      *org.User
+	challenge *challengeResolver
+}
+
+func (p *authPayloadResolver) User() *userResolver {
+	if p.user == nil {
+		return nil
+	}
+	return &userResolver{user: p.user}
+}
+
+func (p *authPayloadResolver) Challenge() *challengeResolver {
+	return p.challenge
+}
+
+type challengeResolver struct {
+	token     string
+	expiresAt time.Time
+}
+
+func (c *challengeResolver) Token() string           { return c.token }
+func (c *challengeResolver) Type() string            { return "totp" }
+func (c *challengeResolver) ExpiresAt() graphql.Time { return graphql.Time{Time: c.expiresAt} }
+
+//------------------------------------------------------------------------------
+
+type profileResolver struct {
+	r       *Resolver
+	profile *org.Profile
+}
+
+func (p *profileResolver) Username() string { return p.profile.Username }
+func (p *profileResolver) Bio() string      { return p.profile.Bio }
+func (p *profileResolver) Following() bool  { return p.profile.Following }
+
+func (p *profileResolver) Image() string {
+	if p.profile.Image == "" {
+		return org.AvatarURL(p.profile.Username)
+	}
+	return p.profile.Image
+}
+
+func (p *profileResolver) FollowersCount() int32 { return int32(p.profile.FollowersCount) }
+func (p *profileResolver) FollowingCount() int32 { return int32(p.profile.FollowingCount) }
+
+func (p *profileResolver) Articles(ctx context.Context, args pageArgs) ([]*articleResolver, error) {
+	f := articleFilter(ctx, args)
+	f.Author = p.profile.Username
+
+	articles, err := p.r.articles.List(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	return p.r.articleResolvers(articles), nil
+}
+
+//------------------------------------------------------------------------------
+
+type articleResolver struct {
+	r       *Resolver
+	article *blog.Article
+}
+
+func (a *articleResolver) Slug() string        { return a.article.Slug }
+func (a *articleResolver) Title() string       { return a.article.Title }
+func (a *articleResolver) Description() string { return a.article.Description }
+func (a *articleResolver) Body() string        { return a.article.Body }
+func (a *articleResolver) BodyHTML() string    { return a.article.BodyHTML }
+func (a *articleResolver) Favorited() bool     { return a.article.Favorited }
+func (a *articleResolver) Bookmarked() bool    { return a.article.Bookmarked }
+func (a *articleResolver) FavoritesCount() int32 {
+	return int32(a.article.FavoritesCount)
+}
+
+func (a *articleResolver) CommentsCount() int32 {
+	return int32(a.article.CommentsCount)
+}
+
+func (a *articleResolver) WordCount() int32 {
+	return int32(a.article.WordCount)
+}
+
+func (a *articleResolver) ReadingTimeMinutes() int32 {
+	return int32(a.article.ReadingTimeMinutes)
+}
+
+func (a *articleResolver) Excerpt() string { return a.article.Excerpt }
+
+func (a *articleResolver) TOC() []*tocEntryResolver {
+	entries := make([]*tocEntryResolver, len(a.article.TOC))
+	for i := range a.article.TOC {
+		entries[i] = &tocEntryResolver{entry: &a.article.TOC[i]}
+	}
+	return entries
+}
+
+func (a *articleResolver) CreatedAt() graphql.Time {
+	return graphql.Time{Time: a.article.CreatedAt}
+}
+
+func (a *articleResolver) UpdatedAt() graphql.Time {
+	return graphql.Time{Time: a.article.UpdatedAt}
+}
+
+func (a *articleResolver) TagList(ctx context.Context) ([]string, error) {
+	if a.article.TagList != nil {
+		return a.article.TagList, nil
+	}
+	return loadersFromContext(ctx).Tags(ctx, a.article.ID)
+}
+
+func (a *articleResolver) Author(ctx context.Context) (*profileResolver, error) {
+	profile := a.article.Author
+	if profile == nil {
+		var err error
+		profile, err = loadersFromContext(ctx).Profile(ctx, a.article.AuthorID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &profileResolver{r: a.r, profile: profile}, nil
+}
+
+func (a *articleResolver) Comments(ctx context.Context) ([]*commentResolver, error) {
+	comments, err := a.r.comments.List(ctx, org.UserFromContext(ctx), a.article.Slug)
+	if err != nil {
+		return nil, err
+	}
+	return a.r.commentResolvers(comments), nil
+}
+
+//------------------------------------------------------------------------------
+
+type tocEntryResolver struct {
+	entry *blog.TOCEntry
+}
+
+func (e *tocEntryResolver) Level() int32 { return int32(e.entry.Level) }
+func (e *tocEntryResolver) Text() string { return e.entry.Text }
+func (e *tocEntryResolver) ID() string   { return e.entry.ID }
+
+//------------------------------------------------------------------------------
+
+type commentResolver struct {
+	r       *Resolver
+	comment *blog.Comment
+}
+
+func (c *commentResolver) ID() graphql.ID {
+	return graphql.ID(strconv.FormatUint(c.comment.ID, 10))
+}
+
+func (c *commentResolver) Body() string     { return c.comment.Body }
+func (c *commentResolver) BodyHTML() string { return c.comment.BodyHTML }
+
+func (c *commentResolver) CreatedAt() graphql.Time {
+	return graphql.Time{Time: c.comment.CreatedAt}
+}
+
+func (c *commentResolver) UpdatedAt() graphql.Time {
+	return graphql.Time{Time: c.comment.UpdatedAt}
+}
+
+func (c *commentResolver) Author(ctx context.Context) (*profileResolver, error) {
+	profile := c.comment.Author
+	if profile == nil {
+		var err error
+		profile, err = loadersFromContext(ctx).Profile(ctx, c.comment.AuthorID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &profileResolver{r: c.r, profile: profile}, nil
+}
+
+func (r *Resolver) commentResolvers(comments []*blog.Comment) []*commentResolver {
+	rs := make([]*commentResolver, len(comments))
+	for i, comment := range comments {
+		rs[i] = &commentResolver{r: r, comment: comment}
+	}
+	return rs
+}
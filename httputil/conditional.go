@@ -0,0 +1,84 @@
+package httputil
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bunrouter"
+)
+
+// ConditionalJSON works like bunrouter.JSON, but also sets the ETag computed
+// from the encoded value and the Last-Modified header when lastModified is not zero.
+// It replies with 304 Not Modified when the request preconditions match.
+func ConditionalJSON(
+	w http.ResponseWriter, req bunrouter.Request, value interface{}, lastModified time.Time,
+) error {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(value); err != nil {
+		return err
+	}
+	return Conditional(w, req, "application/json", buf.Bytes(), lastModified)
+}
+
+// Conditional is like ConditionalJSON, but writes an already encoded body.
+func Conditional(
+	w http.ResponseWriter, req bunrouter.Request, contentType string, body []byte, lastModified time.Time,
+) error {
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	h := w.Header()
+	h.Set("ETag", etag)
+	if !lastModified.IsZero() {
+		h.Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if notModified(req, etag, lastModified) {
+		h.Del("Content-Type")
+		h.Del("Content-Length")
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	h.Set("Content-Type", contentType)
+	_, err := w.Write(body)
+	return err
+}
+
+func notModified(req bunrouter.Request, etag string, lastModified time.Time) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+
+	// If-None-Match takes precedence, see RFC 7232, section 6.
+	if inm := req.Header.Get("If-None-Match"); inm != "" {
+		return etagMatch(inm, etag)
+	}
+
+	if lastModified.IsZero() {
+		return false
+	}
+	ims, err := http.ParseTime(req.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	// HTTP dates have a second precision.
+	return !lastModified.Truncate(time.Second).After(ims)
+}
+
+// etagMatch uses the weak comparison function, because 304 responses are
+// allowed for semantically equivalent representations.
+func etagMatch(header, etag string) bool {
+	for _, s := range strings.Split(header, ",") {
+		s = strings.TrimSpace(s)
+		if s == "*" || strings.TrimPrefix(s, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
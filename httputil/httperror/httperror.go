@@ -3,6 +3,7 @@ package httperror
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -62,5 +63,10 @@ func From(err error) Error {
 		return BadRequest("json_syntax", err.Error())
 	}
 
+	var httpErr Error
+	if errors.As(err, &httpErr) {
+		return httpErr
+	}
+
 	return ErrInternal
 }
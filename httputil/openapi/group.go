@@ -0,0 +1,174 @@
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+
+	"github.com/uptrace/bunrouter"
+)
+
+// Option describes an operation.
+type Option func(s *Spec, op *Operation)
+
+func Summary(summary string) Option {
+	return func(s *Spec, op *Operation) {
+		op.Summary = summary
+	}
+}
+
+// Tags replaces the tags set by the group.
+func Tags(tags ...string) Option {
+	return func(s *Spec, op *Operation) {
+		op.Tags = tags
+	}
+}
+
+// Request sets the JSON request body to the type of v.
+func Request(v interface{}) Option {
+	return func(s *Spec, op *Operation) {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content: map[string]MediaType{
+				jsonContentType: {Schema: s.schema(reflect.TypeOf(v))},
+			},
+		}
+	}
+}
+
+// Returns sets the JSON response body for the status to the type of v.
+// A nil v documents a response without a body.
+func Returns(status int, v interface{}) Option {
+	return func(s *Spec, op *Operation) {
+		if op.Responses == nil {
+			op.Responses = make(map[string]*Response)
+		}
+
+		key := strconv.Itoa(status)
+		if v == nil {
+			op.Responses[key] = &Response{Description: http.StatusText(status)}
+			return
+		}
+		op.Responses[key] = jsonResponse(http.StatusText(status), s.schema(reflect.TypeOf(v)))
+	}
+}
+
+// Multipart sets the request body to a multipart form with a single file field.
+func Multipart(field string) Option {
+	return func(s *Spec, op *Operation) {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content: map[string]MediaType{
+				"multipart/form-data": {Schema: &Schema{
+					Type: "object",
+					Properties: map[string]*Schema{
+						field: {Type: "string", Format: "binary"},
+					},
+					Required: []string{field},
+				}},
+			},
+		}
+	}
+}
+
+// ReturnsBinary adds a non-JSON response body for the status, e.g. an image.
+// It can be used several times to document alternative content types.
+func ReturnsBinary(status int, contentType string) Option {
+	return func(s *Spec, op *Operation) {
+		if op.Responses == nil {
+			op.Responses = make(map[string]*Response)
+		}
+
+		key := strconv.Itoa(status)
+		resp, ok := op.Responses[key]
+		if !ok || resp.Content == nil {
+			resp = &Response{
+				Description: http.StatusText(status),
+				Content:     make(map[string]MediaType),
+			}
+			op.Responses[key] = resp
+		}
+		resp.Content[contentType] = MediaType{Schema: &Schema{Type: "string", Format: "binary"}}
+	}
+}
+
+// Query documents an optional query parameter.
+func Query(name, description string) Option {
+	return func(s *Spec, op *Operation) {
+		op.Parameters = append(op.Parameters, &Parameter{
+			Name:        name,
+			In:          "query",
+			Description: description,
+			Schema:      &Schema{Type: "string"},
+		})
+	}
+}
+
+// Security marks the operation as requiring the security scheme.
+func Security(scheme string, scopes ...string) Option {
+	return func(s *Spec, op *Operation) {
+		if scopes == nil {
+			scopes = make([]string, 0)
+		}
+		op.Security = append(op.Security, map[string][]string{scheme: scopes})
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// Group registers routes with a bunrouter.Group and documents them in the Spec.
+type Group struct {
+	group *bunrouter.Group
+	spec  *Spec
+	path  string
+	opts  []Option
+}
+
+// NewGroup wraps the group; path is the prefix the group was created with.
+func NewGroup(group *bunrouter.Group, spec *Spec, path string) *Group {
+	return &Group{
+		group: group,
+		spec:  spec,
+		path:  path,
+	}
+}
+
+// WithMiddleware returns a group that uses the middleware and documents
+// its routes with the options, e.g. Security for auth middlewares.
+func (g *Group) WithMiddleware(middleware bunrouter.MiddlewareFunc, opts ...Option) *Group {
+	return &Group{
+		group: g.group.WithMiddleware(middleware),
+		spec:  g.spec,
+		path:  g.path,
+		opts:  append(g.opts[:len(g.opts):len(g.opts)], opts...),
+	}
+}
+
+func (g *Group) Handle(method, path string, handler bunrouter.HandlerFunc, opts ...Option) {
+	g.group.Handle(method, path, handler)
+
+	op := new(Operation)
+	for _, opt := range g.opts {
+		opt(g.spec, op)
+	}
+	for _, opt := range opts {
+		opt(g.spec, op)
+	}
+	g.spec.Add(method, g.path+path, op)
+}
+
+func (g *Group) GET(path string, handler bunrouter.HandlerFunc, opts ...Option) {
+	g.Handle("GET", path, handler, opts...)
+}
+
+func (g *Group) POST(path string, handler bunrouter.HandlerFunc, opts ...Option) {
+	g.Handle("POST", path, handler, opts...)
+}
+
+func (g *Group) PUT(path string, handler bunrouter.HandlerFunc, opts ...Option) {
+	g.Handle("PUT", path, handler, opts...)
+}
+
+func (g *Group) DELETE(path string, handler bunrouter.HandlerFunc, opts ...Option) {
+	g.Handle("DELETE", path, handler, opts...)
+}
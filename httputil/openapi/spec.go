@@ -0,0 +1,292 @@
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const jsonContentType = "application/json"
+
+// Spec collects operations while routes are registered and
+// produces an OpenAPI 3 document from them.
+type Spec struct {
+	mu  sync.Mutex
+	doc Document
+
+	defaultResponse *Schema
+	typeNames       map[reflect.Type]string
+}
+
+func NewSpec(title, version string) *Spec {
+	return &Spec{
+		doc: Document{
+			OpenAPI: "3.0.3",
+			Info: Info{
+				Title:   title,
+				Version: version,
+			},
+			Paths: make(map[string]PathItem),
+			Components: Components{
+				Schemas:         make(map[string]*Schema),
+				SecuritySchemes: make(map[string]*SecurityScheme),
+			},
+		},
+		typeNames: make(map[reflect.Type]string),
+	}
+}
+
+// AddSecurityScheme registers a scheme that operations can refer to with Security.
+func (s *Spec) AddSecurityScheme(name string, scheme *SecurityScheme) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.doc.Components.SecuritySchemes[name] = scheme
+}
+
+// SetDefaultResponse sets the body returned on errors by all operations.
+func (s *Spec) SetDefaultResponse(v interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.defaultResponse = s.schema(reflect.TypeOf(v))
+}
+
+// Add adds the operation. The path uses the router syntax, e.g. /articles/:slug.
+func (s *Spec) Add(method, path string, op *Operation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path, params := convertPath(path)
+	op.Parameters = append(params, op.Parameters...)
+
+	if op.Responses == nil {
+		op.Responses = make(map[string]*Response)
+	}
+	if len(op.Responses) == 0 {
+		op.Responses["200"] = &Response{Description: "OK"}
+	}
+	if _, ok := op.Responses["default"]; !ok && s.defaultResponse != nil {
+		op.Responses["default"] = jsonResponse("Error", s.defaultResponse)
+	}
+
+	item, ok := s.doc.Paths[path]
+	if !ok {
+		item = make(PathItem)
+		s.doc.Paths[path] = item
+	}
+	item[strings.ToLower(method)] = op
+}
+
+// Schema returns the schema for the type of v. Named struct types
+// are added to the components and referenced with $ref.
+func (s *Spec) Schema(v interface{}) *Schema {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.schema(reflect.TypeOf(v))
+}
+
+// Document returns a copy of the document safe to marshal concurrently
+// with route registration.
+func (s *Spec) Document() Document {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, err := json.Marshal(s.doc)
+	if err != nil {
+		panic(err)
+	}
+
+	var doc Document
+	if err := json.Unmarshal(b, &doc); err != nil {
+		panic(err)
+	}
+	return doc
+}
+
+// ServeHTTP serves the document as JSON.
+func (s *Spec) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", jsonContentType)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(s.Document())
+}
+
+// convertPath converts router params to OpenAPI templates, e.g. :slug to {slug}.
+func convertPath(path string) (string, []*Parameter) {
+	var params []*Parameter
+
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		if part == "" || (part[0] != ':' && part[0] != '*') {
+			continue
+		}
+
+		name := part[1:]
+		parts[i] = "{" + name + "}"
+		params = append(params, &Parameter{
+			Name:     name,
+			In:       "path",
+			Required: true,
+			Schema:   &Schema{Type: "string"},
+		})
+	}
+
+	return strings.Join(parts, "/"), params
+}
+
+func jsonResponse(description string, schema *Schema) *Response {
+	return &Response{
+		Description: description,
+		Content: map[string]MediaType{
+			jsonContentType: {Schema: schema},
+		},
+	}
+}
+
+//------------------------------------------------------------------------------
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+func (s *Spec) schema(typ reflect.Type) *Schema {
+	if typ == nil {
+		return &Schema{}
+	}
+
+	switch typ {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	if typ.Kind() != reflect.Ptr {
+		if typ.Implements(jsonMarshalerType) {
+			return &Schema{}
+		}
+		if typ.Implements(textMarshalerType) {
+			return &Schema{Type: "string"}
+		}
+	}
+
+	switch typ.Kind() {
+	case reflect.Ptr:
+		schema := s.schema(typ.Elem())
+		if schema.Ref != "" {
+			// Siblings of $ref are ignored, so it is wrapped to be nullable.
+			return &Schema{AllOf: []*Schema{schema}, Nullable: true}
+		}
+		schema.Nullable = true
+		return schema
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{
+			Type:     "array",
+			Items:    s.schema(typ.Elem()),
+			Nullable: typ.Kind() == reflect.Slice,
+		}
+	case reflect.Map:
+		return &Schema{
+			Type:                 "object",
+			AdditionalProperties: s.schema(typ.Elem()),
+			Nullable:             true,
+		}
+	case reflect.Struct:
+		if typ.Name() == "" {
+			return s.structSchema(typ)
+		}
+		return s.namedSchema(typ)
+	default:
+		return &Schema{}
+	}
+}
+
+func (s *Spec) namedSchema(typ reflect.Type) *Schema {
+	if name, ok := s.typeNames[typ]; ok {
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+
+	name := typ.Name()
+	if _, ok := s.doc.Components.Schemas[name]; ok {
+		pkg := typ.PkgPath()
+		if i := strings.LastIndexByte(pkg, '/'); i >= 0 {
+			pkg = pkg[i+1:]
+		}
+		name = pkg + "." + name
+	}
+
+	// Register the name first so recursive types terminate.
+	s.typeNames[typ] = name
+	s.doc.Components.Schemas[name] = &Schema{}
+	*s.doc.Components.Schemas[name] = *s.structSchema(typ)
+
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+func (s *Spec) structSchema(typ reflect.Type) *Schema {
+	schema := &Schema{
+		Type:       "object",
+		Properties: make(map[string]*Schema),
+	}
+	s.addFields(schema, typ)
+	sort.Strings(schema.Required)
+	return schema
+}
+
+func (s *Spec) addFields(schema *Schema, typ reflect.Type) {
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if i := strings.IndexByte(tag, ','); i >= 0 {
+			name, opts = tag[:i], tag[i:]
+		}
+
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				s.addFields(schema, ft)
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		schema.Properties[name] = s.schema(f.Type)
+		if !strings.Contains(opts, ",omitempty") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Validator checks requests and responses against the document.
+type Validator struct {
+	doc Document
+}
+
+// NewValidator snapshots the spec, so it must be called after routes are registered.
+func NewValidator(spec *Spec) *Validator {
+	return &Validator{
+		doc: spec.Document(),
+	}
+}
+
+// Middleware returns a handler that validates requests and responses of next
+// and passes violations to onError. Invalid traffic is never blocked.
+func (v *Validator) Middleware(next http.Handler, onError func(error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		op, err := v.operation(req.Method, req.URL.Path)
+		if err != nil {
+			onError(err)
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		if req.Body != nil && op.RequestBody != nil {
+			body, err := ioutil.ReadAll(req.Body)
+			if err != nil {
+				onError(fmt.Errorf("%s %s: request: %w", req.Method, req.URL.Path, err))
+				// Let next see the same read error.
+				req.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(body), errReader{err}))
+			} else {
+				req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+				if err := v.ValidateRequest(op, req.Header.Get("Content-Type"), body); err != nil {
+					onError(fmt.Errorf("%s %s: request: %w", req.Method, req.URL.Path, err))
+				}
+			}
+		}
+
+		rw := &recordingWriter{ResponseWriter: w}
+		next.ServeHTTP(rw, req)
+
+		if err := v.ValidateResponse(
+			op, rw.statusCode(), rw.Header().Get("Content-Type"), rw.body.Bytes(),
+		); err != nil {
+			onError(fmt.Errorf("%s %s: response %d: %w", req.Method, req.URL.Path, rw.statusCode(), err))
+		}
+	})
+}
+
+// ValidateRequest validates a JSON request body.
+func (v *Validator) ValidateRequest(op *Operation, contentType string, body []byte) error {
+	if op.RequestBody == nil || len(bytes.TrimSpace(body)) == 0 || !isJSON(contentType) {
+		return nil
+	}
+
+	media, ok := op.RequestBody.Content[jsonContentType]
+	if !ok {
+		return fmt.Errorf("operation does not accept %s", contentType)
+	}
+	return v.validateBody(media.Schema, body)
+}
+
+// ValidateResponse validates a JSON response body.
+func (v *Validator) ValidateResponse(op *Operation, status int, contentType string, body []byte) error {
+	resp, ok := op.Responses[strconv.Itoa(status)]
+	if !ok {
+		resp, ok = op.Responses["default"]
+	}
+	if !ok {
+		return fmt.Errorf("status %d is not documented", status)
+	}
+
+	if len(bytes.TrimSpace(body)) == 0 || !isJSON(contentType) {
+		return nil
+	}
+
+	media, ok := resp.Content[jsonContentType]
+	if !ok {
+		return fmt.Errorf("status %d is documented without a JSON body", status)
+	}
+	return v.validateBody(media.Schema, body)
+}
+
+func (v *Validator) validateBody(schema *Schema, body []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return err
+	}
+
+	var errs []string
+	v.validate(schema, value, "$", &errs)
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// operation finds the operation for the path, preferring static segments
+// over params like the router does.
+func (v *Validator) operation(method, path string) (*Operation, error) {
+	segments := strings.Split(path, "/")
+
+	var found PathItem
+	bestScore := -1
+	for tmpl, item := range v.doc.Paths {
+		score, ok := matchPath(strings.Split(tmpl, "/"), segments)
+		if ok && score > bestScore {
+			found, bestScore = item, score
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%s %s: route is not documented", method, path)
+	}
+
+	op, ok := found[strings.ToLower(method)]
+	if !ok {
+		return nil, fmt.Errorf("%s %s: method is not documented", method, path)
+	}
+	return op, nil
+}
+
+func matchPath(tmpl, segments []string) (int, bool) {
+	if len(tmpl) != len(segments) {
+		return 0, false
+	}
+
+	var score int
+	for i, part := range tmpl {
+		if strings.HasPrefix(part, "{") {
+			if segments[i] == "" {
+				return 0, false
+			}
+			continue
+		}
+		if part != segments[i] {
+			return 0, false
+		}
+		score++
+	}
+	return score, true
+}
+
+func (v *Validator) resolve(schema *Schema) *Schema {
+	for schema.Ref != "" {
+		name := strings.TrimPrefix(schema.Ref, "#/components/schemas/")
+		schema = v.doc.Components.Schemas[name]
+	}
+	return schema
+}
+
+func (v *Validator) validate(schema *Schema, value interface{}, path string, errs *[]string) {
+	nullable := schema.Nullable
+	schema = v.resolve(schema)
+	nullable = nullable || schema.Nullable
+
+	if value == nil {
+		if !nullable && (schema.Type != "" || len(schema.AllOf) > 0) {
+			*errs = append(*errs, path+": must not be null")
+		}
+		return
+	}
+
+	for _, s := range schema.AllOf {
+		v.validate(s, value, path, errs)
+	}
+
+	fail := func() {
+		*errs = append(*errs, fmt.Sprintf("%s: %s is not %s", path, jsonType(value), schema.Type))
+	}
+
+	switch schema.Type {
+	case "":
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			fail()
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			fail()
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			fail()
+		}
+	case "integer":
+		if f, ok := value.(float64); !ok || f != math.Trunc(f) {
+			fail()
+		}
+	case "array":
+		list, ok := value.([]interface{})
+		if !ok {
+			fail()
+			return
+		}
+		if schema.Items != nil {
+			for i, el := range list {
+				v.validate(schema.Items, el, fmt.Sprintf("%s[%d]", path, i), errs)
+			}
+		}
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			fail()
+			return
+		}
+		for _, name := range schema.Required {
+			if _, ok := obj[name]; !ok {
+				*errs = append(*errs, fmt.Sprintf("%s: %q is required", path, name))
+			}
+		}
+
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			if prop, ok := schema.Properties[k]; ok {
+				v.validate(prop, obj[k], path+"."+k, errs)
+			} else if schema.AdditionalProperties != nil {
+				v.validate(schema.AdditionalProperties, obj[k], path+"."+k, errs)
+			}
+		}
+	}
+}
+
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return "null"
+	}
+}
+
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == jsonContentType
+}
+
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// errReader replays a read error of the original request body.
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
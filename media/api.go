@@ -0,0 +1,12 @@
+package media
+
+// Response types document the payloads in the OpenAPI spec.
+
+type MediaResponse struct {
+	Media *Media `json:"media"`
+}
+
+type MediaListResponse struct {
+	Media []*Media `json:"media"`
+	Usage *Usage   `json:"usage"`
+}
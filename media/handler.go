@@ -0,0 +1,173 @@
+package media
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/uptrace/bun-realworld-app/bunapp"
+	"github.com/uptrace/bun-realworld-app/httputil/httperror"
+	"github.com/uptrace/bun-realworld-app/org"
+	"github.com/uptrace/bunrouter"
+)
+
+// multipartOverhead is allowed on top of media.max_size for boundaries and part headers.
+const multipartOverhead = 64 << 10
+
+var keyRE = regexp.MustCompile(`^[0-9a-f]{32}(_thumb)?\.(jpg|png|gif)$`)
+
+type MediaHandler struct {
+	app     *bunapp.App
+	service *MediaService
+}
+
+func NewMediaHandler(app *bunapp.App) MediaHandler {
+	return MediaHandler{
+		app:     app,
+		service: NewMediaService(app),
+	}
+}
+
+func (h MediaHandler) Upload(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+	user := org.UserFromContext(ctx)
+
+	data, err := h.readFile(w, req)
+	if err != nil {
+		return err
+	}
+
+	media, err := h.service.Upload(ctx, user, data)
+	if err != nil {
+		return err
+	}
+
+	return bunrouter.JSON(w, bunrouter.H{
+		"media": media,
+	})
+}
+
+// readFile reads the "file" part of the multipart form without buffering
+// other parts and rejects files larger than media.max_size.
+func (h MediaHandler) readFile(w http.ResponseWriter, req bunrouter.Request) ([]byte, error) {
+	maxSize := h.app.Config().Media.MaxSize
+	tooLarge := httperror.New(http.StatusRequestEntityTooLarge,
+		"file_too_large", "file must be at most %d bytes", maxSize)
+
+	req.Body = http.MaxBytesReader(w, req.Body, maxSize+multipartOverhead)
+	mr, err := req.MultipartReader()
+	if err != nil {
+		return nil, httperror.BadRequest("invalid_multipart", "expected a multipart/form-data request")
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil, httperror.BadRequest("file_required", "file is required")
+		}
+		if err != nil {
+			if isMaxBytesError(err) {
+				return nil, tooLarge
+			}
+			return nil, httperror.BadRequest("invalid_multipart", "can't read the multipart form")
+		}
+		if part.FormName() != "file" {
+			continue
+		}
+
+		data, err := ioutil.ReadAll(io.LimitReader(part, maxSize+1))
+		if err != nil {
+			if isMaxBytesError(err) {
+				return nil, tooLarge
+			}
+			return nil, err
+		}
+		if int64(len(data)) > maxSize {
+			return nil, tooLarge
+		}
+		return data, nil
+	}
+}
+
+func (h MediaHandler) List(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+	user := org.UserFromContext(ctx)
+
+	media, err := h.service.List(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	usage, err := h.service.Usage(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	return bunrouter.JSON(w, bunrouter.H{
+		"media": media,
+		"usage": usage,
+	})
+}
+
+func (h MediaHandler) Delete(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+	user := org.UserFromContext(ctx)
+
+	id, err := strconv.ParseUint(req.Param("id"), 10, 64)
+	if err != nil {
+		return ErrMediaNotFound
+	}
+
+	return h.service.Delete(ctx, user, id)
+}
+
+// File serves files from the local storage. Other storages serve files themselves.
+func (h MediaHandler) File(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+	key := req.Param("key")
+
+	if !keyRE.MatchString(key) {
+		return ErrMediaNotFound
+	}
+
+	storage, err := NewStorage(h.app)
+	if err != nil {
+		return err
+	}
+	if _, ok := storage.(*LocalStorage); !ok {
+		return ErrMediaNotFound
+	}
+
+	f, err := storage.Open(ctx, key)
+	if err == ErrObjectNotFound {
+		return ErrMediaNotFound
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Keys are random and files are never modified, so they can be cached forever.
+	w.Header().Set("Content-Type", contentTypeByKey(key))
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	_, err = io.Copy(w, f)
+	return err
+}
+
+func contentTypeByKey(key string) string {
+	for contentType, ext := range extensions {
+		if len(key) > len(ext) && key[len(key)-len(ext):] == ext {
+			return contentType
+		}
+	}
+	return "application/octet-stream"
+}
+
+func isMaxBytesError(err error) bool {
+	// http.MaxBytesError is not available in Go 1.16.
+	return err != nil && errors.Unwrap(err) == nil && err.Error() == "http: request body too large"
+}
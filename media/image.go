@@ -0,0 +1,170 @@
+package media
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	_ "image/gif" // register the GIF decoder
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	"github.com/uptrace/bun-realworld-app/httputil/httperror"
+)
+
+// maxPixels protects against decompression bombs, e.g. a tiny PNG
+// that decodes to a 100000x100000 image.
+const maxPixels = 50 << 20
+
+const jpegQuality = 85
+
+var errUnsupportedType = httperror.New(http.StatusUnsupportedMediaType,
+	"unsupported_media_type", "only JPEG, PNG and GIF images are allowed")
+
+// extensions maps the allowed content types to file extensions.
+var extensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// processedImage is an upload ready to be stored.
+type processedImage struct {
+	contentType string
+	data        []byte
+	width       int
+	height      int
+
+	thumbnailType string
+	thumbnail     []byte
+}
+
+// processImage sniffs the content type ignoring the type claimed by the client,
+// shrinks the image to fit maxDim and generates a thumbnail that fits thumbSize.
+func processImage(data []byte, maxDim, thumbSize int) (*processedImage, error) {
+	contentType := http.DetectContentType(data)
+	if _, ok := extensions[contentType]; !ok {
+		return nil, errUnsupportedType
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, errUnsupportedType
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return nil, httperror.New(http.StatusUnprocessableEntity,
+			"image_too_large", "image must have at most %d pixels", maxPixels)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, errUnsupportedType
+	}
+
+	out := &processedImage{
+		contentType: contentType,
+		data:        data,
+		width:       cfg.Width,
+		height:      cfg.Height,
+	}
+
+	if w, h := fit(cfg.Width, cfg.Height, maxDim); w != cfg.Width || h != cfg.Height {
+		resized := resize(img, w, h)
+		// Resizing loses GIF animation, so resized GIFs become PNGs.
+		out.contentType, out.data, err = encode(resized, contentType)
+		if err != nil {
+			return nil, err
+		}
+		out.width, out.height = w, h
+		img = resized
+	}
+
+	w, h := fit(out.width, out.height, thumbSize)
+	out.thumbnailType, out.thumbnail, err = encode(resize(img, w, h), out.contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// fit scales the dimensions down to fit a max x max box, keeping the aspect ratio.
+func fit(width, height, max int) (int, int) {
+	if max <= 0 || (width <= max && height <= max) {
+		return width, height
+	}
+	if width >= height {
+		h := height * max / width
+		if h < 1 {
+			h = 1
+		}
+		return max, h
+	}
+	w := width * max / height
+	if w < 1 {
+		w = 1
+	}
+	return w, max
+}
+
+// resize scales the image down by averaging the source pixels covered
+// by each destination pixel.
+func resize(src image.Image, width, height int) image.Image {
+	sb := src.Bounds()
+	if sb.Dx() == width && sb.Dy() == height {
+		return src
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := sb.Min.Y + y*sb.Dy()/height
+		y1 := sb.Min.Y + (y+1)*sb.Dy()/height
+		if y1 == y0 {
+			y1++
+		}
+
+		for x := 0; x < width; x++ {
+			x0 := sb.Min.X + x*sb.Dx()/width
+			x1 := sb.Min.X + (x+1)*sb.Dx()/width
+			if x1 == x0 {
+				x1++
+			}
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r += uint64(cr)
+					g += uint64(cg)
+					b += uint64(cb)
+					a += uint64(ca)
+					n++
+				}
+			}
+
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(b / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+	return dst
+}
+
+// encode encodes JPEGs as JPEG and other images as PNG.
+func encode(img image.Image, contentType string) (string, []byte, error) {
+	var buf bytes.Buffer
+	if contentType == "image/jpeg" {
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return "", nil, err
+		}
+		return "image/jpeg", buf.Bytes(), nil
+	}
+
+	if err := png.Encode(&buf, img); err != nil {
+		return "", nil, err
+	}
+	return "image/png", buf.Bytes(), nil
+}
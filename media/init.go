@@ -0,0 +1,41 @@
+package media
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/uptrace/bun-realworld-app/bunapp"
+	"github.com/uptrace/bun-realworld-app/httputil/openapi"
+	"github.com/uptrace/bun-realworld-app/org"
+)
+
+func init() {
+	bunapp.OnStart("media.initRoutes", func(ctx context.Context, app *bunapp.App) error {
+		middleware := org.NewMiddleware(app)
+		mediaHandler := NewMediaHandler(app)
+
+		g := app.API().WithMiddleware(middleware.User, openapi.Tags("media"))
+
+		g.GET("/media/files/:key", mediaHandler.File,
+			openapi.Summary("Download a file from the local storage"),
+			openapi.ReturnsBinary(http.StatusOK, "image/*"))
+
+		g = g.WithMiddleware(middleware.MustUser, openapi.Security("token"))
+
+		g.GET("/media", mediaHandler.List,
+			openapi.Summary("List uploaded media"),
+			openapi.Returns(http.StatusOK, MediaListResponse{}))
+
+		g = g.WithMiddleware(middleware.RequireScope(org.ScopeMediaWrite))
+
+		g.POST("/media", mediaHandler.Upload,
+			openapi.Summary("Upload an image"),
+			openapi.Multipart("file"),
+			openapi.Returns(http.StatusOK, MediaResponse{}))
+		g.DELETE("/media/:id", mediaHandler.Delete,
+			openapi.Summary("Delete media"),
+			openapi.Returns(http.StatusOK, nil))
+
+		return nil
+	})
+}
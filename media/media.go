@@ -0,0 +1,225 @@
+package media
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun-realworld-app/bunapp"
+	"github.com/uptrace/bun-realworld-app/httputil/httperror"
+	"github.com/uptrace/bun-realworld-app/org"
+)
+
+var (
+	ErrMediaNotFound = httperror.NotFound("media not found")
+	ErrQuotaExceeded = httperror.New(http.StatusForbidden,
+		"quota_exceeded", "media quota exceeded, delete some files first")
+)
+
+// Media is an image uploaded by a user.
+type Media struct {
+	bun.BaseModel `bun:"media,alias:m"`
+
+	ID     uint64 `json:"id"`
+	UserID uint64 `json:"-"`
+
+	Key           string `json:"-"`
+	ThumbnailKey  string `json:"-"`
+	ContentType   string `json:"contentType"`
+	Size          int64  `json:"size"`
+	ThumbnailSize int64  `json:"-"`
+	Width         int    `json:"width"`
+	Height        int    `json:"height"`
+
+	URL          string `json:"url" bun:"-"`
+	ThumbnailURL string `json:"thumbnailUrl" bun:"-"`
+
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Usage is the storage used by a user.
+type Usage struct {
+	Used  int64 `json:"used"`
+	Quota int64 `json:"quota"`
+}
+
+// MediaService stores uploads and enforces per-user quotas.
+type MediaService struct {
+	app *bunapp.App
+}
+
+func NewMediaService(app *bunapp.App) *MediaService {
+	return &MediaService{
+		app: app,
+	}
+}
+
+// Upload processes the image and stores it with the thumbnail on behalf of the user.
+func (s *MediaService) Upload(ctx context.Context, user *org.User, data []byte) (*Media, error) {
+	cfg := &s.app.Config().Media
+
+	img, err := processImage(data, cfg.MaxDimension, cfg.ThumbnailSize)
+	if err != nil {
+		return nil, err
+	}
+
+	media := &Media{
+		UserID:        user.ID,
+		Key:           newKey(extensions[img.contentType]),
+		ThumbnailKey:  newKey("_thumb" + extensions[img.thumbnailType]),
+		ContentType:   img.contentType,
+		Size:          int64(len(img.data)),
+		ThumbnailSize: int64(len(img.thumbnail)),
+		Width:         img.width,
+		Height:        img.height,
+		CreatedAt:     s.app.Clock().Now(),
+	}
+
+	// Fail fast before uploading; the quota is checked again in the transaction.
+	if err := s.checkQuota(ctx, user, media); err != nil {
+		return nil, err
+	}
+
+	storage, err := NewStorage(s.app)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := storage.Put(ctx, media.Key, img.data, img.contentType); err != nil {
+		return nil, err
+	}
+	if err := storage.Put(ctx, media.ThumbnailKey, img.thumbnail, img.thumbnailType); err != nil {
+		s.deleteFiles(ctx, storage, media)
+		return nil, err
+	}
+
+	if err := s.app.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		// Serialize uploads by the same user, so they can't exceed the quota together.
+		if _, err := tx.NewSelect().
+			Model((*org.User)(nil)).
+			Column("id").
+			Where("id = ?", user.ID).
+			For("UPDATE").
+			Exec(ctx); err != nil {
+			return err
+		}
+
+		if err := s.checkQuota(ctx, user, media); err != nil {
+			return err
+		}
+
+		_, err := tx.NewInsert().
+			Model(media).
+			Exec(ctx)
+		return err
+	}); err != nil {
+		s.deleteFiles(ctx, storage, media)
+		return nil, err
+	}
+
+	setURLs(storage, media)
+	return media, nil
+}
+
+// List returns the media uploaded by the user, newest first.
+func (s *MediaService) List(ctx context.Context, user *org.User) ([]*Media, error) {
+	storage, err := NewStorage(s.app)
+	if err != nil {
+		return nil, err
+	}
+
+	media := make([]*Media, 0)
+	if err := s.app.IDB(ctx).NewSelect().
+		Model(&media).
+		Where("user_id = ?", user.ID).
+		OrderExpr("id DESC").
+		Scan(ctx); err != nil {
+		return nil, err
+	}
+
+	setURLs(storage, media...)
+	return media, nil
+}
+
+// Usage returns the storage used by the user and the quota.
+func (s *MediaService) Usage(ctx context.Context, user *org.User) (*Usage, error) {
+	var used int64
+	if err := s.app.IDB(ctx).NewSelect().
+		Model((*Media)(nil)).
+		ColumnExpr("coalesce(sum(size + thumbnail_size), 0)").
+		Where("user_id = ?", user.ID).
+		Scan(ctx, &used); err != nil {
+		return nil, err
+	}
+
+	return &Usage{
+		Used:  used,
+		Quota: s.app.Config().Media.UserQuota,
+	}, nil
+}
+
+// Delete deletes the media and its files. Only the owner can delete the media.
+func (s *MediaService) Delete(ctx context.Context, user *org.User, id uint64) error {
+	storage, err := NewStorage(s.app)
+	if err != nil {
+		return err
+	}
+
+	media := new(Media)
+	if _, err := s.app.IDB(ctx).NewDelete().
+		Model(media).
+		Where("id = ?", id).
+		Where("user_id = ?", user.ID).
+		Returning("*").
+		Exec(ctx, media); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrMediaNotFound
+		}
+		return err
+	}
+
+	s.deleteFiles(ctx, storage, media)
+	return nil
+}
+
+func (s *MediaService) checkQuota(ctx context.Context, user *org.User, media *Media) error {
+	usage, err := s.Usage(ctx, user)
+	if err != nil {
+		return err
+	}
+	if usage.Quota > 0 && usage.Used+media.Size+media.ThumbnailSize > usage.Quota {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// deleteFiles only logs errors, because orphaned files are harmless
+// and the database is the source of truth.
+func (s *MediaService) deleteFiles(ctx context.Context, storage Storage, media *Media) {
+	for _, key := range []string{media.Key, media.ThumbnailKey} {
+		if err := storage.Delete(ctx, key); err != nil {
+			log.Printf("media: Delete %s failed: %s", key, err)
+		}
+	}
+}
+
+func setURLs(storage Storage, media ...*Media) {
+	for _, m := range media {
+		m.URL = storage.URL(m.Key)
+		m.ThumbnailURL = storage.URL(m.ThumbnailKey)
+	}
+}
+
+// newKey returns a random unguessable key with the suffix.
+func newKey(suffix string) string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b) + suffix
+}
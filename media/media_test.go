@@ -0,0 +1,236 @@
+package media_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/uptrace/bun-realworld-app/org"
+	"github.com/uptrace/bun-realworld-app/testbed"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestMedia(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "media")
+}
+
+var _ = Describe("media", func() {
+	var ctx context.Context
+	var testapp *testbed.TestApp
+	var dir string
+	var user *org.User
+	var client testbed.Client
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		testapp = testbed.StartApp(ctx)
+		testapp.TruncateDB(ctx)
+
+		var err error
+		dir, err = ioutil.TempDir("", "media")
+		Expect(err).NotTo(HaveOccurred())
+		testapp.Config().Media.Local.Dir = dir
+
+		user = &org.User{
+			Username:     "uploader",
+			Email:        "uploader@example.com",
+			PasswordHash: "h",
+		}
+		_, err = testapp.DB().NewInsert().Model(user).Exec(ctx)
+		Expect(err).NotTo(HaveOccurred())
+
+		client = testapp.Client().WithToken(user.ID)
+	})
+
+	AfterEach(func() {
+		testapp.Stop()
+		os.RemoveAll(dir)
+	})
+
+	It("uploads an image and generates a thumbnail", func() {
+		resp := client.Serve(uploadRequest("file", makePNG(400, 200)))
+		data := parseJSON(resp, http.StatusOK)
+
+		media := data["media"].(map[string]interface{})
+		Expect(media["contentType"]).To(Equal("image/png"))
+		Expect(media["width"]).To(Equal(float64(400)))
+		Expect(media["height"]).To(Equal(float64(200)))
+		Expect(media["url"]).To(MatchRegexp(`^/api/media/files/[0-9a-f]{32}\.png$`))
+		Expect(media["thumbnailUrl"]).To(MatchRegexp(`^/api/media/files/[0-9a-f]{32}_thumb\.png$`))
+
+		resp = client.Get(media["thumbnailUrl"].(string))
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		Expect(resp.Header().Get("Content-Type")).To(Equal("image/png"))
+
+		cfg, err := png.DecodeConfig(resp.Body)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.Width).To(Equal(256))
+		Expect(cfg.Height).To(Equal(128))
+	})
+
+	It("shrinks images larger than max dimension", func() {
+		testapp.Config().Media.MaxDimension = 100
+
+		resp := client.Serve(uploadRequest("file", makePNG(400, 200)))
+		media := parseJSON(resp, http.StatusOK)["media"].(map[string]interface{})
+		Expect(media["width"]).To(Equal(float64(100)))
+		Expect(media["height"]).To(Equal(float64(50)))
+	})
+
+	It("sniffs the content type", func() {
+		resp := client.Serve(uploadRequest("file", []byte("<html><script>alert(1)</script></html>")))
+		data := parseJSON(resp, http.StatusUnsupportedMediaType)
+		Expect(data["code"]).To(Equal("unsupported_media_type"))
+	})
+
+	It("requires the file", func() {
+		resp := client.Serve(uploadRequest("image", makePNG(10, 10)))
+		data := parseJSON(resp, http.StatusBadRequest)
+		Expect(data["code"]).To(Equal("file_required"))
+	})
+
+	It("limits the file size", func() {
+		testapp.Config().Media.MaxSize = 100
+
+		resp := client.Serve(uploadRequest("file", makePNG(100, 100)))
+		data := parseJSON(resp, http.StatusRequestEntityTooLarge)
+		Expect(data["code"]).To(Equal("file_too_large"))
+	})
+
+	It("enforces the quota", func() {
+		img := makePNG(50, 50)
+		resp := client.Serve(uploadRequest("file", img))
+		_ = parseJSON(resp, http.StatusOK)
+
+		resp = client.Get("/api/media")
+		usage := parseJSON(resp, http.StatusOK)["usage"].(map[string]interface{})
+		used := usage["used"].(float64)
+		Expect(used).To(BeNumerically(">", len(img)))
+
+		testapp.Config().Media.UserQuota = int64(used) + 1
+
+		resp = client.Serve(uploadRequest("file", img))
+		data := parseJSON(resp, http.StatusForbidden)
+		Expect(data["code"]).To(Equal("quota_exceeded"))
+	})
+
+	It("lists and deletes media", func() {
+		resp := client.Serve(uploadRequest("file", makePNG(10, 10)))
+		media := parseJSON(resp, http.StatusOK)["media"].(map[string]interface{})
+
+		resp = client.Get("/api/media")
+		data := parseJSON(resp, http.StatusOK)
+		Expect(data["media"]).To(HaveLen(1))
+
+		other := &org.User{Username: "other", Email: "other@example.com", PasswordHash: "h"}
+		_, err := testapp.DB().NewInsert().Model(other).Exec(ctx)
+		Expect(err).NotTo(HaveOccurred())
+
+		url := fmt.Sprintf("/api/media/%d", uint64(media["id"].(float64)))
+		resp = testapp.Client().WithToken(other.ID).Delete(url)
+		Expect(resp.Code).To(Equal(http.StatusNotFound))
+
+		resp = client.Delete(url)
+		Expect(resp.Code).To(Equal(http.StatusOK))
+
+		resp = client.Get(media["url"].(string))
+		Expect(resp.Code).To(Equal(http.StatusNotFound))
+
+		files, err := ioutil.ReadDir(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(BeEmpty())
+	})
+
+	It("requires the media:write scope", func() {
+		json := `{"token": {"name": "ci", "scopes": ["articles:write"]}}`
+		resp := client.PostJSON("/api/user/tokens", json)
+		token := parseJSON(resp, http.StatusOK)["token"].(map[string]interface{})["token"].(string)
+
+		resp = testapp.Client().WithAuthToken(token).Serve(uploadRequest("file", makePNG(10, 10)))
+		data := parseJSON(resp, http.StatusForbidden)
+		Expect(data["code"]).To(Equal("insufficient_scope"))
+
+		resp = client.Serve(uploadRequest("file", makePNG(10, 10)))
+		media := parseJSON(resp, http.StatusOK)["media"].(map[string]interface{})
+
+		url := fmt.Sprintf("/api/media/%d", uint64(media["id"].(float64)))
+		resp = testapp.Client().WithAuthToken(token).Delete(url)
+		data = parseJSON(resp, http.StatusForbidden)
+		Expect(data["code"]).To(Equal("insufficient_scope"))
+	})
+
+	It("stores files in S3", func() {
+		s3 := testbed.NewS3Server("test-access-key")
+		defer s3.Close()
+
+		cfg := &testapp.Config().Media
+		cfg.Storage = "s3"
+		cfg.S3.Endpoint = s3.URL
+		cfg.S3.Region = "us-east-1"
+		cfg.S3.Bucket = "conduit"
+		cfg.S3.AccessKey = "test-access-key"
+		cfg.S3.SecretKey = "secret"
+
+		resp := client.Serve(uploadRequest("file", makePNG(10, 10)))
+		media := parseJSON(resp, http.StatusOK)["media"].(map[string]interface{})
+		Expect(media["url"]).To(HavePrefix(s3.URL + "/conduit/"))
+		Expect(s3.Len()).To(Equal(2))
+
+		obj, ok := s3.Object(media["url"].(string)[len(s3.URL):])
+		Expect(ok).To(BeTrue())
+		Expect(obj.ContentType).To(Equal("image/png"))
+
+		url := fmt.Sprintf("/api/media/%d", uint64(media["id"].(float64)))
+		resp = client.Delete(url)
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		Expect(s3.Len()).To(Equal(0))
+	})
+})
+
+func uploadRequest(field string, data []byte) *http.Request {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile(field, "image.png")
+	Expect(err).NotTo(HaveOccurred())
+	_, err = fw.Write(data)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(mw.Close()).To(Succeed())
+
+	req := httptest.NewRequest("POST", "/api/media", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func makePNG(width, height int) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: uint8(x ^ y), A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	Expect(png.Encode(&buf, img)).To(Succeed())
+	return buf.Bytes()
+}
+
+func parseJSON(resp *httptest.ResponseRecorder, code int) map[string]interface{} {
+	out := make(map[string]interface{})
+	err := json.Unmarshal(resp.Body.Bytes(), &out)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(resp.Code).To(Equal(code))
+	return out
+}
@@ -0,0 +1,99 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/uptrace/bun-realworld-app/bunapp"
+)
+
+var ErrObjectNotFound = errors.New("media: object not found")
+
+// Storage stores uploaded files by key. Keys are generated by the app
+// and never contain slashes.
+type Storage interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	// URL returns the public URL of the file.
+	URL(key string) string
+}
+
+// NewStorage returns the storage configured in media.storage.
+func NewStorage(app *bunapp.App) (Storage, error) {
+	cfg := &app.Config().Media
+	switch cfg.Storage {
+	case "", "local":
+		return NewLocalStorage(cfg.Local.Dir), nil
+	case "s3":
+		return NewS3Storage(&cfg.S3), nil
+	default:
+		return nil, fmt.Errorf("media: unknown storage %q", cfg.Storage)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// LocalStorage keeps files in a directory and serves them via the API.
+type LocalStorage struct {
+	dir string
+}
+
+var _ Storage = (*LocalStorage)(nil)
+
+func NewLocalStorage(dir string) *LocalStorage {
+	return &LocalStorage{
+		dir: dir,
+	}
+}
+
+func (s *LocalStorage) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first, so readers never see partial files.
+	f, err := os.CreateTemp(s.dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), s.path(key))
+}
+
+func (s *LocalStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	f, err := os.Open(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrObjectNotFound
+	}
+	return f, err
+}
+
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	err := os.Remove(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *LocalStorage) URL(key string) string {
+	return "/api/media/files/" + key
+}
+
+func (s *LocalStorage) path(key string) string {
+	return filepath.Join(s.dir, filepath.Base(strings.ReplaceAll(key, "..", "")))
+}
@@ -0,0 +1,63 @@
+package org
+
+import (
+	"net/http"
+
+	"github.com/uptrace/bun-realworld-app/bunapp"
+	"github.com/uptrace/bunrouter"
+)
+
+type AdminHandler struct {
+	app *bunapp.App
+}
+
+func NewAdminHandler(app *bunapp.App) AdminHandler {
+	return AdminHandler{
+		app: app,
+	}
+}
+
+func (h AdminHandler) LockStatus(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+
+	user, err := SelectUserByUsername(ctx, h.app, req.Param("username"))
+	if err != nil {
+		return err
+	}
+
+	attempt, err := newLoginThrottle(h.app).Status(ctx, emailLoginKey(user.Email))
+	if err != nil {
+		return err
+	}
+
+	return bunrouter.JSON(w, bunrouter.H{
+		"lock": bunrouter.H{
+			"username":       user.Username,
+			"locked":         attempt.Locked(h.app.Clock().Now()),
+			"lockedUntil":    attempt.LockedUntil,
+			"failedAttempts": attempt.Failures,
+			"lastFailedAt":   attempt.LastFailedAt,
+		},
+	})
+}
+
+func (h AdminHandler) Unlock(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+
+	user, err := SelectUserByUsername(ctx, h.app, req.Param("username"))
+	if err != nil {
+		return err
+	}
+
+	if err := newLoginThrottle(h.app).Reset(ctx, emailLoginKey(user.Email)); err != nil {
+		return err
+	}
+
+	return bunrouter.JSON(w, bunrouter.H{
+		"lock": bunrouter.H{
+			"username":       user.Username,
+			"locked":         false,
+			"failedAttempts": 0,
+		},
+	})
+}
@@ -0,0 +1,149 @@
+package org
+
+import "time"
+
+// Request types are decoded by the handlers. Response types document
+// the payloads in the OpenAPI spec, which tests validate responses against.
+
+type UserRequest struct {
+	User *UserInput `json:"user"`
+}
+
+type UserInput struct {
+	Username string `json:"username,omitempty"`
+	Email    string `json:"email,omitempty"`
+	Password string `json:"password,omitempty"`
+	Bio      string `json:"bio,omitempty"`
+	Image    string `json:"image,omitempty"`
+}
+
+func (in *UserInput) user() *User {
+	return &User{
+		Username: in.Username,
+		Email:    in.Email,
+		Password: in.Password,
+		Bio:      in.Bio,
+		Image:    in.Image,
+	}
+}
+
+type UserUpdateRequest struct {
+	User *UserUpdate `json:"user"`
+}
+
+// UserUpdate holds the profile fields to change. Nil fields are left as is.
+type UserUpdate struct {
+	Username *string `json:"username,omitempty"`
+	Email    *string `json:"email,omitempty"`
+	Password *string `json:"password,omitempty"`
+	Bio      *string `json:"bio,omitempty"`
+	Image    *string `json:"image,omitempty"`
+}
+
+type LoginRequest struct {
+	User *Credentials `json:"user"`
+}
+
+type Credentials struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type SecondFactorRequest struct {
+	ChallengeToken string `json:"challengeToken,omitempty"`
+	Code           string `json:"code,omitempty"`
+	RecoveryCode   string `json:"recoveryCode,omitempty"`
+}
+
+type CallbackRequest struct {
+	Code  string `json:"code"`
+	State string `json:"state"`
+}
+
+type TokenRequest struct {
+	Token *TokenInput `json:"token"`
+}
+
+type TokenInput struct {
+	Name      string    `json:"name"`
+	Scopes    []string  `json:"scopes"`
+	ExpiresAt time.Time `json:"expiresAt,omitempty"`
+}
+
+//------------------------------------------------------------------------------
+
+type UserResponse struct {
+	User *User `json:"user"`
+}
+
+// LoginResponse has either the user or a second factor challenge.
+type LoginResponse struct {
+	User      *User      `json:"user,omitempty"`
+	Challenge *Challenge `json:"challenge,omitempty"`
+}
+
+type Challenge struct {
+	Token     string    `json:"token"`
+	Type      string    `json:"type"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type ProfileResponse struct {
+	Profile *Profile `json:"profile"`
+}
+
+type ProfilesResponse struct {
+	Profiles []*Profile `json:"profiles"`
+}
+
+type NotificationsResponse struct {
+	Notifications []*Notification `json:"notifications"`
+}
+
+type TwoFactorResponse struct {
+	TwoFactor *TwoFactorStatus `json:"twoFactor"`
+}
+
+type TwoFactorStatus struct {
+	Secret        string   `json:"secret,omitempty"`
+	OTPAuthURI    string   `json:"otpauthUri,omitempty"`
+	Enabled       bool     `json:"enabled,omitempty"`
+	RecoveryCodes []string `json:"recoveryCodes,omitempty"`
+}
+
+type ProvidersResponse struct {
+	Providers []string `json:"providers"`
+}
+
+type AuthorizationResponse struct {
+	Authorization *Authorization `json:"authorization"`
+}
+
+type Authorization struct {
+	URL   string `json:"url"`
+	State string `json:"state"`
+}
+
+type IdentitiesResponse struct {
+	Identities []*UserIdentity `json:"identities"`
+}
+
+type TokensResponse struct {
+	Tokens []*PersonalToken `json:"tokens"`
+}
+
+type TokenResponse struct {
+	Token *PersonalToken `json:"token"`
+}
+
+type LockResponse struct {
+	Lock *LockStatus `json:"lock"`
+}
+
+type LockStatus struct {
+	Username       string    `json:"username"`
+	Locked         bool      `json:"locked"`
+	LockedUntil    time.Time `json:"lockedUntil,omitempty"`
+	FailedAttempts int       `json:"failedAttempts"`
+	LastFailedAt   time.Time `json:"lastFailedAt,omitempty"`
+}
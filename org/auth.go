@@ -2,28 +2,108 @@ package org
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/uptrace/bun-realworld-app/bunapp"
+	"github.com/uptrace/bun-realworld-app/httputil/httperror"
 	"github.com/uptrace/bunrouter"
 )
 
 type (
 	userCtxKey    struct{}
 	userErrCtxKey struct{}
+	scopesCtxKey  struct{}
 )
 
+const (
+	ScopeArticlesWrite  = "articles:write"
+	ScopeCommentsWrite  = "comments:write"
+	ScopeFavoritesWrite = "favorites:write"
+	ScopeProfileWrite   = "profile:write"
+	ScopeMediaWrite     = "media:write"
+	ScopeBookmarksWrite = "bookmarks:write"
+	ScopeReportsWrite   = "reports:write"
+)
+
+var allScopes = []string{
+	ScopeArticlesWrite,
+	ScopeCommentsWrite,
+	ScopeFavoritesWrite,
+	ScopeProfileWrite,
+	ScopeMediaWrite,
+	ScopeBookmarksWrite,
+	ScopeReportsWrite,
+}
+
+func validScope(scope string) bool {
+	for _, s := range allScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
 func UserFromContext(ctx context.Context) *User {
 	user, _ := ctx.Value(userCtxKey{}).(*User)
 	return user
 }
 
+// HasScope reports whether the request is allowed to perform actions
+// covered by the scope. Session (JWT) tokens have all scopes.
+func HasScope(ctx context.Context, scope string) bool {
+	scopes, ok := ctx.Value(scopesCtxKey{}).([]string)
+	if !ok {
+		return true
+	}
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckScope returns an error when the request is not allowed to perform
+// actions covered by the scope.
+func CheckScope(ctx context.Context, scope string) error {
+	if !HasScope(ctx, scope) {
+		return httperror.New(http.StatusForbidden,
+			"insufficient_scope", "token does not have %q scope", scope)
+	}
+	return nil
+}
+
+var errUserRequired = httperror.New(http.StatusUnauthorized,
+	"unauthorized", "authentication is required")
+
+// MustUserFromContext returns the authenticated user or the reason the request
+// is not authenticated. Use it in handlers that serve anonymous users too.
+func MustUserFromContext(ctx context.Context) (*User, error) {
+	if user := UserFromContext(ctx); user != nil {
+		return user, nil
+	}
+	if err, ok := ctx.Value(userErrCtxKey{}).(error); ok {
+		return nil, err
+	}
+	return nil, errUserRequired
+}
+
+func isPersonalTokenAuth(ctx context.Context) bool {
+	_, ok := ctx.Value(scopesCtxKey{}).([]string)
+	return ok
+}
+
 func authToken(req bunrouter.Request) string {
-	const prefix = "Token "
 	v := req.Header.Get("Authorization")
-	v = strings.TrimPrefix(v, prefix)
+	for _, prefix := range []string{"Token ", "Bearer "} {
+		if strings.HasPrefix(v, prefix) {
+			return strings.TrimPrefix(v, prefix)
+		}
+	}
 	return v
 }
 
@@ -42,13 +122,17 @@ func (m Middleware) User(next bunrouter.HandlerFunc) bunrouter.HandlerFunc {
 		ctx := req.Context()
 
 		token := authToken(req)
+		if isPersonalToken(token) {
+			return m.personalToken(w, req, next, token)
+		}
+
 		userID, err := decodeUserToken(m.app, token)
 		if err != nil {
 			ctx = context.WithValue(ctx, userErrCtxKey{}, err)
 			return next(w, req.WithContext(ctx))
 		}
 
-		user, err := SelectUser(ctx, m.app, userID)
+		user, err := selectActiveUser(ctx, m.app, userID)
 		if err != nil {
 			ctx = context.WithValue(ctx, userErrCtxKey{}, err)
 			return next(w, req.WithContext(ctx))
@@ -65,6 +149,42 @@ func (m Middleware) User(next bunrouter.HandlerFunc) bunrouter.HandlerFunc {
 	}
 }
 
+func (m Middleware) personalToken(
+	w http.ResponseWriter, req bunrouter.Request, next bunrouter.HandlerFunc, token string,
+) error {
+	ctx := req.Context()
+
+	pt, err := selectPersonalToken(ctx, m.app, token)
+	if err != nil {
+		ctx = context.WithValue(ctx, userErrCtxKey{}, err)
+		return next(w, req.WithContext(ctx))
+	}
+
+	user, err := selectActiveUser(ctx, m.app, pt.UserID)
+	if err != nil {
+		ctx = context.WithValue(ctx, userErrCtxKey{}, err)
+		return next(w, req.WithContext(ctx))
+	}
+
+	// Unlike sessions, personal tokens are never exchanged for a JWT.
+	ctx = context.WithValue(ctx, userCtxKey{}, user)
+	ctx = context.WithValue(ctx, scopesCtxKey{}, pt.Scopes)
+	return next(w, req.WithContext(ctx))
+}
+
+// selectActiveUser returns ErrSuspended instead of suspended users,
+// so their tokens stop working immediately.
+func selectActiveUser(ctx context.Context, app *bunapp.App, id uint64) (*User, error) {
+	user, err := SelectUser(ctx, app, id)
+	if err != nil {
+		return nil, err
+	}
+	if user.Suspended() {
+		return nil, ErrSuspended
+	}
+	return user, nil
+}
+
 func (m Middleware) MustUser(next bunrouter.HandlerFunc) bunrouter.HandlerFunc {
 	return func(w http.ResponseWriter, req bunrouter.Request) error {
 		if err, ok := req.Context().Value(userErrCtxKey{}).(error); ok {
@@ -73,3 +193,61 @@ func (m Middleware) MustUser(next bunrouter.HandlerFunc) bunrouter.HandlerFunc {
 		return next(w, req)
 	}
 }
+
+var errAdminRequired = httperror.New(http.StatusForbidden, "forbidden", "admin access required")
+
+func (m Middleware) MustAdmin(next bunrouter.HandlerFunc) bunrouter.HandlerFunc {
+	return func(w http.ResponseWriter, req bunrouter.Request) error {
+		user := UserFromContext(req.Context())
+		if user == nil {
+			return errors.New("user is required")
+		}
+		if !user.IsAdmin {
+			return errAdminRequired
+		}
+		return next(w, req)
+	}
+}
+
+var errModeratorRequired = httperror.New(http.StatusForbidden,
+	"forbidden", "moderator access required")
+
+// MustModerator allows moderators and admins.
+func (m Middleware) MustModerator(next bunrouter.HandlerFunc) bunrouter.HandlerFunc {
+	return func(w http.ResponseWriter, req bunrouter.Request) error {
+		user := UserFromContext(req.Context())
+		if user == nil {
+			return errors.New("user is required")
+		}
+		if !user.CanModerate() {
+			return errModeratorRequired
+		}
+		return next(w, req)
+	}
+}
+
+var errSessionRequired = httperror.New(http.StatusForbidden,
+	"session_required", "this action is not available with personal access tokens")
+
+// MustSession rejects requests authenticated with personal access tokens.
+func (m Middleware) MustSession(next bunrouter.HandlerFunc) bunrouter.HandlerFunc {
+	return func(w http.ResponseWriter, req bunrouter.Request) error {
+		if isPersonalTokenAuth(req.Context()) {
+			return errSessionRequired
+		}
+		return next(w, req)
+	}
+}
+
+// RequireScope returns a middleware that rejects personal access tokens
+// that were not granted the scope.
+func (m Middleware) RequireScope(scope string) bunrouter.MiddlewareFunc {
+	return func(next bunrouter.HandlerFunc) bunrouter.HandlerFunc {
+		return func(w http.ResponseWriter, req bunrouter.Request) error {
+			if err := CheckScope(req.Context(), scope); err != nil {
+				return err
+			}
+			return next(w, req)
+		}
+	}
+}
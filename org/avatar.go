@@ -0,0 +1,108 @@
+package org
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/uptrace/bun-realworld-app/httputil/httperror"
+)
+
+const maxImageURLLength = 2048
+
+// imageSchemes are the URL schemes allowed in User.Image. Notably javascript:
+// and data: URLs are rejected, because clients render the image as is.
+var imageSchemes = map[string]bool{
+	"http":  true,
+	"https": true,
+}
+
+// localImagePrefix allows images uploaded to the local media storage.
+const localImagePrefix = "/api/media/files/"
+
+func invalidImageError(format string, args ...interface{}) error {
+	return httperror.New(http.StatusUnprocessableEntity, "invalid_image", format, args...)
+}
+
+// validateImageURL returns an error unless image is empty, an absolute
+// http(s) URL, or a path to an uploaded file.
+func validateImageURL(image string) error {
+	if image == "" {
+		return nil
+	}
+	if len(image) > maxImageURLLength {
+		return invalidImageError("image URL must be at most %d characters long", maxImageURLLength)
+	}
+
+	if strings.HasPrefix(image, localImagePrefix) {
+		if strings.ContainsAny(image[len(localImagePrefix):], "/\\?#") {
+			return invalidImageError("image URL is not valid")
+		}
+		return nil
+	}
+
+	u, err := url.Parse(image)
+	if err != nil || u.Host == "" {
+		return invalidImageError("image must be an absolute URL")
+	}
+	if !imageSchemes[strings.ToLower(u.Scheme)] {
+		return invalidImageError("image URL scheme must be http or https")
+	}
+	if u.User != nil {
+		return invalidImageError("image URL must not contain credentials")
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+const identiconSize = 5
+
+// AvatarURL returns the URL of the generated avatar of the user.
+func AvatarURL(username string) string {
+	return "/api/profiles/" + url.PathEscape(username) + "/avatar.svg"
+}
+
+// Identicon returns a deterministic SVG avatar for the username: a symmetric
+// 5x5 pattern in a color derived from the username hash.
+func Identicon(username string) []byte {
+	sum := sha256.Sum256([]byte(username))
+
+	hue := (int(sum[0])<<8 | int(sum[1])) % 360
+	fg := fmt.Sprintf("hsl(%d,55%%,50%%)", hue)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="-0.5 -0.5 %d %d" `+
+		`width="120" height="120" shape-rendering="crispEdges">`, identiconSize+1, identiconSize+1)
+
+	buf.WriteString("<title>")
+	_ = xml.EscapeText(&buf, []byte(username))
+	buf.WriteString("</title>")
+	fmt.Fprintf(&buf, `<rect x="-0.5" y="-0.5" width="%d" height="%d" fill="#f0f0f0"/>`,
+		identiconSize+1, identiconSize+1)
+
+	// Only the left half and the middle column are random; the right half mirrors them.
+	const half = (identiconSize + 1) / 2
+	bit := 0
+	for x := 0; x < half; x++ {
+		for y := 0; y < identiconSize; y++ {
+			on := sum[2+bit/8]&(1<<(bit%8)) != 0
+			bit++
+			if !on {
+				continue
+			}
+
+			fmt.Fprintf(&buf, `<rect x="%d" y="%d" width="1" height="1" fill="%s"/>`, x, y, fg)
+			if mirror := identiconSize - 1 - x; mirror != x {
+				fmt.Fprintf(&buf, `<rect x="%d" y="%d" width="1" height="1" fill="%s"/>`, mirror, y, fg)
+			}
+		}
+	}
+
+	buf.WriteString("</svg>")
+	return buf.Bytes()
+}
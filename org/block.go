@@ -0,0 +1,226 @@
+package org
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun-realworld-app/bunapp"
+	"github.com/uptrace/bun-realworld-app/httputil/httperror"
+)
+
+var (
+	ErrBlocked = httperror.New(http.StatusForbidden,
+		"blocked", "you are blocked by the user")
+	errSelfRelation = httperror.New(http.StatusUnprocessableEntity,
+		"invalid_user", "you can't block or mute yourself")
+)
+
+// BlockUser stops BlockedUserID from following UserID and from commenting on
+// or favoriting articles by UserID.
+type BlockUser struct {
+	bun.BaseModel `bun:"alias:bu"`
+
+	UserID        uint64
+	BlockedUserID uint64
+	CreatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+// MuteUser hides articles and comments by MutedUserID from UserID.
+// Unlike blocking, the muted user can still follow UserID and interact with their articles.
+type MuteUser struct {
+	bun.BaseModel `bun:"alias:mu"`
+
+	UserID      uint64
+	MutedUserID uint64
+	CreatedAt   time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+// IsBlocked reports whether the owner blocked the user.
+func IsBlocked(ctx context.Context, app *bunapp.App, ownerID, userID uint64) (bool, error) {
+	if ownerID == userID {
+		return false, nil
+	}
+	return app.IDB(ctx).NewSelect().
+		Model((*BlockUser)(nil)).
+		Where("user_id = ?", ownerID).
+		Where("blocked_user_id = ?", userID).
+		Exists(ctx)
+}
+
+// MutedUserIDs returns a subquery that selects the ids of the users muted by the user.
+func MutedUserIDs(app *bunapp.App, userID uint64) *bun.SelectQuery {
+	return app.DB().NewSelect().
+		Model((*MuteUser)(nil)).
+		ColumnExpr("mu.muted_user_id").
+		Where("mu.user_id = ?", userID)
+}
+
+// lockUser locks the user row until the transaction ends. Block and Follow
+// take it on the blocking user, so a follow can't slip in after a block.
+func lockUser(ctx context.Context, tx bun.Tx, id uint64) error {
+	_, err := tx.NewSelect().
+		Model((*User)(nil)).
+		Column("id").
+		Where("id = ?", id).
+		For("UPDATE").
+		Exec(ctx)
+	return err
+}
+
+// ShareLockUser locks the user row in share mode until the transaction ends.
+// It conflicts with the lock taken by Block, so callers that check IsBlocked
+// after taking it can't race with a new block by the user.
+func ShareLockUser(ctx context.Context, tx bun.Tx, id uint64) error {
+	_, err := tx.NewSelect().
+		Model((*User)(nil)).
+		Column("id").
+		Where("id = ?", id).
+		For("SHARE").
+		Exec(ctx)
+	return err
+}
+
+// Block blocks the user with the username and removes follows in both directions.
+func (s *UserService) Block(ctx context.Context, user *User, username string) (*Profile, error) {
+	blocked, err := s.relatedUser(ctx, user, username)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.app.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if err := lockUser(ctx, tx, user.ID); err != nil {
+			return err
+		}
+
+		if _, err := tx.NewInsert().
+			Model(&BlockUser{
+				UserID:        user.ID,
+				BlockedUserID: blocked.ID,
+				CreatedAt:     s.app.Clock().Now(),
+			}).
+			On("CONFLICT DO NOTHING").
+			Exec(ctx); err != nil {
+			return err
+		}
+
+		res, err := tx.NewDelete().
+			Model((*FollowUser)(nil)).
+			Where("(user_id = ? AND followed_user_id = ?) OR (user_id = ? AND followed_user_id = ?)",
+				user.ID, blocked.ID, blocked.ID, user.ID).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n != 0 {
+			return s.invalidateFollow(ctx, user, blocked)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	// Reload the counters changed by the triggers.
+	return s.Profile(ctx, user, blocked.Username)
+}
+
+func (s *UserService) Unblock(ctx context.Context, user *User, username string) (*Profile, error) {
+	blocked, err := s.relatedUser(ctx, user, username)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.app.IDB(ctx).NewDelete().
+		Model((*BlockUser)(nil)).
+		Where("user_id = ?", user.ID).
+		Where("blocked_user_id = ?", blocked.ID).
+		Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	return s.Profile(ctx, user, blocked.Username)
+}
+
+func (s *UserService) Mute(ctx context.Context, user *User, username string) (*Profile, error) {
+	muted, err := s.relatedUser(ctx, user, username)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.app.IDB(ctx).NewInsert().
+		Model(&MuteUser{
+			UserID:      user.ID,
+			MutedUserID: muted.ID,
+			CreatedAt:   s.app.Clock().Now(),
+		}).
+		On("CONFLICT DO NOTHING").
+		Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	return s.Profile(ctx, user, muted.Username)
+}
+
+func (s *UserService) Unmute(ctx context.Context, user *User, username string) (*Profile, error) {
+	muted, err := s.relatedUser(ctx, user, username)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.app.IDB(ctx).NewDelete().
+		Model((*MuteUser)(nil)).
+		Where("user_id = ?", user.ID).
+		Where("muted_user_id = ?", muted.ID).
+		Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	return s.Profile(ctx, user, muted.Username)
+}
+
+// Blocked returns the profiles of the users blocked by the user, most recent first.
+func (s *UserService) Blocked(ctx context.Context, user *User) ([]*Profile, error) {
+	profiles := make([]*Profile, 0)
+	if err := s.app.IDB(ctx).NewSelect().
+		Model(&profiles).
+		Column("id", "username", "bio", "image", "followers_count", "following_count").
+		Apply(s.followingColumn(user)).
+		Join("JOIN block_users AS bu ON bu.blocked_user_id = u.id").
+		Where("bu.user_id = ?", user.ID).
+		OrderExpr("bu.created_at DESC, u.id ASC").
+		Scan(ctx); err != nil {
+		return nil, err
+	}
+	return profiles, nil
+}
+
+// Muted returns the profiles of the users muted by the user, most recent first.
+func (s *UserService) Muted(ctx context.Context, user *User) ([]*Profile, error) {
+	profiles := make([]*Profile, 0)
+	if err := s.app.IDB(ctx).NewSelect().
+		Model(&profiles).
+		Column("id", "username", "bio", "image", "followers_count", "following_count").
+		Apply(s.followingColumn(user)).
+		Join("JOIN mute_users AS mu ON mu.muted_user_id = u.id").
+		Where("mu.user_id = ?", user.ID).
+		OrderExpr("mu.created_at DESC, u.id ASC").
+		Scan(ctx); err != nil {
+		return nil, err
+	}
+	return profiles, nil
+}
+
+func (s *UserService) relatedUser(ctx context.Context, user *User, username string) (*User, error) {
+	other, err := SelectUserByUsername(ctx, s.app, username)
+	if err != nil {
+		return nil, userErr(err)
+	}
+	if other.ID == user.ID {
+		return nil, errSelfRelation
+	}
+	return other, nil
+}
@@ -0,0 +1,3 @@
+package org
+
+var TOTPCode = totpCode
@@ -2,28 +2,138 @@ package org
 
 import (
 	"context"
+	"net/http"
 
 	"github.com/uptrace/bun-realworld-app/bunapp"
+	"github.com/uptrace/bun-realworld-app/httputil/openapi"
 )
 
 func init() {
 	bunapp.OnStart("org.initRoutes", func(ctx context.Context, app *bunapp.App) error {
 		middleware := NewMiddleware(app)
 		userHandler := NewUserHandler(app)
+		notificationHandler := NewNotificationHandler(app)
+		adminHandler := NewAdminHandler(app)
+		twoFactorHandler := NewTwoFactorHandler(app)
+		oidcHandler := NewOIDCHandler(app)
+		personalTokenHandler := NewPersonalTokenHandler(app)
 
-		g := app.APIRouter().WithMiddleware(middleware.User)
+		g := app.API().WithMiddleware(middleware.User, openapi.Tags("users"))
 
-		g.POST("/users", userHandler.Create)
-		g.POST("/users/login", userHandler.Login)
-		g.GET("/profiles/:username", userHandler.Profile)
+		g.POST("/users", userHandler.Create,
+			openapi.Summary("Register"),
+			openapi.Request(UserRequest{}),
+			openapi.Returns(http.StatusOK, UserResponse{}))
+		g.POST("/users/login", userHandler.Login,
+			openapi.Summary("Log in"),
+			openapi.Request(LoginRequest{}),
+			openapi.Returns(http.StatusOK, LoginResponse{}))
+		g.POST("/users/login/2fa", twoFactorHandler.Login,
+			openapi.Summary("Complete a second factor challenge"),
+			openapi.Request(SecondFactorRequest{}),
+			openapi.Returns(http.StatusOK, UserResponse{}))
+		g.GET("/profiles/:username", userHandler.Profile,
+			openapi.Summary("Get a profile"),
+			openapi.Returns(http.StatusOK, ProfileResponse{}))
+		g.GET("/profiles/:username/avatar.svg", userHandler.Avatar,
+			openapi.Summary("Get a generated avatar"),
+			openapi.ReturnsBinary(http.StatusOK, "image/svg+xml"))
 
-		g = g.WithMiddleware(middleware.MustUser)
+		g.GET("/auth/providers", oidcHandler.Providers,
+			openapi.Summary("List identity providers"),
+			openapi.Returns(http.StatusOK, ProvidersResponse{}))
+		g.GET("/auth/:provider/authorize", oidcHandler.Authorize,
+			openapi.Summary("Start OpenID Connect login"),
+			openapi.Returns(http.StatusOK, AuthorizationResponse{}))
+		g.POST("/auth/:provider/callback", oidcHandler.Callback,
+			openapi.Summary("Finish OpenID Connect login"),
+			openapi.Request(CallbackRequest{}),
+			openapi.Returns(http.StatusOK, LoginResponse{}))
 
-		g.GET("/user/", userHandler.Current)
-		g.PUT("/user/", userHandler.Update)
+		g = g.WithMiddleware(middleware.MustUser, openapi.Security("token"))
 
-		g.POST("/profiles/:username/follow", userHandler.Follow)
-		g.DELETE("/profiles/:username/follow", userHandler.Unfollow)
+		g.GET("/user/", userHandler.Current,
+			openapi.Summary("Get the current user"),
+			openapi.Returns(http.StatusOK, UserResponse{}))
+		g.GET("/user/notifications", notificationHandler.List,
+			openapi.Summary("List notifications"),
+			openapi.Returns(http.StatusOK, NotificationsResponse{}))
+		g.GET("/user/blocks", userHandler.Blocked,
+			openapi.Summary("List blocked users"),
+			openapi.Returns(http.StatusOK, ProfilesResponse{}))
+		g.GET("/user/mutes", userHandler.Muted,
+			openapi.Summary("List muted users"),
+			openapi.Returns(http.StatusOK, ProfilesResponse{}))
+
+		{
+			g := g.WithMiddleware(middleware.RequireScope(ScopeProfileWrite))
+
+			g.PUT("/user/", userHandler.Update,
+				openapi.Summary("Update the current user"),
+				openapi.Request(UserUpdateRequest{}),
+				openapi.Returns(http.StatusOK, UserResponse{}))
+
+			g.POST("/profiles/:username/follow", userHandler.Follow,
+				openapi.Summary("Follow a user"),
+				openapi.Returns(http.StatusOK, ProfileResponse{}))
+			g.DELETE("/profiles/:username/follow", userHandler.Unfollow,
+				openapi.Summary("Unfollow a user"),
+				openapi.Returns(http.StatusOK, ProfileResponse{}))
+
+			g.POST("/profiles/:username/block", userHandler.Block,
+				openapi.Summary("Block a user"),
+				openapi.Returns(http.StatusOK, ProfileResponse{}))
+			g.DELETE("/profiles/:username/block", userHandler.Unblock,
+				openapi.Summary("Unblock a user"),
+				openapi.Returns(http.StatusOK, ProfileResponse{}))
+			g.POST("/profiles/:username/mute", userHandler.Mute,
+				openapi.Summary("Mute a user"),
+				openapi.Returns(http.StatusOK, ProfileResponse{}))
+			g.DELETE("/profiles/:username/mute", userHandler.Unmute,
+				openapi.Summary("Unmute a user"),
+				openapi.Returns(http.StatusOK, ProfileResponse{}))
+		}
+
+		g = g.WithMiddleware(middleware.MustSession)
+
+		g.POST("/user/2fa", twoFactorHandler.Enroll,
+			openapi.Summary("Start two-factor enrollment"),
+			openapi.Returns(http.StatusOK, TwoFactorResponse{}))
+		g.POST("/user/2fa/confirm", twoFactorHandler.Confirm,
+			openapi.Summary("Confirm two-factor enrollment"),
+			openapi.Request(SecondFactorRequest{}),
+			openapi.Returns(http.StatusOK, TwoFactorResponse{}))
+		g.POST("/user/2fa/disable", twoFactorHandler.Disable,
+			openapi.Summary("Disable two-factor authentication"),
+			openapi.Request(SecondFactorRequest{}),
+			openapi.Returns(http.StatusOK, TwoFactorResponse{}))
+
+		g.GET("/user/identities", oidcHandler.Identities,
+			openapi.Summary("List linked identities"),
+			openapi.Returns(http.StatusOK, IdentitiesResponse{}))
+		g.DELETE("/user/identities/:provider", oidcHandler.Unlink,
+			openapi.Summary("Unlink an identity"),
+			openapi.Returns(http.StatusOK, IdentitiesResponse{}))
+
+		g.GET("/user/tokens", personalTokenHandler.List,
+			openapi.Summary("List personal access tokens"),
+			openapi.Returns(http.StatusOK, TokensResponse{}))
+		g.POST("/user/tokens", personalTokenHandler.Create,
+			openapi.Summary("Create a personal access token"),
+			openapi.Request(TokenRequest{}),
+			openapi.Returns(http.StatusOK, TokenResponse{}))
+		g.DELETE("/user/tokens/:id", personalTokenHandler.Delete,
+			openapi.Summary("Revoke a personal access token"),
+			openapi.Returns(http.StatusOK, nil))
+
+		g = g.WithMiddleware(middleware.MustAdmin, openapi.Tags("admin"))
+
+		g.GET("/admin/users/:username/lock", adminHandler.LockStatus,
+			openapi.Summary("Get login lock status"),
+			openapi.Returns(http.StatusOK, LockResponse{}))
+		g.DELETE("/admin/users/:username/lock", adminHandler.Unlock,
+			openapi.Summary("Unlock login"),
+			openapi.Returns(http.StatusOK, LockResponse{}))
 
 		return nil
 	})
@@ -0,0 +1,331 @@
+package org
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun-realworld-app/bunapp"
+	"github.com/uptrace/bun-realworld-app/httputil/httperror"
+	"github.com/uptrace/bunrouter"
+)
+
+const (
+	loginScopeEmail = "email"
+	loginScopeIP    = "ip"
+)
+
+// loginLockClassID namespaces the advisory locks taken on login keys.
+const loginLockClassID = 4211
+
+var (
+	errLoginThrottled = httperror.New(http.StatusTooManyRequests,
+		"login_throttled", "Too many failed login attempts, try again later")
+	errAccountLocked = httperror.New(http.StatusTooManyRequests,
+		"account_locked", "Account is temporarily locked due to too many failed login attempts")
+)
+
+// ThrottleError is returned when a login is rejected without checking the password.
+type ThrottleError struct {
+	Err        httperror.Error
+	RetryAfter time.Duration
+}
+
+func (e *ThrottleError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ThrottleError) Unwrap() error {
+	return e.Err
+}
+
+// SetRetryAfter sets the Retry-After header if err is a ThrottleError.
+func SetRetryAfter(w http.ResponseWriter, err error) {
+	var throttleErr *ThrottleError
+	if !errors.As(err, &throttleErr) || throttleErr.RetryAfter <= 0 {
+		return
+	}
+	secs := int64((throttleErr.RetryAfter + time.Second - 1) / time.Second)
+	w.Header().Set("Retry-After", strconv.FormatInt(secs, 10))
+}
+
+type LoginAttempt struct {
+	bun.BaseModel `bun:"alias:la"`
+
+	Scope        string    `bun:",pk" json:"-"`
+	Key          string    `bun:",pk" json:"-"`
+	Failures     int       `json:"failedAttempts"`
+	LastFailedAt time.Time `json:"lastFailedAt"`
+	LockedUntil  time.Time `bun:",nullzero" json:"lockedUntil"`
+}
+
+func (a *LoginAttempt) Locked(now time.Time) bool {
+	return a.LockedUntil.After(now)
+}
+
+type loginKey struct {
+	scope string
+	key   string
+}
+
+func emailLoginKey(email string) loginKey {
+	return loginKey{
+		scope: loginScopeEmail,
+		key:   strings.ToLower(strings.TrimSpace(email)),
+	}
+}
+
+func ipLoginKey(ip string) loginKey {
+	return loginKey{
+		scope: loginScopeIP,
+		key:   ip,
+	}
+}
+
+// RemoteIP returns the client IP address used to throttle logins.
+func RemoteIP(req bunrouter.Request) string {
+	ip, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return ip
+}
+
+type loginThrottleConfig struct {
+	freeAttempts int
+	baseDelay    time.Duration
+	maxDelay     time.Duration
+	resetAfter   time.Duration
+
+	lockoutAttempts   int
+	ipLockoutAttempts int
+	lockoutDuration   time.Duration
+}
+
+// loginThrottle tracks failed logins per account and per IP address. It slows
+// down repeated failures with an exponential delay and locks the key for
+// a while once too many attempts were made.
+type loginThrottle struct {
+	app *bunapp.App
+	cfg loginThrottleConfig
+}
+
+func newLoginThrottle(app *bunapp.App) *loginThrottle {
+	c := &app.Config().Login
+	cfg := loginThrottleConfig{
+		freeAttempts:      c.FreeAttempts,
+		baseDelay:         c.BaseDelay,
+		maxDelay:          c.MaxDelay,
+		resetAfter:        c.ResetAfter,
+		lockoutAttempts:   c.LockoutAttempts,
+		ipLockoutAttempts: c.IPLockoutAttempts,
+		lockoutDuration:   c.LockoutDuration,
+	}
+
+	if cfg.freeAttempts == 0 {
+		cfg.freeAttempts = 3
+	}
+	if cfg.baseDelay == 0 {
+		cfg.baseDelay = time.Second
+	}
+	if cfg.maxDelay == 0 {
+		cfg.maxDelay = time.Minute
+	}
+	if cfg.resetAfter == 0 {
+		cfg.resetAfter = time.Hour
+	}
+	if cfg.lockoutAttempts == 0 {
+		cfg.lockoutAttempts = 10
+	}
+	if cfg.ipLockoutAttempts == 0 {
+		cfg.ipLockoutAttempts = 100
+	}
+	if cfg.lockoutDuration == 0 {
+		cfg.lockoutDuration = 15 * time.Minute
+	}
+
+	return &loginThrottle{
+		app: app,
+		cfg: cfg,
+	}
+}
+
+// Guard checks the keys and runs fn in a transaction that holds advisory locks
+// on them, so concurrent attempts with the same keys are checked and recorded
+// one at a time. Failures recorded by fn are kept even when fn returns an error.
+func (t *loginThrottle) Guard(ctx context.Context, fn func(ctx context.Context) error, keys ...loginKey) error {
+	var fnErr error
+	if err := t.app.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		for _, key := range keys {
+			if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(?, hashtext(?))",
+				loginLockClassID, key.scope+":"+key.key); err != nil {
+				return err
+			}
+		}
+
+		if err := t.Check(ctx, keys...); err != nil {
+			fnErr = err
+			return nil
+		}
+		fnErr = fn(ctx)
+		return nil
+	}); err != nil {
+		return err
+	}
+	return fnErr
+}
+
+// Check returns a ThrottleError when any of the keys is not allowed
+// to attempt a login right now.
+func (t *loginThrottle) Check(ctx context.Context, keys ...loginKey) error {
+	now := t.app.Clock().Now()
+
+	for _, key := range keys {
+		attempt, err := t.selectAttempt(ctx, key)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				continue
+			}
+			return err
+		}
+
+		if attempt.Locked(now) {
+			return &ThrottleError{
+				Err:        errAccountLocked,
+				RetryAfter: attempt.LockedUntil.Sub(now),
+			}
+		}
+		if now.Sub(attempt.LastFailedAt) >= t.cfg.resetAfter {
+			continue
+		}
+
+		if d := attempt.LastFailedAt.Add(t.delay(attempt.Failures)).Sub(now); d > 0 {
+			return &ThrottleError{
+				Err:        errLoginThrottled,
+				RetryAfter: d,
+			}
+		}
+	}
+
+	return nil
+}
+
+func (t *loginThrottle) delay(failures int) time.Duration {
+	n := failures - t.cfg.freeAttempts
+	if n < 0 {
+		return 0
+	}
+	if n > 30 {
+		return t.cfg.maxDelay
+	}
+
+	d := t.cfg.baseDelay << uint(n)
+	if d > t.cfg.maxDelay || d <= 0 {
+		d = t.cfg.maxDelay
+	}
+	return d
+}
+
+// fail records a failed login and reports whether the key has just been locked.
+func (t *loginThrottle) fail(ctx context.Context, key loginKey) (bool, error) {
+	now := t.app.Clock().Now()
+
+	attempt := &LoginAttempt{
+		Scope:        key.scope,
+		Key:          key.key,
+		Failures:     1,
+		LastFailedAt: now,
+	}
+	if _, err := t.app.IDB(ctx).NewInsert().
+		Model(attempt).
+		On("CONFLICT (scope, key) DO UPDATE").
+		Set("failures = CASE WHEN la.last_failed_at < ? THEN 1 ELSE la.failures + 1 END",
+			now.Add(-t.cfg.resetAfter)).
+		Set("last_failed_at = EXCLUDED.last_failed_at").
+		Returning("*").
+		Exec(ctx); err != nil {
+		return false, err
+	}
+
+	limit := t.cfg.lockoutAttempts
+	if key.scope == loginScopeIP {
+		limit = t.cfg.ipLockoutAttempts
+	}
+	if attempt.Failures < limit {
+		return false, nil
+	}
+
+	if _, err := t.app.IDB(ctx).NewUpdate().
+		Model(attempt).
+		Set("failures = 0").
+		Set("locked_until = ?", now.Add(t.cfg.lockoutDuration)).
+		WherePK().
+		Exec(ctx); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Failed records a failed login for every key and notifies the user
+// when the account gets locked.
+func (t *loginThrottle) Failed(ctx context.Context, user *User, keys ...loginKey) error {
+	for _, key := range keys {
+		locked, err := t.fail(ctx, key)
+		if err != nil {
+			return err
+		}
+		if locked && user != nil && key.scope == loginScopeEmail {
+			if err := t.notifyLocked(ctx, user); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Reset forgets failed logins after a successful one.
+func (t *loginThrottle) Reset(ctx context.Context, key loginKey) error {
+	if _, err := t.app.IDB(ctx).NewDelete().
+		Model((*LoginAttempt)(nil)).
+		Where("scope = ?", key.scope).
+		Where("key = ?", key.key).
+		Exec(ctx); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Status returns the login attempt for the key or an empty one.
+func (t *loginThrottle) Status(ctx context.Context, key loginKey) (*LoginAttempt, error) {
+	attempt, err := t.selectAttempt(ctx, key)
+	if err == sql.ErrNoRows {
+		return &LoginAttempt{Scope: key.scope, Key: key.key}, nil
+	}
+	return attempt, err
+}
+
+func (t *loginThrottle) selectAttempt(ctx context.Context, key loginKey) (*LoginAttempt, error) {
+	attempt := new(LoginAttempt)
+	if err := t.app.IDB(ctx).NewSelect().
+		Model(attempt).
+		Where("scope = ?", key.scope).
+		Where("key = ?", key.key).
+		Scan(ctx); err != nil {
+		return nil, err
+	}
+	return attempt, nil
+}
+
+func (t *loginThrottle) notifyLocked(ctx context.Context, user *User) error {
+	msg := fmt.Sprintf("Your account was locked for %s after too many failed login attempts.",
+		t.cfg.lockoutDuration)
+	return Notify(ctx, t.app, user.ID, NotificationAccountLocked, msg)
+}
@@ -0,0 +1,72 @@
+package org
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun-realworld-app/bunapp"
+	"github.com/uptrace/bunrouter"
+)
+
+const (
+	NotificationAccountLocked  = "account_locked"
+	NotificationReportResolved = "report_resolved"
+)
+
+type Notification struct {
+	bun.BaseModel `bun:"alias:n"`
+
+	ID      uint64 `json:"id"`
+	UserID  uint64 `json:"-"`
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Notify stores a notification that is shown to the user on the next visit.
+func Notify(ctx context.Context, app *bunapp.App, userID uint64, kind, msg string) error {
+	notif := &Notification{
+		UserID:    userID,
+		Kind:      kind,
+		Message:   msg,
+		CreatedAt: app.Clock().Now(),
+	}
+	if _, err := app.IDB(ctx).NewInsert().
+		Model(notif).
+		Exec(ctx); err != nil {
+		return err
+	}
+	return nil
+}
+
+type NotificationHandler struct {
+	app *bunapp.App
+}
+
+func NewNotificationHandler(app *bunapp.App) NotificationHandler {
+	return NotificationHandler{
+		app: app,
+	}
+}
+
+func (h NotificationHandler) List(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+	user := UserFromContext(ctx)
+
+	notifs := make([]*Notification, 0)
+	if err := h.app.DB().NewSelect().
+		Model(&notifs).
+		Where("user_id = ?", user.ID).
+		OrderExpr("id DESC").
+		Limit(100).
+		Scan(ctx); err != nil {
+		return err
+	}
+
+	return bunrouter.JSON(w, bunrouter.H{
+		"notifications": notifs,
+	})
+}
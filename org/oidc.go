@@ -0,0 +1,359 @@
+package org
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/uptrace/bun-realworld-app/bunapp"
+)
+
+const oidcCacheTTL = time.Hour
+
+var oidcHTTPClient = &http.Client{
+	Timeout: 10 * time.Second,
+}
+
+// oidcProvider is a minimal OpenID Connect relying party that supports
+// the authorization code flow with PKCE and RS256 ID tokens.
+type oidcProvider struct {
+	cfg *bunapp.OIDCProviderConfig
+
+	mu        sync.Mutex
+	fetchedAt time.Time
+	meta      *oidcMetadata
+	keys      map[string]*rsa.PublicKey
+}
+
+type oidcMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+var oidcProviders struct {
+	mu sync.Mutex
+	m  map[string]*oidcProvider
+}
+
+// getOIDCProvider returns a cached provider so discovery documents
+// and signing keys are not fetched on every login.
+func getOIDCProvider(cfg *bunapp.OIDCProviderConfig) *oidcProvider {
+	oidcProviders.mu.Lock()
+	defer oidcProviders.mu.Unlock()
+
+	key := cfg.Name + "\x00" + cfg.Issuer
+	if p, ok := oidcProviders.m[key]; ok && p.cfg.ClientID == cfg.ClientID {
+		p.cfg = cfg
+		return p
+	}
+
+	if oidcProviders.m == nil {
+		oidcProviders.m = make(map[string]*oidcProvider)
+	}
+	p := &oidcProvider{cfg: cfg}
+	oidcProviders.m[key] = p
+	return p
+}
+
+func (p *oidcProvider) metadata(ctx context.Context) (*oidcMetadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.meta != nil && time.Since(p.fetchedAt) < oidcCacheTTL {
+		return p.meta, nil
+	}
+
+	u := strings.TrimSuffix(p.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	meta := new(oidcMetadata)
+	if err := oidcGetJSON(ctx, u, meta); err != nil {
+		return nil, err
+	}
+	if meta.Issuer != p.cfg.Issuer {
+		return nil, fmt.Errorf("oidc: issuer mismatch: got %q, wanted %q", meta.Issuer, p.cfg.Issuer)
+	}
+
+	p.meta = meta
+	p.keys = nil
+	p.fetchedAt = time.Now()
+	return meta, nil
+}
+
+func (p *oidcProvider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	scopes := p.cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "email", "profile"}
+	}
+
+	q := make(url.Values)
+	q.Set("response_type", "code")
+	q.Set("client_id", p.cfg.ClientID)
+	q.Set("redirect_uri", p.cfg.RedirectURL)
+	q.Set("scope", strings.Join(scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", pkceChallenge(verifier))
+	q.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(meta.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return meta.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// Exchange trades the authorization code for an ID token.
+func (p *oidcProvider) Exchange(ctx context.Context, code, verifier string) (string, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	form := make(url.Values)
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.cfg.RedirectURL)
+	form.Set("client_id", p.cfg.ClientID)
+	form.Set("code_verifier", verifier)
+	if p.cfg.ClientSecret != "" {
+		form.Set("client_secret", p.cfg.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := oidcHTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var out struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&out); err != nil {
+		return "", fmt.Errorf("oidc: can't decode token response: %w", err)
+	}
+	if out.Error != "" {
+		return "", fmt.Errorf("oidc: %s: %s", out.Error, out.ErrorDescription)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("oidc: token endpoint returned %s", resp.Status)
+	}
+	if out.IDToken == "" {
+		return "", errors.New("oidc: token response has no id_token")
+	}
+
+	return out.IDToken, nil
+}
+
+type idTokenClaims struct {
+	Issuer    string     `json:"iss"`
+	Subject   string     `json:"sub"`
+	Audience  jwtStrings `json:"aud"`
+	ExpiresAt int64      `json:"exp"`
+	IssuedAt  int64      `json:"iat"`
+	Nonce     string     `json:"nonce"`
+
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	Name              string `json:"name"`
+	PreferredUsername string `json:"preferred_username"`
+	Picture           string `json:"picture"`
+}
+
+var _ jwt.Claims = (*idTokenClaims)(nil)
+
+// Valid is a no-op; claims are validated by Verify using the app clock.
+func (c *idTokenClaims) Valid() error {
+	return nil
+}
+
+// jwtStrings decodes claims that may be either a string or an array of strings.
+type jwtStrings []string
+
+func (s *jwtStrings) UnmarshalJSON(b []byte) error {
+	var str string
+	if err := json.Unmarshal(b, &str); err == nil {
+		*s = jwtStrings{str}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		return err
+	}
+	*s = list
+	return nil
+}
+
+func (s jwtStrings) Contains(v string) bool {
+	for _, el := range s {
+		if el == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Verify checks the ID token signature and claims.
+func (p *oidcProvider) Verify(
+	ctx context.Context, rawToken, nonce string, now time.Time,
+) (*idTokenClaims, error) {
+	claims := new(idTokenClaims)
+	parser := &jwt.Parser{SkipClaimsValidation: true}
+	if _, err := parser.ParseWithClaims(rawToken, claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodRS256 {
+			return nil, fmt.Errorf("oidc: unexpected signing method: %s", token.Method.Alg())
+		}
+		kid, _ := token.Header["kid"].(string)
+		return p.publicKey(ctx, kid)
+	}); err != nil {
+		return nil, err
+	}
+
+	const leeway = time.Minute
+
+	switch {
+	case claims.Issuer != p.cfg.Issuer:
+		return nil, errors.New("oidc: invalid issuer")
+	case !claims.Audience.Contains(p.cfg.ClientID):
+		return nil, errors.New("oidc: invalid audience")
+	case claims.Subject == "":
+		return nil, errors.New("oidc: subject is missing")
+	case claims.Nonce != nonce:
+		return nil, errors.New("oidc: invalid nonce")
+	case now.Add(-leeway).Unix() >= claims.ExpiresAt:
+		return nil, errors.New("oidc: ID token has expired")
+	case claims.IssuedAt != 0 && now.Add(leeway).Unix() < claims.IssuedAt:
+		return nil, errors.New("oidc: ID token is issued in the future")
+	}
+
+	return claims, nil
+}
+
+func (p *oidcProvider) publicKey(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+
+	// The key is unknown: the provider may have rotated its keys.
+	keys, err := fetchJWKS(ctx, meta.JWKSURI)
+	if err != nil {
+		return nil, err
+	}
+	p.keys = keys
+
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("oidc: unknown signing key %q", kid)
+}
+
+func fetchJWKS(ctx context.Context, url string) (map[string]*rsa.PublicKey, error) {
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := oidcGetJSON(ctx, url, &jwks); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(jwks.Keys))
+	for _, k := range jwks.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	return keys, nil
+}
+
+func oidcGetJSON(ctx context.Context, url string, dst interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := oidcHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("oidc: GET %s returned %s", url, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(dst)
+}
+
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
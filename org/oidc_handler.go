@@ -0,0 +1,424 @@
+package org
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun-realworld-app/bunapp"
+	"github.com/uptrace/bun-realworld-app/httputil"
+	"github.com/uptrace/bun-realworld-app/httputil/httperror"
+	"github.com/uptrace/bunrouter"
+)
+
+const oidcStateTTL = 10 * time.Minute
+
+var (
+	errUnknownProvider = httperror.NotFound("unknown identity provider")
+	errInvalidState    = httperror.BadRequest("invalid_state", "OIDC state is invalid or has expired")
+	errIdentityTaken   = httperror.New(http.StatusConflict,
+		"identity_taken", "This identity is already linked to another account")
+	errIdentityNotFound = httperror.NotFound("identity not found")
+	errEmailTaken       = httperror.New(http.StatusConflict,
+		"email_taken", "Log in to link this identity to the account with the same email")
+	errLastLoginMethod = httperror.New(http.StatusUnprocessableEntity,
+		"last_login_method", "Set a password or link another identity before unlinking this one")
+)
+
+// noPasswordHash is stored for provisioned users. It is not a valid hash,
+// so password login is impossible until a password is set.
+const noPasswordHash = "!"
+
+type UserIdentity struct {
+	bun.BaseModel `bun:"alias:ui"`
+
+	ID       uint64 `json:"-"`
+	UserID   uint64 `json:"-"`
+	Provider string `json:"provider"`
+	Subject  string `json:"subject"`
+	Email    string `bun:",nullzero" json:"email"`
+
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type OIDCState struct {
+	bun.BaseModel `bun:"oidc_states,alias:os"`
+
+	State        string `bun:",pk"`
+	Provider     string
+	Nonce        string
+	CodeVerifier string
+	// UserID is set when a logged in user links a new identity.
+	UserID    uint64 `bun:",nullzero"`
+	ExpiresAt time.Time
+}
+
+type OIDCHandler struct {
+	app *bunapp.App
+}
+
+func NewOIDCHandler(app *bunapp.App) OIDCHandler {
+	return OIDCHandler{
+		app: app,
+	}
+}
+
+func (h OIDCHandler) Providers(w http.ResponseWriter, req bunrouter.Request) error {
+	names := make([]string, 0, len(h.app.Config().OIDC.Providers))
+	for _, p := range h.app.Config().OIDC.Providers {
+		names = append(names, p.Name)
+	}
+	return bunrouter.JSON(w, bunrouter.H{
+		"providers": names,
+	})
+}
+
+// Authorize starts the authorization code flow. The frontend redirects
+// the browser to the returned URL.
+func (h OIDCHandler) Authorize(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+
+	cfg, ok := h.app.Config().OIDCProvider(req.Param("provider"))
+	if !ok {
+		return errUnknownProvider
+	}
+
+	state := &OIDCState{
+		Provider:  cfg.Name,
+		ExpiresAt: h.app.Clock().Now().Add(oidcStateTTL),
+	}
+	if user := UserFromContext(ctx); user != nil {
+		state.UserID = user.ID
+	}
+
+	var err error
+	if state.State, err = randomToken(24); err != nil {
+		return err
+	}
+	if state.Nonce, err = randomToken(24); err != nil {
+		return err
+	}
+	if state.CodeVerifier, err = randomToken(32); err != nil {
+		return err
+	}
+
+	authURL, err := getOIDCProvider(cfg).AuthCodeURL(ctx, state.State, state.Nonce, state.CodeVerifier)
+	if err != nil {
+		return err
+	}
+
+	// States are deleted when they are consumed, so abandoned flows
+	// are cleaned up here to keep the table bounded.
+	if _, err := h.app.DB().NewDelete().
+		Model((*OIDCState)(nil)).
+		Where("expires_at <= ?", h.app.Clock().Now()).
+		Exec(ctx); err != nil {
+		return err
+	}
+
+	if _, err := h.app.DB().NewInsert().
+		Model(state).
+		Exec(ctx); err != nil {
+		return err
+	}
+
+	return bunrouter.JSON(w, bunrouter.H{
+		"authorization": bunrouter.H{
+			"url":   authURL,
+			"state": state.State,
+		},
+	})
+}
+
+// Callback finishes the flow with the code and state the provider
+// passed to the redirect URL.
+func (h OIDCHandler) Callback(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+
+	cfg, ok := h.app.Config().OIDCProvider(req.Param("provider"))
+	if !ok {
+		return errUnknownProvider
+	}
+
+	var in CallbackRequest
+	if err := httputil.UnmarshalJSON(w, req, &in, 10<<kb); err != nil {
+		return err
+	}
+
+	state, err := h.consumeState(ctx, cfg.Name, in.State)
+	if err != nil {
+		return err
+	}
+
+	provider := getOIDCProvider(cfg)
+
+	rawToken, err := provider.Exchange(ctx, in.Code, state.CodeVerifier)
+	if err != nil {
+		return httperror.BadRequest("oidc_exchange", err.Error())
+	}
+
+	claims, err := provider.Verify(ctx, rawToken, state.Nonce, h.app.Clock().Now())
+	if err != nil {
+		return httperror.New(http.StatusUnauthorized, "invalid_id_token", err.Error())
+	}
+
+	var user *User
+	if err := h.app.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var err error
+		user, err = h.identityUser(ctx, cfg, state.UserID, claims)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	if user.TOTPEnabled {
+		resp, err := challengeResponse(h.app, user)
+		if err != nil {
+			return err
+		}
+		return bunrouter.JSON(w, resp)
+	}
+
+	if err := setUserToken(h.app, user); err != nil {
+		return err
+	}
+
+	return bunrouter.JSON(w, bunrouter.H{
+		"user": user,
+	})
+}
+
+func (h OIDCHandler) consumeState(ctx context.Context, provider, value string) (*OIDCState, error) {
+	state := new(OIDCState)
+	if _, err := h.app.DB().NewDelete().
+		Model(state).
+		Where("state = ?", value).
+		Where("provider = ?", provider).
+		Returning("*").
+		Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	if state.State == "" || !state.ExpiresAt.After(h.app.Clock().Now()) {
+		return nil, errInvalidState
+	}
+	return state, nil
+}
+
+// identityUser returns the user linked to the identity, linking or creating one
+// when the identity is seen for the first time. New identities are linked to
+// the logged in user, or by email when the provider opts in.
+func (h OIDCHandler) identityUser(
+	ctx context.Context, cfg *bunapp.OIDCProviderConfig, linkUserID uint64, claims *idTokenClaims,
+) (*User, error) {
+	identity := new(UserIdentity)
+	err := h.app.IDB(ctx).NewSelect().
+		Model(identity).
+		Where("provider = ?", cfg.Name).
+		Where("subject = ?", claims.Subject).
+		Scan(ctx)
+	switch err {
+	case nil:
+		if linkUserID != 0 && linkUserID != identity.UserID {
+			return nil, errIdentityTaken
+		}
+		return selectActiveUser(ctx, h.app, identity.UserID)
+	case sql.ErrNoRows:
+	default:
+		return nil, err
+	}
+
+	var user *User
+	switch {
+	case linkUserID != 0:
+		user, err = selectActiveUser(ctx, h.app, linkUserID)
+	case cfg.LinkByEmail && claims.Email != "" && claims.EmailVerified:
+		user, err = selectUserByEmail(ctx, h.app, claims.Email)
+		if err == sql.ErrNoRows {
+			user, err = h.provisionUser(ctx, claims)
+		} else if err == nil && user.CanModerate() {
+			err = errEmailTaken
+		} else if err == nil && user.Suspended() {
+			err = ErrSuspended
+		}
+	default:
+		user, err = h.provisionUser(ctx, claims)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	identity = &UserIdentity{
+		UserID:    user.ID,
+		Provider:  cfg.Name,
+		Subject:   claims.Subject,
+		Email:     claims.Email,
+		CreatedAt: h.app.Clock().Now(),
+	}
+	if _, err := h.app.IDB(ctx).NewInsert().
+		Model(identity).
+		Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// provisionUser creates a user without a password for a new identity.
+func (h OIDCHandler) provisionUser(ctx context.Context, claims *idTokenClaims) (*User, error) {
+	if claims.Email == "" {
+		return nil, httperror.New(http.StatusUnprocessableEntity,
+			"email_required", "identity provider did not return an email")
+	}
+
+	exists, err := h.app.IDB(ctx).NewSelect().
+		Model((*User)(nil)).
+		Where("email = ?", claims.Email).
+		Exists(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, errEmailTaken
+	}
+
+	username, err := h.uniqueUsername(ctx, usernameFromClaims(claims))
+	if err != nil {
+		return nil, err
+	}
+
+	// Providers are trusted less than users, so drop pictures the user couldn't set.
+	image := claims.Picture
+	if validateImageURL(image) != nil {
+		image = ""
+	}
+
+	user := &User{
+		Username:     username,
+		Email:        claims.Email,
+		Image:        image,
+		PasswordHash: noPasswordHash,
+	}
+	if _, err := h.app.IDB(ctx).NewInsert().
+		Model(user).
+		Exec(ctx); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (h OIDCHandler) uniqueUsername(ctx context.Context, base string) (string, error) {
+	for i := 1; i <= 100; i++ {
+		username := base
+		if i > 1 {
+			username = base + strconv.Itoa(i)
+		}
+
+		exists, err := h.app.IDB(ctx).NewSelect().
+			Model((*User)(nil)).
+			Where("username = ?", username).
+			Exists(ctx)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return username, nil
+		}
+	}
+
+	suffix, err := randomToken(4)
+	if err != nil {
+		return "", err
+	}
+	return base + "-" + strings.ToLower(suffix), nil
+}
+
+var usernameRe = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+func usernameFromClaims(claims *idTokenClaims) string {
+	candidates := []string{claims.PreferredUsername, claims.Name}
+	if i := strings.IndexByte(claims.Email, '@'); i > 0 {
+		candidates = append(candidates, claims.Email[:i])
+	}
+
+	for _, s := range candidates {
+		s = usernameRe.ReplaceAllString(strings.TrimSpace(s), "")
+		if len(s) > 50 {
+			s = s[:50]
+		}
+		if s != "" {
+			return s
+		}
+	}
+	return "user"
+}
+
+func (h OIDCHandler) Identities(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+	user := UserFromContext(ctx)
+
+	identities := make([]*UserIdentity, 0)
+	if err := h.app.DB().NewSelect().
+		Model(&identities).
+		Where("user_id = ?", user.ID).
+		OrderExpr("id ASC").
+		Scan(ctx); err != nil {
+		return err
+	}
+
+	return bunrouter.JSON(w, bunrouter.H{
+		"identities": identities,
+	})
+}
+
+// Unlink removes the identity of the provider unless it is the only way
+// left for the user to log in.
+func (h OIDCHandler) Unlink(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+	user := UserFromContext(ctx)
+	provider := req.Param("provider")
+
+	identities := make([]*UserIdentity, 0)
+	if err := h.app.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if err := tx.NewSelect().
+			Model(&identities).
+			Where("user_id = ?", user.ID).
+			OrderExpr("id ASC").
+			For("UPDATE").
+			Scan(ctx); err != nil {
+			return err
+		}
+
+		remaining := identities[:0]
+		for _, identity := range identities {
+			if identity.Provider != provider {
+				remaining = append(remaining, identity)
+			}
+		}
+		if len(remaining) == len(identities) {
+			return errIdentityNotFound
+		}
+		if len(remaining) == 0 && user.PasswordHash == noPasswordHash {
+			return errLastLoginMethod
+		}
+		identities = remaining
+
+		_, err := tx.NewDelete().
+			Model((*UserIdentity)(nil)).
+			Where("user_id = ?", user.ID).
+			Where("provider = ?", provider).
+			Exec(ctx)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	return bunrouter.JSON(w, bunrouter.H{
+		"identities": identities,
+	})
+}
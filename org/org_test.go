@@ -6,10 +6,15 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun-realworld-app/bunapp"
 	"github.com/uptrace/bun-realworld-app/org"
 	"github.com/uptrace/bun-realworld-app/testbed"
+	"golang.org/x/crypto/bcrypt"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -87,7 +92,7 @@ var _ = Describe("createUser", func() {
 
 		Describe("updateUser", func() {
 			BeforeEach(func() {
-				json := `{"user": {"username": "hello","email": "foo@bar.com", "image": "bar", "bio": "foo"}}`
+				json := `{"user": {"username": "hello","email": "foo@bar.com", "image": "https://example.com/bar.png", "bio": "foo"}}`
 				resp := testapp.Client().WithToken(user.ID).PutJSON("/api/user/", json)
 				data = parseJSON(resp, http.StatusOK)
 			})
@@ -98,18 +103,57 @@ var _ = Describe("createUser", func() {
 					"username":  Equal("hello"),
 					"email":     Equal("foo@bar.com"),
 					"bio":       Equal("foo"),
-					"image":     Equal("bar"),
+					"image":     Equal("https://example.com/bar.png"),
 					"token":     Not(BeEmpty()),
 					"following": Equal(false),
 				}))
 			})
+
+			It("validates the image URL", func() {
+				for _, image := range []string{
+					"javascript:alert(1)",
+					"data:image/svg+xml;base64,PHN2Zz4=",
+					"//example.com/foo.png",
+					"bar",
+					"https://" + strings.Repeat("a", 2048) + ".com",
+				} {
+					json := fmt.Sprintf(`{"user": {"username": "hello","email": "foo@bar.com", "image": %q}}`, image)
+					resp := testapp.Client().WithToken(user.ID).PutJSON("/api/user/", json)
+					data := parseJSON(resp, http.StatusUnprocessableEntity)
+					Expect(data["code"]).To(Equal("invalid_image"), image)
+				}
+
+				json := `{"user": {"username": "hello","email": "foo@bar.com", "image": "/api/media/files/0123.png"}}`
+				resp := testapp.Client().WithToken(user.ID).PutJSON("/api/user/", json)
+				data := parseJSON(resp, http.StatusOK)
+				Expect(data["user"]).To(HaveKeyWithValue("image", "/api/media/files/0123.png"))
+			})
+		})
+
+		Describe("avatar", func() {
+			It("generates a deterministic SVG", func() {
+				resp := testapp.Client().Get("/api/profiles/wangzitian0/avatar.svg")
+				Expect(resp.Code).To(Equal(http.StatusOK))
+				Expect(resp.Header().Get("Content-Type")).To(Equal("image/svg+xml"))
+				Expect(resp.Body.String()).To(HavePrefix("<svg "))
+				Expect(resp.Body.String()).To(ContainSubstring("<title>wangzitian0</title>"))
+
+				again := testapp.Client().Get("/api/profiles/wangzitian0/avatar.svg")
+				Expect(again.Body.String()).To(Equal(resp.Body.String()))
+				Expect(org.Identicon("someone-else")).NotTo(Equal(resp.Body.Bytes()))
+			})
+
+			It("returns 404 for unknown users", func() {
+				resp := testapp.Client().Get("/api/profiles/nobody/avatar.svg")
+				Expect(resp.Code).To(Equal(http.StatusNotFound))
+			})
 		})
 
 		Describe("followUser", func() {
 			var username string
 
 			BeforeEach(func() {
-				json := `{"user": {"username": "hello","email": "foo@bar.com","password": "pwd"}}`
+				json := `{"user": {"username": "hello","email": "foo@bar.com","password": "hello-pwd"}}`
 				resp := testapp.Client().PostJSON("/api/users", json)
 
 				data = parseJSON(resp, http.StatusOK)
@@ -130,8 +174,11 @@ var _ = Describe("createUser", func() {
 				Expect(profile).To(MatchAllKeys(Keys{
 					"username":  Equal("hello"),
 					"bio":       Equal(""),
-					"image":     Equal(""),
+					"image":     Equal("/api/profiles/hello/avatar.svg"),
 					"following": Equal(true),
+
+					"followersCount": Equal(float64(1)),
+					"followingCount": Equal(float64(0)),
 				}))
 			})
 
@@ -147,8 +194,11 @@ var _ = Describe("createUser", func() {
 					Expect(profile).To(MatchAllKeys(Keys{
 						"username":  Equal("hello"),
 						"bio":       Equal(""),
-						"image":     Equal(""),
+						"image":     Equal("/api/profiles/hello/avatar.svg"),
 						"following": Equal(false),
+
+						"followersCount": Equal(float64(0)),
+						"followingCount": Equal(float64(0)),
 					}))
 				})
 			})
@@ -156,6 +206,803 @@ var _ = Describe("createUser", func() {
 	})
 })
 
+var _ = Describe("password", func() {
+	var ctx context.Context
+	var testapp *testbed.TestApp
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		testapp = testbed.StartApp(ctx)
+		testapp.TruncateDB(ctx)
+	})
+
+	AfterEach(func() {
+		testapp.Stop()
+	})
+
+	It("rejects short passwords", func() {
+		json := `{"user": {"username": "hello","email": "foo@bar.com","password": "short"}}`
+		resp := testapp.Client().PostJSON("/api/users", json)
+		data := parseJSON(resp, http.StatusUnprocessableEntity)
+		Expect(data["code"]).To(Equal("weak_password"))
+	})
+
+	It("rejects breached passwords", func() {
+		json := `{"user": {"username": "hello","email": "foo@bar.com","password": "Password123"}}`
+		resp := testapp.Client().PostJSON("/api/users", json)
+		data := parseJSON(resp, http.StatusUnprocessableEntity)
+		Expect(data["code"]).To(Equal("weak_password"))
+	})
+
+	It("hashes passwords with argon2id", func() {
+		json := `{"user": {"username": "hello","email": "foo@bar.com","password": "hello-pwd"}}`
+		resp := testapp.Client().PostJSON("/api/users", json)
+		_ = parseJSON(resp, http.StatusOK)
+
+		user, err := org.SelectUserByUsername(ctx, testapp.App, "hello")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(user.PasswordHash).To(HavePrefix("$argon2id$v=19$m=1024,t=1,p=1$"))
+	})
+
+	It("upgrades bcrypt hashes on login", func() {
+		hash, err := bcrypt.GenerateFromPassword([]byte("hello-pwd"), bcrypt.MinCost)
+		Expect(err).NotTo(HaveOccurred())
+
+		user := &org.User{
+			Username:     "hello",
+			Email:        "foo@bar.com",
+			PasswordHash: string(hash),
+		}
+		_, err = testapp.DB().NewInsert().Model(user).Exec(ctx)
+		Expect(err).NotTo(HaveOccurred())
+
+		json := `{"user": {"email": "foo@bar.com","password": "hello-pwd"}}`
+		resp := testapp.Client().PostJSON("/api/users/login", json)
+		_ = parseJSON(resp, http.StatusOK)
+
+		user, err = org.SelectUserByUsername(ctx, testapp.App, "hello")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(user.PasswordHash).To(HavePrefix("$argon2id$"))
+
+		resp = testapp.Client().PostJSON("/api/users/login", json)
+		_ = parseJSON(resp, http.StatusOK)
+	})
+})
+
+var _ = Describe("twoFactor", func() {
+	var ctx context.Context
+	var testapp *testbed.TestApp
+	var user *org.User
+	var secret string
+	var recoveryCodes []interface{}
+
+	totpCode := func() string {
+		step := testapp.Clock().Now().Unix() / 30
+		code, err := org.TOTPCode(secret, step)
+		Expect(err).NotTo(HaveOccurred())
+		return code
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		testapp = testbed.StartApp(ctx)
+		testapp.TruncateDB(ctx)
+
+		json := `{"user": {"username": "wangzitian0","email": "wzt@gg.cn","password": "jakejxke"}}`
+		resp := testapp.Client().PostJSON("/api/users", json)
+		_ = parseJSON(resp, http.StatusOK)
+
+		var err error
+		user, err = org.SelectUserByUsername(ctx, testapp.App, "wangzitian0")
+		Expect(err).NotTo(HaveOccurred())
+
+		resp = testapp.Client().WithToken(user.ID).Post("/api/user/2fa", "")
+		data := parseJSON(resp, http.StatusOK)
+
+		twoFactor := data["twoFactor"].(map[string]interface{})
+		secret = twoFactor["secret"].(string)
+		Expect(twoFactor["otpauthUri"]).To(HavePrefix("otpauth://totp/Conduit:wzt@gg.cn?"))
+
+		json = fmt.Sprintf(`{"code": %q}`, totpCode())
+		resp = testapp.Client().WithToken(user.ID).PostJSON("/api/user/2fa/confirm", json)
+		data = parseJSON(resp, http.StatusOK)
+
+		recoveryCodes = data["twoFactor"].(map[string]interface{})["recoveryCodes"].([]interface{})
+		Expect(recoveryCodes).To(HaveLen(10))
+	})
+
+	AfterEach(func() {
+		testapp.Stop()
+	})
+
+	Describe("login", func() {
+		var challengeToken string
+
+		BeforeEach(func() {
+			json := `{"user": {"email": "wzt@gg.cn","password": "jakejxke"}}`
+			resp := testapp.Client().PostJSON("/api/users/login", json)
+			data := parseJSON(resp, http.StatusOK)
+
+			Expect(data).NotTo(HaveKey("user"))
+			challenge := data["challenge"].(map[string]interface{})
+			challengeToken = challenge["token"].(string)
+		})
+
+		It("requires a fresh TOTP code", func() {
+			json := fmt.Sprintf(`{"challengeToken": %q, "code": %q}`, challengeToken, totpCode())
+			resp := testapp.Client().PostJSON("/api/users/login/2fa", json)
+			data := parseJSON(resp, http.StatusUnauthorized)
+			Expect(data["code"]).To(Equal("invalid_code"))
+
+			testapp.AdvanceClock(30 * time.Second)
+
+			json = fmt.Sprintf(`{"challengeToken": %q, "code": %q}`, challengeToken, totpCode())
+			resp = testapp.Client().PostJSON("/api/users/login/2fa", json)
+			data = parseJSON(resp, http.StatusOK)
+			Expect(data["user"]).To(HaveKeyWithValue("token", Not(BeEmpty())))
+		})
+
+		It("accepts a recovery code once", func() {
+			json := fmt.Sprintf(`{"challengeToken": %q, "recoveryCode": %q}`,
+				challengeToken, recoveryCodes[0])
+			resp := testapp.Client().PostJSON("/api/users/login/2fa", json)
+			_ = parseJSON(resp, http.StatusOK)
+
+			resp = testapp.Client().PostJSON("/api/users/login/2fa", json)
+			_ = parseJSON(resp, http.StatusUnauthorized)
+		})
+
+		It("rejects the challenge when 2FA was disabled", func() {
+			json := fmt.Sprintf(`{"recoveryCode": %q}`, recoveryCodes[0])
+			resp := testapp.Client().WithToken(user.ID).PostJSON("/api/user/2fa/disable", json)
+			_ = parseJSON(resp, http.StatusOK)
+
+			json = fmt.Sprintf(`{"challengeToken": %q, "code": %q}`, challengeToken, totpCode())
+			resp = testapp.Client().PostJSON("/api/users/login/2fa", json)
+			data := parseJSON(resp, http.StatusUnauthorized)
+			Expect(data["code"]).To(Equal("invalid_challenge"))
+		})
+
+		It("rejects suspended users", func() {
+			err := org.NewUserService(testapp.App).Suspend(ctx, user.ID)
+			Expect(err).NotTo(HaveOccurred())
+
+			json := fmt.Sprintf(`{"challengeToken": %q, "recoveryCode": %q}`,
+				challengeToken, recoveryCodes[0])
+			resp := testapp.Client().PostJSON("/api/users/login/2fa", json)
+			data := parseJSON(resp, http.StatusForbidden)
+			Expect(data["code"]).To(Equal("suspended"))
+		})
+
+		It("expires the challenge", func() {
+			testapp.AdvanceClock(6 * time.Minute)
+
+			json := fmt.Sprintf(`{"challengeToken": %q, "code": %q}`, challengeToken, totpCode())
+			resp := testapp.Client().PostJSON("/api/users/login/2fa", json)
+			data := parseJSON(resp, http.StatusUnauthorized)
+			Expect(data["code"]).To(Equal("invalid_challenge"))
+		})
+	})
+})
+
+var _ = Describe("oidc", func() {
+	var ctx context.Context
+	var testapp *testbed.TestApp
+	var provider *testbed.OIDCProvider
+
+	callback := func(client testbed.Client, claims testbed.OIDCClaims) *httptest.ResponseRecorder {
+		resp := client.Get("/api/auth/company/authorize")
+		data := parseJSON(resp, http.StatusOK)
+
+		authURL := data["authorization"].(map[string]interface{})["url"].(string)
+		Expect(authURL).To(HavePrefix(provider.URL + "/authorize?"))
+		Expect(authURL).To(ContainSubstring("code_challenge_method=S256"))
+
+		code, state := provider.Authorize(authURL, claims)
+
+		json := fmt.Sprintf(`{"code": %q, "state": %q}`, code, state)
+		return client.PostJSON("/api/auth/company/callback", json)
+	}
+
+	signIn := func(client testbed.Client, claims testbed.OIDCClaims) map[string]interface{} {
+		return parseJSON(callback(client, claims), http.StatusOK)
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		testapp = testbed.StartApp(ctx)
+		testapp.TruncateDB(ctx)
+
+		provider = testbed.NewOIDCProvider("conduit", testapp.Clock().Now)
+		testapp.Config().OIDC.Providers = []bunapp.OIDCProviderConfig{{
+			Name:        "company",
+			Issuer:      provider.URL,
+			ClientID:    "conduit",
+			RedirectURL: "http://localhost:4100/auth/company/callback",
+		}}
+	})
+
+	AfterEach(func() {
+		provider.Close()
+		testapp.Stop()
+	})
+
+	It("provisions a new user", func() {
+		data := signIn(testapp.Client(), testbed.OIDCClaims{
+			Subject:           "sub-1",
+			Email:             "jane@company.com",
+			EmailVerified:     true,
+			PreferredUsername: "jane doe",
+		})
+		Expect(data["user"]).To(HaveKeyWithValue("username", "janedoe"))
+		Expect(data["user"]).To(HaveKeyWithValue("token", Not(BeEmpty())))
+
+		data = signIn(testapp.Client(), testbed.OIDCClaims{
+			Subject: "sub-1",
+		})
+		Expect(data["user"]).To(HaveKeyWithValue("email", "jane@company.com"))
+	})
+
+	It("picks a unique username", func() {
+		json := `{"user": {"username": "jane","email": "jane@gg.cn","password": "jakejxke"}}`
+		resp := testapp.Client().PostJSON("/api/users", json)
+		_ = parseJSON(resp, http.StatusOK)
+
+		data := signIn(testapp.Client(), testbed.OIDCClaims{
+			Subject:           "sub-2",
+			Email:             "jane@company.com",
+			PreferredUsername: "jane",
+		})
+		Expect(data["user"]).To(HaveKeyWithValue("username", "jane2"))
+	})
+
+	It("links the identity to the logged in user", func() {
+		json := `{"user": {"username": "jane","email": "jane@gg.cn","password": "jakejxke"}}`
+		resp := testapp.Client().PostJSON("/api/users", json)
+		_ = parseJSON(resp, http.StatusOK)
+
+		user, err := org.SelectUserByUsername(ctx, testapp.App, "jane")
+		Expect(err).NotTo(HaveOccurred())
+
+		data := signIn(testapp.Client().WithToken(user.ID), testbed.OIDCClaims{
+			Subject: "sub-3",
+			Email:   "jane@company.com",
+		})
+		Expect(data["user"]).To(HaveKeyWithValue("username", "jane"))
+
+		resp = testapp.Client().WithToken(user.ID).Get("/api/user/identities")
+		data = parseJSON(resp, http.StatusOK)
+		Expect(data["identities"]).To(HaveLen(1))
+	})
+
+	It("links identities by email only when the provider opts in", func() {
+		json := `{"user": {"username": "jane","email": "jane@company.com","password": "jakejxke"}}`
+		resp := testapp.Client().PostJSON("/api/users", json)
+		_ = parseJSON(resp, http.StatusOK)
+
+		admin := &org.User{
+			Username:     "admin",
+			Email:        "admin@company.com",
+			PasswordHash: "#",
+			IsAdmin:      true,
+		}
+		_, err := testapp.DB().NewInsert().Model(admin).Exec(ctx)
+		Expect(err).NotTo(HaveOccurred())
+
+		claims := testbed.OIDCClaims{
+			Subject:       "sub-7",
+			Email:         "jane@company.com",
+			EmailVerified: true,
+		}
+		data := parseJSON(callback(testapp.Client(), claims), http.StatusConflict)
+		Expect(data["code"]).To(Equal("email_taken"))
+
+		testapp.Config().OIDC.Providers[0].LinkByEmail = true
+
+		data = signIn(testapp.Client(), claims)
+		Expect(data["user"]).To(HaveKeyWithValue("username", "jane"))
+
+		data = parseJSON(callback(testapp.Client(), testbed.OIDCClaims{
+			Subject:       "sub-8",
+			Email:         "admin@company.com",
+			EmailVerified: true,
+		}), http.StatusConflict)
+		Expect(data["code"]).To(Equal("email_taken"))
+
+		n, err := testapp.DB().NewSelect().
+			Model((*org.UserIdentity)(nil)).
+			Where("user_id = ?", admin.ID).
+			Count(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n).To(Equal(0))
+	})
+
+	It("keeps the last login method of provisioned users", func() {
+		_ = signIn(testapp.Client(), testbed.OIDCClaims{
+			Subject:       "sub-6",
+			Email:         "ann@company.com",
+			EmailVerified: true,
+		})
+
+		user, err := org.SelectUserByUsername(ctx, testapp.App, "ann")
+		Expect(err).NotTo(HaveOccurred())
+		client := testapp.Client().WithToken(user.ID)
+
+		resp := client.Delete("/api/user/identities/unknown")
+		_ = parseJSON(resp, http.StatusNotFound)
+
+		resp = client.Delete("/api/user/identities/company")
+		data := parseJSON(resp, http.StatusUnprocessableEntity)
+		Expect(data["code"]).To(Equal("last_login_method"))
+
+		json := `{"user": {"password": "jakejxke"}}`
+		_ = parseJSON(client.PutJSON("/api/user/", json), http.StatusOK)
+
+		resp = client.Delete("/api/user/identities/company")
+		data = parseJSON(resp, http.StatusOK)
+		Expect(data["identities"]).To(BeEmpty())
+	})
+
+	It("rejects suspended users", func() {
+		claims := testbed.OIDCClaims{
+			Subject:       "sub-5",
+			Email:         "joe@company.com",
+			EmailVerified: true,
+		}
+		_ = signIn(testapp.Client(), claims)
+
+		user, err := org.SelectUserByUsername(ctx, testapp.App, "joe")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(org.NewUserService(testapp.App).Suspend(ctx, user.ID)).To(Succeed())
+
+		resp := testapp.Client().Get("/api/auth/company/authorize")
+		data := parseJSON(resp, http.StatusOK)
+		authURL := data["authorization"].(map[string]interface{})["url"].(string)
+		code, state := provider.Authorize(authURL, claims)
+
+		json := fmt.Sprintf(`{"code": %q, "state": %q}`, code, state)
+		resp = testapp.Client().PostJSON("/api/auth/company/callback", json)
+		data = parseJSON(resp, http.StatusForbidden)
+		Expect(data["code"]).To(Equal("suspended"))
+	})
+
+	It("deletes expired states", func() {
+		for i := 0; i < 3; i++ {
+			resp := testapp.Client().Get("/api/auth/company/authorize")
+			_ = parseJSON(resp, http.StatusOK)
+		}
+
+		testapp.AdvanceClock(11 * time.Minute)
+
+		resp := testapp.Client().Get("/api/auth/company/authorize")
+		_ = parseJSON(resp, http.StatusOK)
+
+		n, err := testapp.DB().NewSelect().
+			Model((*org.OIDCState)(nil)).
+			Count(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n).To(Equal(1))
+	})
+
+	It("rejects a reused state", func() {
+		resp := testapp.Client().Get("/api/auth/company/authorize")
+		data := parseJSON(resp, http.StatusOK)
+
+		authURL := data["authorization"].(map[string]interface{})["url"].(string)
+		code, state := provider.Authorize(authURL, testbed.OIDCClaims{
+			Subject: "sub-4",
+			Email:   "joe@company.com",
+		})
+
+		json := fmt.Sprintf(`{"code": %q, "state": %q}`, code, state)
+		resp = testapp.Client().PostJSON("/api/auth/company/callback", json)
+		_ = parseJSON(resp, http.StatusOK)
+
+		resp = testapp.Client().PostJSON("/api/auth/company/callback", json)
+		data = parseJSON(resp, http.StatusBadRequest)
+		Expect(data["code"]).To(Equal("invalid_state"))
+	})
+})
+
+var _ = Describe("personalTokens", func() {
+	var ctx context.Context
+	var testapp *testbed.TestApp
+	var user *org.User
+	var token string
+	var tokenID float64
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		testapp = testbed.StartApp(ctx)
+		testapp.TruncateDB(ctx)
+
+		json := `{"user": {"username": "wangzitian0","email": "wzt@gg.cn","password": "jakejxke"}}`
+		resp := testapp.Client().PostJSON("/api/users", json)
+		_ = parseJSON(resp, http.StatusOK)
+
+		var err error
+		user, err = org.SelectUserByUsername(ctx, testapp.App, "wangzitian0")
+		Expect(err).NotTo(HaveOccurred())
+
+		json = `{"token": {"name": "ci", "scopes": ["articles:write"]}}`
+		resp = testapp.Client().WithToken(user.ID).PostJSON("/api/user/tokens", json)
+		data := parseJSON(resp, http.StatusOK)
+
+		created := data["token"].(map[string]interface{})
+		token = created["token"].(string)
+		tokenID = created["id"].(float64)
+		Expect(token).To(HavePrefix("cdt_"))
+	})
+
+	AfterEach(func() {
+		testapp.Stop()
+	})
+
+	It("authenticates without issuing a session", func() {
+		resp := testapp.Client().WithAuthToken(token).Get("/api/user/")
+		data := parseJSON(resp, http.StatusOK)
+		Expect(data["user"]).To(HaveKeyWithValue("username", "wangzitian0"))
+		Expect(data["user"]).NotTo(HaveKey("token"))
+	})
+
+	It("enforces scopes", func() {
+		json := `{"user": {"username": "hello","email": "foo@bar.com"}}`
+		resp := testapp.Client().WithAuthToken(token).PutJSON("/api/user/", json)
+		data := parseJSON(resp, http.StatusForbidden)
+		Expect(data["code"]).To(Equal("insufficient_scope"))
+
+		resp = testapp.Client().WithAuthToken(token).Get("/api/user/tokens")
+		data = parseJSON(resp, http.StatusForbidden)
+		Expect(data["code"]).To(Equal("session_required"))
+	})
+
+	It("can't change the password or the email", func() {
+		json := `{"token": {"name": "profile", "scopes": ["profile:write"]}}`
+		resp := testapp.Client().WithToken(user.ID).PostJSON("/api/user/tokens", json)
+		data := parseJSON(resp, http.StatusOK)
+		profileToken := data["token"].(map[string]interface{})["token"].(string)
+
+		json = `{"user": {"username": "wangzitian0", "email": "wzt@gg.cn", "password": "hijacked1"}}`
+		resp = testapp.Client().WithAuthToken(profileToken).PutJSON("/api/user/", json)
+		data = parseJSON(resp, http.StatusForbidden)
+		Expect(data["code"]).To(Equal("session_required"))
+
+		json = `{"user": {"username": "wangzitian0", "email": "evil@gg.cn"}}`
+		resp = testapp.Client().WithAuthToken(profileToken).PutJSON("/api/user/", json)
+		data = parseJSON(resp, http.StatusForbidden)
+		Expect(data["code"]).To(Equal("session_required"))
+
+		json = `{"user": {"username": "wangzitian0", "email": "wzt@gg.cn", "bio": "hi"}}`
+		resp = testapp.Client().WithAuthToken(profileToken).PutJSON("/api/user/", json)
+		data = parseJSON(resp, http.StatusOK)
+		Expect(data["user"]).To(HaveKeyWithValue("bio", "hi"))
+	})
+
+	It("lists tokens without secrets", func() {
+		resp := testapp.Client().WithToken(user.ID).Get("/api/user/tokens")
+		data := parseJSON(resp, http.StatusOK)
+
+		tokens := data["tokens"].([]interface{})
+		Expect(tokens).To(HaveLen(1))
+		Expect(tokens[0]).NotTo(HaveKey("token"))
+		Expect(tokens[0]).To(HaveKeyWithValue("scopes", ConsistOf("articles:write")))
+	})
+
+	It("revokes tokens", func() {
+		url := fmt.Sprintf("/api/user/tokens/%d", uint64(tokenID))
+		resp := testapp.Client().WithToken(user.ID).Delete(url)
+		Expect(resp.Code).To(Equal(http.StatusOK))
+
+		resp = testapp.Client().WithAuthToken(token).Get("/api/user/")
+		Expect(resp.Code).NotTo(Equal(http.StatusOK))
+	})
+})
+
+var _ = Describe("loginThrottle", func() {
+	var ctx context.Context
+	var testapp *testbed.TestApp
+	var user *org.User
+
+	login := func(password string) *httptest.ResponseRecorder {
+		json := fmt.Sprintf(`{"user": {"email": "wzt@gg.cn","password": %q}}`, password)
+		return testapp.Client().PostJSON("/api/users/login", json)
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		testapp = testbed.StartApp(ctx)
+		testapp.TruncateDB(ctx)
+
+		json := `{"user": {"username": "wangzitian0","email": "wzt@gg.cn","password": "jakejxke"}}`
+		resp := testapp.Client().PostJSON("/api/users", json)
+		_ = parseJSON(resp, http.StatusOK)
+
+		var err error
+		user, err = org.SelectUserByUsername(ctx, testapp.App, "wangzitian0")
+		Expect(err).NotTo(HaveOccurred())
+
+		for i := 0; i < 3; i++ {
+			_ = parseJSON(login("wrong"), http.StatusUnauthorized)
+		}
+	})
+
+	AfterEach(func() {
+		testapp.Stop()
+	})
+
+	It("delays the next attempt", func() {
+		resp := login("jakejxke")
+		data := parseJSON(resp, http.StatusTooManyRequests)
+		Expect(data["code"]).To(Equal("login_throttled"))
+		Expect(resp.Header().Get("Retry-After")).To(Equal("1"))
+
+		testapp.AdvanceClock(time.Second)
+		_ = parseJSON(login("jakejxke"), http.StatusOK)
+	})
+
+	It("checks concurrent attempts one at a time", func() {
+		testapp.AdvanceClock(time.Second)
+
+		codes := make(chan int, 5)
+		for i := 0; i < cap(codes); i++ {
+			go func() {
+				defer GinkgoRecover()
+				codes <- login("wrong").Code
+			}()
+		}
+
+		var unauthorized int
+		for i := 0; i < cap(codes); i++ {
+			if <-codes == http.StatusUnauthorized {
+				unauthorized++
+			}
+		}
+		Expect(unauthorized).To(Equal(1))
+	})
+
+	Describe("lockout", func() {
+		BeforeEach(func() {
+			for i := 3; i < 10; i++ {
+				testapp.AdvanceClock(time.Minute)
+				_ = parseJSON(login("wrong"), http.StatusUnauthorized)
+			}
+		})
+
+		It("locks the account", func() {
+			data := parseJSON(login("jakejxke"), http.StatusTooManyRequests)
+			Expect(data["code"]).To(Equal("account_locked"))
+
+			testapp.AdvanceClock(15 * time.Minute)
+			_ = parseJSON(login("jakejxke"), http.StatusOK)
+		})
+
+		It("notifies the user", func() {
+			resp := testapp.Client().WithToken(user.ID).Get("/api/user/notifications")
+			data := parseJSON(resp, http.StatusOK)
+
+			notifs := data["notifications"].([]interface{})
+			Expect(notifs).To(HaveLen(1))
+			Expect(notifs[0]).To(HaveKeyWithValue("kind", org.NotificationAccountLocked))
+		})
+
+		It("exposes lock status to admins", func() {
+			url := "/api/admin/users/wangzitian0/lock"
+
+			resp := testapp.Client().WithToken(user.ID).Get(url)
+			_ = parseJSON(resp, http.StatusForbidden)
+
+			admin := &org.User{
+				Username:     "admin",
+				Email:        "admin@gg.cn",
+				PasswordHash: "#",
+				IsAdmin:      true,
+			}
+			_, err := testapp.DB().NewInsert().Model(admin).Exec(ctx)
+			Expect(err).NotTo(HaveOccurred())
+
+			resp = testapp.Client().WithToken(admin.ID).Get(url)
+			data := parseJSON(resp, http.StatusOK)
+			Expect(data["lock"]).To(HaveKeyWithValue("locked", true))
+
+			resp = testapp.Client().WithToken(admin.ID).Delete(url)
+			_ = parseJSON(resp, http.StatusOK)
+
+			// Wait out the per-IP delay.
+			testapp.AdvanceClock(time.Minute)
+			_ = parseJSON(login("jakejxke"), http.StatusOK)
+		})
+	})
+})
+
+var _ = Describe("UserService", func() {
+	var ctx context.Context
+	var testapp *testbed.TestApp
+	var service *org.UserService
+	var user *org.User
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		testapp = testbed.StartApp(ctx)
+		testapp.TruncateDB(ctx)
+
+		service = org.NewUserService(testapp.App)
+
+		user = &org.User{
+			Username: "wangzitian0",
+			Email:    "wzt@gg.cn",
+			Password: "jakejxke",
+		}
+		err := service.Create(ctx, user)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(user.ID).NotTo(BeZero())
+		Expect(user.Password).To(BeEmpty())
+	})
+
+	AfterEach(func() {
+		testapp.Stop()
+	})
+
+	It("logs in with the password", func() {
+		got, err := service.Login(ctx, "wzt@gg.cn", "jakejxke", "127.0.0.1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(got.ID).To(Equal(user.ID))
+	})
+
+	It("rejects invalid credentials", func() {
+		_, err := service.Login(ctx, "wzt@gg.cn", "wrong", "127.0.0.1")
+		Expect(err).To(Equal(org.ErrInvalidCredentials))
+
+		_, err = service.Login(ctx, "unknown@gg.cn", "jakejxke", "127.0.0.2")
+		Expect(err).To(Equal(org.ErrInvalidCredentials))
+	})
+
+	It("follows and unfollows users", func() {
+		followed := &org.User{
+			Username: "followed",
+			Email:    "followed@gg.cn",
+			Password: "jakejxke",
+		}
+		Expect(service.Create(ctx, followed)).NotTo(HaveOccurred())
+
+		profile, err := service.Follow(ctx, user, "followed")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(profile.Following).To(BeTrue())
+
+		_, err = service.Follow(ctx, user, "followed")
+		Expect(err).NotTo(HaveOccurred())
+
+		profile, err = service.Profile(ctx, user, "followed")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(profile.Following).To(BeTrue())
+
+		profile, err = service.Unfollow(ctx, user, "followed")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(profile.Following).To(BeFalse())
+	})
+
+	It("blocks and mutes users", func() {
+		other := &org.User{
+			Username: "harasser",
+			Email:    "harasser@gg.cn",
+			Password: "jakejxke",
+		}
+		Expect(service.Create(ctx, other)).NotTo(HaveOccurred())
+
+		_, err := service.Follow(ctx, other, user.Username)
+		Expect(err).NotTo(HaveOccurred())
+
+		profile, err := service.Block(ctx, user, "harasser")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(profile.FollowingCount).To(Equal(0))
+
+		_, err = service.Follow(ctx, other, user.Username)
+		Expect(err).To(Equal(org.ErrBlocked))
+
+		profile, err = service.Profile(ctx, nil, user.Username)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(profile.FollowersCount).To(Equal(0))
+
+		blocked, err := service.Blocked(ctx, user)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(blocked).To(HaveLen(1))
+		Expect(blocked[0].Username).To(Equal("harasser"))
+
+		_, err = service.Unblock(ctx, user, "harasser")
+		Expect(err).NotTo(HaveOccurred())
+		_, err = service.Follow(ctx, other, user.Username)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = service.Mute(ctx, user, "harasser")
+		Expect(err).NotTo(HaveOccurred())
+		muted, err := service.Muted(ctx, user)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(muted).To(HaveLen(1))
+
+		_, err = service.Unmute(ctx, user, "harasser")
+		Expect(err).NotTo(HaveOccurred())
+		muted, err = service.Muted(ctx, user)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(muted).To(BeEmpty())
+
+		_, err = service.Block(ctx, user, user.Username)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("invalidates cached profiles on update", func() {
+		profile, err := service.Profile(ctx, nil, "wangzitian0")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(profile.Bio).To(Equal(""))
+
+		username, bio := "wzt", "hello"
+		err = service.Update(ctx, user, &org.UserUpdate{
+			Username: &username,
+			Bio:      &bio,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(user.Email).To(Equal("wzt@gg.cn"))
+
+		profile, err = service.Profile(ctx, nil, "wzt")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(profile.Bio).To(Equal("hello"))
+
+		_, err = service.Profile(ctx, nil, "wangzitian0")
+		Expect(err).To(Equal(org.ErrUserNotFound))
+	})
+
+	It("invalidates cached profiles after the transaction commits", func() {
+		_, err := service.Profile(ctx, nil, "wangzitian0")
+		Expect(err).NotTo(HaveOccurred())
+
+		bio := "hello"
+		err = testapp.RunInTx(ctx, nil, func(txCtx context.Context, tx bun.Tx) error {
+			if err := service.Update(txCtx, user, &org.UserUpdate{Bio: &bio}); err != nil {
+				return err
+			}
+
+			// Other requests keep seeing the committed profile.
+			profile, err := service.Profile(ctx, nil, "wangzitian0")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(profile.Bio).To(Equal(""))
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		profile, err := service.Profile(ctx, nil, "wangzitian0")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(profile.Bio).To(Equal("hello"))
+	})
+
+	It("expires cached profiles with the app clock", func() {
+		_, err := service.Profile(ctx, nil, "wangzitian0")
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = testapp.DB().NewUpdate().
+			Model((*org.User)(nil)).
+			Set("bio = ?", "hello").
+			Where("id = ?", user.ID).
+			Exec(ctx)
+		Expect(err).NotTo(HaveOccurred())
+
+		profile, err := service.Profile(ctx, nil, "wangzitian0")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(profile.Bio).To(Equal(""))
+
+		testapp.AdvanceClock(6 * time.Minute)
+
+		profile, err = service.Profile(ctx, nil, "wangzitian0")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(profile.Bio).To(Equal("hello"))
+	})
+
+	It("returns ErrUserNotFound for unknown users", func() {
+		_, err := service.Profile(ctx, nil, "unknown")
+		Expect(err).To(Equal(org.ErrUserNotFound))
+
+		_, err = service.Follow(ctx, user, "unknown")
+		Expect(err).To(Equal(org.ErrUserNotFound))
+	})
+})
+
 func parseJSON(resp *httptest.ResponseRecorder, code int) map[string]interface{} {
 	out := make(map[string]interface{})
 	err := json.Unmarshal(resp.Body.Bytes(), &out)
@@ -0,0 +1,337 @@
+package org
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/uptrace/bun-realworld-app/bunapp"
+	"github.com/uptrace/bun-realworld-app/httputil/httperror"
+)
+
+const (
+	algArgon2id = "argon2id"
+	algBcrypt   = "bcrypt"
+)
+
+var errUnknownHash = errors.New("unknown password hash format")
+
+// PasswordHasher hashes passwords into self-describing strings so the algorithm
+// and its parameters can be changed without invalidating existing hashes.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	// Verify reports whether the password matches the hash.
+	Verify(hash, password string) (bool, error)
+	// NeedsRehash reports whether the hash was created with other parameters.
+	NeedsRehash(hash string) bool
+}
+
+func NewPasswordHasher(app *bunapp.App) PasswordHasher {
+	cfg := &app.Config().Password
+
+	argon := &argon2idHasher{
+		memory:      cfg.Argon2id.Memory,
+		iterations:  cfg.Argon2id.Iterations,
+		parallelism: cfg.Argon2id.Parallelism,
+		saltLength:  cfg.Argon2id.SaltLength,
+		keyLength:   cfg.Argon2id.KeyLength,
+	}
+	if argon.memory == 0 {
+		argon.memory = 64 * 1024
+	}
+	if argon.iterations == 0 {
+		argon.iterations = 3
+	}
+	if argon.parallelism == 0 {
+		argon.parallelism = 2
+	}
+	if argon.saltLength == 0 {
+		argon.saltLength = 16
+	}
+	if argon.keyLength == 0 {
+		argon.keyLength = 32
+	}
+
+	bc := &bcryptHasher{
+		cost: cfg.Bcrypt.Cost,
+	}
+	if bc.cost == 0 {
+		bc.cost = bcrypt.DefaultCost
+	}
+
+	h := &multiHasher{
+		argon2id: argon,
+		bcrypt:   bc,
+	}
+	switch cfg.Algorithm {
+	case algBcrypt:
+		h.primary = bc
+	default:
+		h.primary = argon
+	}
+	return h
+}
+
+// multiHasher creates hashes with the primary algorithm and verifies hashes
+// created by any of the supported algorithms.
+type multiHasher struct {
+	primary  PasswordHasher
+	argon2id *argon2idHasher
+	bcrypt   *bcryptHasher
+}
+
+func (h *multiHasher) Hash(password string) (string, error) {
+	return h.primary.Hash(password)
+}
+
+func (h *multiHasher) Verify(hash, password string) (bool, error) {
+	hasher, err := h.hasherFor(hash)
+	if err != nil {
+		return false, err
+	}
+	return hasher.Verify(hash, password)
+}
+
+func (h *multiHasher) NeedsRehash(hash string) bool {
+	hasher, err := h.hasherFor(hash)
+	if err != nil {
+		return true
+	}
+	if hasher != h.primary {
+		return true
+	}
+	return hasher.NeedsRehash(hash)
+}
+
+func (h *multiHasher) hasherFor(hash string) (PasswordHasher, error) {
+	switch {
+	case strings.HasPrefix(hash, "$"+algArgon2id+"$"):
+		return h.argon2id, nil
+	case strings.HasPrefix(hash, "$2a$"),
+		strings.HasPrefix(hash, "$2b$"),
+		strings.HasPrefix(hash, "$2y$"):
+		return h.bcrypt, nil
+	default:
+		return nil, errUnknownHash
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type argon2idHasher struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	saltLength  uint32
+	keyLength   uint32
+}
+
+type argon2idParams struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	salt        []byte
+	key         []byte
+}
+
+// Hash returns the hash in PHC string format, for example,
+// $argon2id$v=19$m=65536,t=3,p=2$c2FsdA$aGFzaA.
+func (h *argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt,
+		h.iterations, h.memory, h.parallelism, h.keyLength)
+
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		algArgon2id, argon2.Version, h.memory, h.iterations, h.parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (h *argon2idHasher) Verify(hash, password string) (bool, error) {
+	p, err := parseArgon2id(hash)
+	if err != nil {
+		return false, err
+	}
+
+	key := argon2.IDKey([]byte(password), p.salt,
+		p.iterations, p.memory, p.parallelism, uint32(len(p.key)))
+	return subtle.ConstantTimeCompare(key, p.key) == 1, nil
+}
+
+func (h *argon2idHasher) NeedsRehash(hash string) bool {
+	p, err := parseArgon2id(hash)
+	if err != nil {
+		return true
+	}
+	return p.memory != h.memory ||
+		p.iterations != h.iterations ||
+		p.parallelism != h.parallelism ||
+		uint32(len(p.salt)) != h.saltLength ||
+		uint32(len(p.key)) != h.keyLength
+}
+
+func parseArgon2id(hash string) (*argon2idParams, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != algArgon2id {
+		return nil, errUnknownHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return nil, err
+	}
+	if version != argon2.Version {
+		return nil, fmt.Errorf("unsupported argon2 version: %d", version)
+	}
+
+	p := new(argon2idParams)
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d",
+		&p.memory, &p.iterations, &p.parallelism); err != nil {
+		return nil, err
+	}
+
+	var err error
+	p.salt, err = base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, err
+	}
+	p.key, err = base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+//------------------------------------------------------------------------------
+
+type bcryptHasher struct {
+	cost int
+}
+
+func (h *bcryptHasher) Hash(password string) (string, error) {
+	b, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func (h *bcryptHasher) Verify(hash, password string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (h *bcryptHasher) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		return true
+	}
+	return cost != h.cost
+}
+
+//------------------------------------------------------------------------------
+
+func weakPasswordError(msg string, args ...interface{}) httperror.Error {
+	return httperror.New(http.StatusUnprocessableEntity, "weak_password", msg, args...)
+}
+
+// checkPasswordPolicy returns an error if the password is too short, too long,
+// or appears in the list of breached passwords.
+func checkPasswordPolicy(app *bunapp.App, password string) error {
+	cfg := &app.Config().Password
+
+	minLen := cfg.MinLength
+	if minLen == 0 {
+		minLen = 8
+	}
+	maxLen := cfg.MaxLength
+	if maxLen == 0 {
+		maxLen = 128
+	}
+
+	n := utf8.RuneCountInString(password)
+	if n < minLen {
+		return weakPasswordError("password must be at least %d characters long", minLen)
+	}
+	if n > maxLen {
+		return weakPasswordError("password must be at most %d characters long", maxLen)
+	}
+
+	if cfg.BreachedList == "" {
+		return nil
+	}
+
+	breached, err := loadBreachedPasswords(cfg.BreachedList)
+	if err != nil {
+		return err
+	}
+	if _, ok := breached[strings.ToLower(password)]; ok {
+		return weakPasswordError("password is too common and has appeared in a data breach")
+	}
+
+	return nil
+}
+
+var breachedPasswords struct {
+	mu    sync.Mutex
+	lists map[string]map[string]struct{}
+}
+
+// loadBreachedPasswords reads a newline-separated list of passwords
+// from the app FS and caches it.
+func loadBreachedPasswords(name string) (map[string]struct{}, error) {
+	breachedPasswords.mu.Lock()
+	defer breachedPasswords.mu.Unlock()
+
+	if set, ok := breachedPasswords.lists[name]; ok {
+		return set, nil
+	}
+
+	b, err := fs.ReadFile(bunapp.FS(), name)
+	if err != nil {
+		return nil, err
+	}
+
+	set := make(map[string]struct{})
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		set[strings.ToLower(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if breachedPasswords.lists == nil {
+		breachedPasswords.lists = make(map[string]map[string]struct{})
+	}
+	breachedPasswords.lists[name] = set
+
+	return set, nil
+}
@@ -0,0 +1,84 @@
+package org
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun-realworld-app/bunapp"
+)
+
+// personalTokenPrefix distinguishes personal tokens from JWT session tokens.
+const personalTokenPrefix = "cdt_"
+
+var errInvalidPersonalToken = errors.New("personal token is invalid or has expired")
+
+type PersonalToken struct {
+	bun.BaseModel `bun:"alias:pt"`
+
+	ID          uint64   `json:"id"`
+	UserID      uint64   `json:"-"`
+	Name        string   `json:"name"`
+	TokenHash   string   `json:"-"`
+	TokenPrefix string   `json:"prefix"`
+	Scopes      []string `bun:",array" json:"scopes"`
+
+	CreatedAt  time.Time `json:"createdAt"`
+	LastUsedAt time.Time `bun:",nullzero" json:"lastUsedAt"`
+	ExpiresAt  time.Time `bun:",nullzero" json:"expiresAt"`
+
+	// Token is the plain token. It is only returned once on creation.
+	Token string `bun:"-" json:"token,omitempty"`
+}
+
+func (t *PersonalToken) Expired(now time.Time) bool {
+	return !t.ExpiresAt.IsZero() && !t.ExpiresAt.After(now)
+}
+
+func isPersonalToken(token string) bool {
+	return strings.HasPrefix(token, personalTokenPrefix)
+}
+
+func newPersonalToken() (string, error) {
+	s, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	return personalTokenPrefix + s, nil
+}
+
+func hashPersonalToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// selectPersonalToken finds a valid token and marks it as used.
+func selectPersonalToken(ctx context.Context, app *bunapp.App, token string) (*PersonalToken, error) {
+	pt := new(PersonalToken)
+	if err := app.DB().NewSelect().
+		Model(pt).
+		Where("token_hash = ?", hashPersonalToken(token)).
+		Scan(ctx); err != nil {
+		return nil, errInvalidPersonalToken
+	}
+
+	now := app.Clock().Now()
+	if pt.Expired(now) {
+		return nil, errInvalidPersonalToken
+	}
+
+	if _, err := app.DB().NewUpdate().
+		Model(pt).
+		Set("last_used_at = ?", now).
+		Where("id = ?", pt.ID).
+		Exec(ctx); err != nil {
+		return nil, err
+	}
+	pt.LastUsedAt = now
+
+	return pt, nil
+}
@@ -0,0 +1,119 @@
+package org
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/uptrace/bun-realworld-app/bunapp"
+	"github.com/uptrace/bun-realworld-app/httputil"
+	"github.com/uptrace/bun-realworld-app/httputil/httperror"
+	"github.com/uptrace/bunrouter"
+)
+
+type PersonalTokenHandler struct {
+	app *bunapp.App
+}
+
+func NewPersonalTokenHandler(app *bunapp.App) PersonalTokenHandler {
+	return PersonalTokenHandler{
+		app: app,
+	}
+}
+
+func (h PersonalTokenHandler) List(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+	user := UserFromContext(ctx)
+
+	tokens := make([]*PersonalToken, 0)
+	if err := h.app.DB().NewSelect().
+		Model(&tokens).
+		Where("user_id = ?", user.ID).
+		OrderExpr("id ASC").
+		Scan(ctx); err != nil {
+		return err
+	}
+
+	return bunrouter.JSON(w, bunrouter.H{
+		"tokens": tokens,
+	})
+}
+
+func (h PersonalTokenHandler) Create(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+	user := UserFromContext(ctx)
+
+	var in TokenRequest
+	if err := httputil.UnmarshalJSON(w, req, &in, 10<<kb); err != nil {
+		return err
+	}
+
+	if in.Token == nil {
+		return errors.New(`JSON field "token" is required`)
+	}
+
+	name := strings.TrimSpace(in.Token.Name)
+	if name == "" {
+		return httperror.New(http.StatusUnprocessableEntity, "invalid_token", "token name is required")
+	}
+	if len(in.Token.Scopes) == 0 {
+		return httperror.New(http.StatusUnprocessableEntity, "invalid_token", "at least one scope is required")
+	}
+	for _, scope := range in.Token.Scopes {
+		if !validScope(scope) {
+			return httperror.New(http.StatusUnprocessableEntity,
+				"invalid_scope", "unknown scope %q", scope)
+		}
+	}
+
+	now := h.app.Clock().Now()
+	if !in.Token.ExpiresAt.IsZero() && !in.Token.ExpiresAt.After(now) {
+		return httperror.New(http.StatusUnprocessableEntity,
+			"invalid_token", "expiresAt must be in the future")
+	}
+
+	plain, err := newPersonalToken()
+	if err != nil {
+		return err
+	}
+
+	token := &PersonalToken{
+		UserID:      user.ID,
+		Name:        name,
+		TokenHash:   hashPersonalToken(plain),
+		TokenPrefix: plain[:len(personalTokenPrefix)+4],
+		Scopes:      in.Token.Scopes,
+		CreatedAt:   now,
+		ExpiresAt:   in.Token.ExpiresAt,
+	}
+	if _, err := h.app.DB().NewInsert().
+		Model(token).
+		Exec(ctx); err != nil {
+		return err
+	}
+
+	token.Token = plain
+	return bunrouter.JSON(w, bunrouter.H{
+		"token": token,
+	})
+}
+
+func (h PersonalTokenHandler) Delete(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+	user := UserFromContext(ctx)
+
+	id, err := req.Params().Uint64("id")
+	if err != nil {
+		return err
+	}
+
+	if _, err := h.app.DB().NewDelete().
+		Model((*PersonalToken)(nil)).
+		Where("id = ?", id).
+		Where("user_id = ?", user.ID).
+		Exec(ctx); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -26,6 +26,9 @@ func decodeUserToken(app *bunapp.App, jwtToken string) (uint64, error) {
 	}
 
 	claims := token.Claims.(*jwt.StandardClaims)
+	if claims.Audience != "" {
+		return 0, errors.New("invalid token audience")
+	}
 
 	id, err := strconv.ParseUint(claims.Subject, 10, 64)
 	if err != nil {
@@ -45,3 +48,49 @@ func CreateUserToken(app *bunapp.App, userID uint64, ttl time.Duration) (string,
 	key := []byte(app.Config().SecretKey)
 	return token.SignedString(key)
 }
+
+const challengeAudience = "2fa"
+
+// CreateChallengeToken returns a short-lived token that proves the password
+// was verified and a second factor is still required. Expiration is checked
+// against the app clock.
+func CreateChallengeToken(app *bunapp.App, userID uint64, ttl time.Duration) (string, error) {
+	now := app.Clock().Now()
+	claims := &jwt.StandardClaims{
+		Subject:   strconv.FormatUint(userID, 10),
+		Audience:  challengeAudience,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+
+	key := []byte(app.Config().SecretKey)
+	return token.SignedString(key)
+}
+
+func decodeChallengeToken(app *bunapp.App, jwtToken string) (uint64, error) {
+	if len(jwtToken) == 0 {
+		return 0, errors.New("challenge token is missing or empty")
+	}
+
+	parser := &jwt.Parser{SkipClaimsValidation: true}
+	token, err := parser.ParseWithClaims(jwtToken, &jwt.StandardClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return []byte(app.Config().SecretKey), nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	claims := token.Claims.(*jwt.StandardClaims)
+	if !claims.VerifyAudience(challengeAudience, true) {
+		return 0, errors.New("invalid challenge token")
+	}
+	if !claims.VerifyExpiresAt(app.Clock().Now().Unix(), true) {
+		return 0, errors.New("challenge token has expired")
+	}
+
+	return strconv.ParseUint(claims.Subject, 10, 64)
+}
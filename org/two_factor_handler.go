@@ -0,0 +1,325 @@
+package org
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun-realworld-app/bunapp"
+	"github.com/uptrace/bun-realworld-app/httputil"
+	"github.com/uptrace/bun-realworld-app/httputil/httperror"
+	"github.com/uptrace/bunrouter"
+)
+
+// ChallengeTTL is how long a second factor challenge issued on login is valid.
+const ChallengeTTL = 5 * time.Minute
+
+const recoveryCodeCount = 10
+
+var (
+	errInvalidCode = httperror.New(http.StatusUnauthorized,
+		"invalid_code", "Invalid two-factor authentication code")
+	errTwoFactorEnabled = httperror.New(http.StatusConflict,
+		"two_factor_enabled", "Two-factor authentication is already enabled")
+	errTwoFactorNotEnrolled = httperror.New(http.StatusConflict,
+		"two_factor_not_enrolled", "Two-factor authentication enrollment was not started")
+	errTwoFactorDisabled = httperror.New(http.StatusConflict,
+		"two_factor_disabled", "Two-factor authentication is not enabled")
+)
+
+type RecoveryCode struct {
+	bun.BaseModel `bun:"alias:rc"`
+
+	ID       uint64
+	UserID   uint64
+	CodeHash string
+	UsedAt   time.Time `bun:",nullzero"`
+}
+
+type TwoFactorHandler struct {
+	app   *bunapp.App
+	users *UserService
+}
+
+func NewTwoFactorHandler(app *bunapp.App) TwoFactorHandler {
+	return TwoFactorHandler{
+		app:   app,
+		users: NewUserService(app),
+	}
+}
+
+// Enroll generates a new TOTP secret. It is not used until it is confirmed with a code.
+func (h TwoFactorHandler) Enroll(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+	user := UserFromContext(ctx)
+
+	if user.TOTPEnabled {
+		return errTwoFactorEnabled
+	}
+
+	secret, err := newTOTPSecret()
+	if err != nil {
+		return err
+	}
+
+	if _, err := h.app.IDB(ctx).NewUpdate().
+		Model(user).
+		Set("totp_secret = ?", secret).
+		Set("totp_last_step = 0").
+		Where("id = ?", user.ID).
+		Exec(ctx); err != nil {
+		return err
+	}
+
+	return bunrouter.JSON(w, bunrouter.H{
+		"twoFactor": bunrouter.H{
+			"secret":     secret,
+			"otpauthUri": totpURI(secret, user.Email),
+		},
+	})
+}
+
+// Confirm enables 2FA once the user proves the authenticator app is set up.
+func (h TwoFactorHandler) Confirm(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+	user := UserFromContext(ctx)
+
+	var in SecondFactorRequest
+	if err := httputil.UnmarshalJSON(w, req, &in, 10<<kb); err != nil {
+		return err
+	}
+
+	if user.TOTPEnabled {
+		return errTwoFactorEnabled
+	}
+	if user.TOTPSecret == "" {
+		return errTwoFactorNotEnrolled
+	}
+
+	step, ok, err := verifyTOTP(user.TOTPSecret, in.Code, h.app.Clock().Now(), user.TOTPLastStep)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errInvalidCode
+	}
+
+	codes, err := newRecoveryCodes()
+	if err != nil {
+		return err
+	}
+
+	if err := h.app.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewUpdate().
+			Model(user).
+			Set("totp_enabled = TRUE").
+			Set("totp_last_step = ?", step).
+			Where("id = ?", user.ID).
+			Exec(ctx); err != nil {
+			return err
+		}
+		return replaceRecoveryCodes(ctx, h.app, user.ID, codes)
+	}); err != nil {
+		return err
+	}
+
+	return bunrouter.JSON(w, bunrouter.H{
+		"twoFactor": bunrouter.H{
+			"enabled":       true,
+			"recoveryCodes": codes,
+		},
+	})
+}
+
+// Disable turns 2FA off. It requires a valid code or a recovery code.
+func (h TwoFactorHandler) Disable(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+	user := UserFromContext(ctx)
+
+	var in SecondFactorRequest
+	if err := httputil.UnmarshalJSON(w, req, &in, 10<<kb); err != nil {
+		return err
+	}
+
+	if !user.TOTPEnabled {
+		return errTwoFactorDisabled
+	}
+
+	if err := verifySecondFactor(ctx, h.app, user, in.Code, in.RecoveryCode); err != nil {
+		return err
+	}
+
+	if err := h.app.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewUpdate().
+			Model(user).
+			Set("totp_enabled = FALSE").
+			Set("totp_secret = NULL").
+			Set("totp_last_step = 0").
+			Where("id = ?", user.ID).
+			Exec(ctx); err != nil {
+			return err
+		}
+		return replaceRecoveryCodes(ctx, h.app, user.ID, nil)
+	}); err != nil {
+		return err
+	}
+
+	return bunrouter.JSON(w, bunrouter.H{
+		"twoFactor": bunrouter.H{
+			"enabled": false,
+		},
+	})
+}
+
+// Login completes a login that was interrupted by a 2FA challenge.
+func (h TwoFactorHandler) Login(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+
+	var in SecondFactorRequest
+	if err := httputil.UnmarshalJSON(w, req, &in, 10<<kb); err != nil {
+		return err
+	}
+
+	user, err := h.users.LoginSecondFactor(ctx, in.ChallengeToken, in.Code, in.RecoveryCode)
+	if err != nil {
+		SetRetryAfter(w, err)
+		return err
+	}
+
+	if err := setUserToken(h.app, user); err != nil {
+		return err
+	}
+
+	return bunrouter.JSON(w, bunrouter.H{
+		"user": user,
+	})
+}
+
+// verifySecondFactor accepts either a TOTP code or an unused recovery code.
+func verifySecondFactor(
+	ctx context.Context, app *bunapp.App, user *User, code, recoveryCode string,
+) error {
+	if recoveryCode != "" {
+		return useRecoveryCode(ctx, app, user, recoveryCode)
+	}
+
+	step, ok, err := verifyTOTP(user.TOTPSecret, code, app.Clock().Now(), user.TOTPLastStep)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errInvalidCode
+	}
+
+	// Remember the step so the same code can't be used twice.
+	res, err := app.IDB(ctx).NewUpdate().
+		Model(user).
+		Set("totp_last_step = ?", step).
+		Where("id = ?", user.ID).
+		Where("totp_last_step < ?", step).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return errInvalidCode
+	}
+
+	user.TOTPLastStep = step
+	return nil
+}
+
+func useRecoveryCode(ctx context.Context, app *bunapp.App, user *User, code string) error {
+	res, err := app.IDB(ctx).NewUpdate().
+		Model((*RecoveryCode)(nil)).
+		Set("used_at = ?", app.Clock().Now()).
+		Where("user_id = ?", user.ID).
+		Where("code_hash = ?", hashRecoveryCode(code)).
+		Where("used_at IS NULL").
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errInvalidCode
+	}
+	return nil
+}
+
+func replaceRecoveryCodes(ctx context.Context, app *bunapp.App, userID uint64, codes []string) error {
+	if _, err := app.IDB(ctx).NewDelete().
+		Model((*RecoveryCode)(nil)).
+		Where("user_id = ?", userID).
+		Exec(ctx); err != nil {
+		return err
+	}
+
+	if len(codes) == 0 {
+		return nil
+	}
+
+	rows := make([]RecoveryCode, 0, len(codes))
+	for _, code := range codes {
+		rows = append(rows, RecoveryCode{
+			UserID:   userID,
+			CodeHash: hashRecoveryCode(code),
+		})
+	}
+
+	if _, err := app.IDB(ctx).NewInsert().
+		Model(&rows).
+		Exec(ctx); err != nil {
+		return err
+	}
+	return nil
+}
+
+// newRecoveryCodes returns codes like 4f1a-92bc-07de.
+func newRecoveryCodes() ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 6)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		s := hex.EncodeToString(b)
+		codes = append(codes, s[:4]+"-"+s[4:8]+"-"+s[8:])
+	}
+	return codes, nil
+}
+
+// hashRecoveryCode uses a fast hash because recovery codes are random
+// and long enough not to be guessed.
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.ReplaceAll(code, "-", "")
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+func challengeResponse(app *bunapp.App, user *User) (*LoginResponse, error) {
+	token, err := CreateChallengeToken(app, user.ID, ChallengeTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LoginResponse{
+		Challenge: &Challenge{
+			Token:     token,
+			Type:      "totp",
+			ExpiresAt: app.Clock().Now().Add(ChallengeTTL),
+		},
+	}, nil
+}
@@ -2,6 +2,8 @@ package org
 
 import (
 	"context"
+	"encoding/json"
+	"time"
 
 	"github.com/uptrace/bun"
 	"github.com/uptrace/bun-realworld-app/bunapp"
@@ -17,7 +19,21 @@ type User struct {
 	Image        string `json:"image"`
 	Password     string `bun:"-" json:"password,omitempty"`
 	PasswordHash string `json:"-"`
-	Following    bool   `bun:",scanonly" json:"following"`
+	IsAdmin      bool   `json:"-"`
+	IsModerator  bool   `json:"-"`
+
+	// SuspendedAt is set by moderators. Suspended users can't log in.
+	SuspendedAt time.Time `bun:",nullzero" json:"-"`
+
+	TOTPSecret   string `bun:"totp_secret,nullzero" json:"-"`
+	TOTPEnabled  bool   `bun:"totp_enabled" json:"-"`
+	TOTPLastStep int64  `bun:"totp_last_step" json:"-"`
+
+	// Counters are maintained by triggers, see `bun db recount`.
+	FollowersCount int `json:"-"`
+	FollowingCount int `json:"-"`
+
+	Following bool `bun:",scanonly" json:"following"`
 
 	Token string `bun:"-" json:"token,omitempty"`
 }
@@ -32,11 +48,35 @@ type FollowUser struct {
 type Profile struct {
 	bun.BaseModel `bun:"users,alias:u"`
 
-	ID        uint64 `json:"-"`
-	Username  string `json:"username"`
-	Bio       string `json:"bio"`
-	Image     string `json:"image"`
-	Following bool   `bun:",scanonly" json:"following"`
+	ID             uint64 `json:"-"`
+	Username       string `json:"username"`
+	Bio            string `json:"bio"`
+	Image          string `json:"image"`
+	FollowersCount int    `json:"followersCount"`
+	FollowingCount int    `json:"followingCount"`
+	Following      bool   `bun:",scanonly" json:"following"`
+	// ChangedAt is set by a trigger on every change, including counters.
+	ChangedAt time.Time `bun:",nullzero" json:"-"`
+}
+
+// CanModerate reports whether the user can resolve reports.
+func (u *User) CanModerate() bool {
+	return u.IsAdmin || u.IsModerator
+}
+
+// Suspended reports whether a moderator suspended the user.
+func (u *User) Suspended() bool {
+	return !u.SuspendedAt.IsZero()
+}
+
+// MarshalJSON falls back to the generated avatar when the user has no image.
+func (p *Profile) MarshalJSON() ([]byte, error) {
+	type profile Profile
+	out := profile(*p)
+	if out.Image == "" {
+		out.Image = AvatarURL(out.Username)
+	}
+	return json.Marshal(out)
 }
 
 func NewProfile(user *User) *Profile {
@@ -45,12 +85,15 @@ func NewProfile(user *User) *Profile {
 		Bio:       user.Bio,
 		Image:     user.Image,
 		Following: user.Following,
+
+		FollowersCount: user.FollowersCount,
+		FollowingCount: user.FollowingCount,
 	}
 }
 
 func SelectUser(ctx context.Context, app *bunapp.App, id uint64) (*User, error) {
 	user := new(User)
-	if err := app.DB().NewSelect().
+	if err := app.IDB(ctx).NewSelect().
 		Model(user).
 		Where("id = ?", id).
 		Scan(ctx); err != nil {
@@ -61,7 +104,7 @@ func SelectUser(ctx context.Context, app *bunapp.App, id uint64) (*User, error)
 
 func SelectUserByUsername(ctx context.Context, app *bunapp.App, username string) (*User, error) {
 	user := new(User)
-	if err := app.DB().NewSelect().
+	if err := app.IDB(ctx).NewSelect().
 		Model(user).
 		Where("username = ?", username).
 		Scan(ctx); err != nil {
@@ -70,3 +113,14 @@ func SelectUserByUsername(ctx context.Context, app *bunapp.App, username string)
 
 	return user, nil
 }
+
+func selectUserByEmail(ctx context.Context, app *bunapp.App, email string) (*User, error) {
+	user := new(User)
+	if err := app.IDB(ctx).NewSelect().
+		Model(user).
+		Where("email = ?", email).
+		Scan(ctx); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
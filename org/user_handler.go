@@ -6,24 +6,22 @@ import (
 	"time"
 
 	"github.com/uptrace/bunrouter"
-	"golang.org/x/crypto/bcrypt"
 
-	"github.com/uptrace/bun"
 	"github.com/uptrace/bun-realworld-app/bunapp"
 	"github.com/uptrace/bun-realworld-app/httputil"
 )
 
 const kb = 10
 
-var errUserNotFound = errors.New("Not registered email or invalid password")
-
 type UserHandler struct {
-	app *bunapp.App
+	app     *bunapp.App
+	service *UserService
 }
 
 func NewUserHandler(app *bunapp.App) UserHandler {
 	return UserHandler{
-		app: app,
+		app:     app,
+		service: NewUserService(app),
 	}
 }
 
@@ -37,10 +35,7 @@ func (*UserHandler) Current(w http.ResponseWriter, req bunrouter.Request) error
 func (h UserHandler) Create(w http.ResponseWriter, req bunrouter.Request) error {
 	ctx := req.Context()
 
-	var in struct {
-		User *User `json:"user"`
-	}
-
+	var in UserRequest
 	if err := httputil.UnmarshalJSON(w, req, &in, 10<<kb); err != nil {
 		return err
 	}
@@ -49,17 +44,9 @@ func (h UserHandler) Create(w http.ResponseWriter, req bunrouter.Request) error
 		return errors.New(`JSON field "user" is required`)
 	}
 
-	user := in.User
-
-	var err error
-	user.PasswordHash, err = hashPassword(user.Password)
-	if err != nil {
-		return err
-	}
+	user := in.User.user()
 
-	if _, err := h.app.DB().NewInsert().
-		Model(user).
-		Exec(ctx); err != nil {
+	if err := h.service.Create(ctx, user); err != nil {
 		return err
 	}
 
@@ -67,7 +54,6 @@ func (h UserHandler) Create(w http.ResponseWriter, req bunrouter.Request) error
 		return err
 	}
 
-	user.Password = ""
 	return bunrouter.JSON(w, bunrouter.H{
 		"user": user,
 	})
@@ -76,12 +62,7 @@ func (h UserHandler) Create(w http.ResponseWriter, req bunrouter.Request) error
 func (h UserHandler) Login(w http.ResponseWriter, req bunrouter.Request) error {
 	ctx := req.Context()
 
-	var in struct {
-		User *struct {
-			Email    string `json:"email"`
-			Password string `json:"password"`
-		} `json:"user"`
-	}
+	var in LoginRequest
 	if err := httputil.UnmarshalJSON(w, req, &in, 10<<kb); err != nil {
 		return err
 	}
@@ -90,16 +71,18 @@ func (h UserHandler) Login(w http.ResponseWriter, req bunrouter.Request) error {
 		return errors.New(`JSON field "user" is required`)
 	}
 
-	user := new(User)
-	if err := h.app.DB().NewSelect().
-		Model(user).
-		Where("email = ?", in.User.Email).
-		Scan(ctx); err != nil {
+	user, err := h.service.Login(ctx, in.User.Email, in.User.Password, RemoteIP(req))
+	if err != nil {
+		SetRetryAfter(w, err)
 		return err
 	}
 
-	if err := comparePasswords(user.PasswordHash, in.User.Password); err != nil {
-		return err
+	if user.TOTPEnabled {
+		resp, err := challengeResponse(h.app, user)
+		if err != nil {
+			return err
+		}
+		return bunrouter.JSON(w, resp)
 	}
 
 	if err := setUserToken(h.app, user); err != nil {
@@ -115,10 +98,7 @@ func (h UserHandler) Update(w http.ResponseWriter, req bunrouter.Request) error
 	ctx := req.Context()
 	authUser := UserFromContext(ctx)
 
-	var in struct {
-		User *User `json:"user"`
-	}
-
+	var in UserUpdateRequest
 	if err := httputil.UnmarshalJSON(w, req, &in, 10<<kb); err != nil {
 		return err
 	}
@@ -127,28 +107,10 @@ func (h UserHandler) Update(w http.ResponseWriter, req bunrouter.Request) error
 		return errors.New(`JSON field "user" is required`)
 	}
 
-	user := in.User
-
-	var err error
-	user.PasswordHash, err = hashPassword(user.Password)
-	if err != nil {
-		return err
-	}
-
-	if _, err = h.app.DB().NewUpdate().
-		Model(authUser).
-		Set("email = ?", user.Email).
-		Set("username = ?", user.Username).
-		Set("password_hash = ?", user.PasswordHash).
-		Set("image = ?", user.Image).
-		Set("bio = ?", user.Bio).
-		Where("id = ?", authUser.ID).
-		Returning("*").
-		Exec(ctx); err != nil {
+	if err := h.service.Update(ctx, authUser, in.User); err != nil {
 		return err
 	}
 
-	user.Password = ""
 	return bunrouter.JSON(w, bunrouter.H{
 		"user": authUser,
 	})
@@ -157,105 +119,141 @@ func (h UserHandler) Update(w http.ResponseWriter, req bunrouter.Request) error
 func (h UserHandler) Profile(w http.ResponseWriter, req bunrouter.Request) error {
 	ctx := req.Context()
 
-	followingColumn := func(q *bun.SelectQuery) *bun.SelectQuery {
-		if authUser, ok := ctx.Value(userCtxKey{}).(*User); ok {
-			subq := h.app.DB().NewSelect().
-				Model((*FollowUser)(nil)).
-				Where("fu.followed_user_id = u.id").
-				Where("fu.user_id = ?", authUser.ID)
+	profile, err := h.service.Profile(ctx, UserFromContext(ctx), req.Param("username"))
+	if err != nil {
+		return err
+	}
 
-			q = q.ColumnExpr("EXISTS (?) AS following", subq)
-		} else {
-			q = q.ColumnExpr("false AS following")
-		}
+	return bunrouter.JSON(w, bunrouter.H{
+		"profile": profile,
+	})
+}
 
-		return q
+// Avatar serves a generated avatar, which clients can show when the profile has no image.
+func (h UserHandler) Avatar(w http.ResponseWriter, req bunrouter.Request) error {
+	svg, err := h.service.Avatar(req.Context(), req.Param("username"))
+	if err != nil {
+		return err
 	}
 
-	user := new(User)
-	if err := h.app.DB().NewSelect().
-		Model(user).
-		ColumnExpr("u.*").
-		Apply(followingColumn).
-		Where("username = ?", req.Param("username")).
-		Scan(ctx); err != nil {
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	// Disallow scripts in case the SVG is opened directly.
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+	_, err = w.Write(svg)
+	return err
+}
+
+func (h UserHandler) Follow(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+
+	profile, err := h.service.Follow(ctx, UserFromContext(ctx), req.Param("username"))
+	if err != nil {
 		return err
 	}
 
 	return bunrouter.JSON(w, bunrouter.H{
-		"profile": NewProfile(user),
+		"profile": profile,
 	})
 }
 
-func (h UserHandler) Follow(w http.ResponseWriter, req bunrouter.Request) error {
+func (h UserHandler) Unfollow(w http.ResponseWriter, req bunrouter.Request) error {
 	ctx := req.Context()
-	authUser := UserFromContext(ctx)
 
-	user, err := SelectUserByUsername(ctx, h.app, req.Param("username"))
+	profile, err := h.service.Unfollow(ctx, UserFromContext(ctx), req.Param("username"))
 	if err != nil {
 		return err
 	}
 
-	followUser := &FollowUser{
-		UserID:         authUser.ID,
-		FollowedUserID: user.ID,
+	return bunrouter.JSON(w, bunrouter.H{
+		"profile": profile,
+	})
+}
+
+func (h UserHandler) Block(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+
+	profile, err := h.service.Block(ctx, UserFromContext(ctx), req.Param("username"))
+	if err != nil {
+		return err
 	}
-	if _, err := h.app.DB().NewInsert().
-		Model(followUser).
-		Exec(ctx); err != nil {
+
+	return bunrouter.JSON(w, bunrouter.H{
+		"profile": profile,
+	})
+}
+
+func (h UserHandler) Unblock(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+
+	profile, err := h.service.Unblock(ctx, UserFromContext(ctx), req.Param("username"))
+	if err != nil {
 		return err
 	}
 
-	user.Following = true
 	return bunrouter.JSON(w, bunrouter.H{
-		"profile": NewProfile(user),
+		"profile": profile,
 	})
 }
 
-func (h UserHandler) Unfollow(w http.ResponseWriter, req bunrouter.Request) error {
+func (h UserHandler) Mute(w http.ResponseWriter, req bunrouter.Request) error {
 	ctx := req.Context()
-	authUser := UserFromContext(ctx)
 
-	user, err := SelectUserByUsername(ctx, h.app, req.Param("username"))
+	profile, err := h.service.Mute(ctx, UserFromContext(ctx), req.Param("username"))
 	if err != nil {
 		return err
 	}
 
-	if _, err := h.app.DB().NewDelete().
-		Model((*FollowUser)(nil)).
-		Where("user_id = ?", authUser.ID).
-		Where("followed_user_id = ?", user.ID).
-		Exec(ctx); err != nil {
+	return bunrouter.JSON(w, bunrouter.H{
+		"profile": profile,
+	})
+}
+
+func (h UserHandler) Unmute(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+
+	profile, err := h.service.Unmute(ctx, UserFromContext(ctx), req.Param("username"))
+	if err != nil {
 		return err
 	}
 
-	user.Following = false
 	return bunrouter.JSON(w, bunrouter.H{
-		"profile": NewProfile(user),
+		"profile": profile,
 	})
 }
 
-func setUserToken(app *bunapp.App, user *User) error {
-	token, err := CreateUserToken(app, user.ID, 24*time.Hour)
+func (h UserHandler) Blocked(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+
+	profiles, err := h.service.Blocked(ctx, UserFromContext(ctx))
 	if err != nil {
 		return err
 	}
-	user.Token = token
-	return nil
+
+	return bunrouter.JSON(w, bunrouter.H{
+		"profiles": profiles,
+	})
 }
 
-func hashPassword(pass string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.DefaultCost)
+func (h UserHandler) Muted(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx := req.Context()
+
+	profiles, err := h.service.Muted(ctx, UserFromContext(ctx))
 	if err != nil {
-		return "", err
+		return err
 	}
-	return string(bytes), nil
+
+	return bunrouter.JSON(w, bunrouter.H{
+		"profiles": profiles,
+	})
 }
 
-func comparePasswords(hash, pass string) error {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(pass))
+func setUserToken(app *bunapp.App, user *User) error {
+	token, err := CreateUserToken(app, user.ID, 24*time.Hour)
 	if err != nil {
-		return errUserNotFound
+		return err
 	}
+	user.Token = token
 	return nil
 }
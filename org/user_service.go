@@ -0,0 +1,451 @@
+package org
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun-realworld-app/bunapp"
+	"github.com/uptrace/bun-realworld-app/httputil/httperror"
+)
+
+var (
+	ErrUserNotFound       = httperror.NotFound("user not found")
+	ErrInvalidCredentials = httperror.New(http.StatusUnauthorized,
+		"invalid_credentials", "Not registered email or invalid password")
+	ErrSuspended = httperror.New(http.StatusForbidden,
+		"suspended", "the account is suspended")
+	ErrSuspendStaff = httperror.New(http.StatusForbidden,
+		"suspend_staff", "admins and moderators can't be suspended")
+)
+
+const profileCacheTTL = 5 * time.Minute
+
+func profileCacheKey(username string) string {
+	return "org:profile:" + username
+}
+
+// UserService manages users and their relations independently of HTTP.
+type UserService struct {
+	app *bunapp.App
+}
+
+func NewUserService(app *bunapp.App) *UserService {
+	return &UserService{
+		app: app,
+	}
+}
+
+// Create registers a new user with the plain password from user.Password.
+func (s *UserService) Create(ctx context.Context, user *User) error {
+	if err := checkPasswordPolicy(s.app, user.Password); err != nil {
+		return err
+	}
+
+	var err error
+	user.PasswordHash, err = NewPasswordHasher(s.app).Hash(user.Password)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.app.IDB(ctx).NewInsert().
+		Model(user).
+		Exec(ctx); err != nil {
+		return err
+	}
+
+	user.Password = ""
+	return nil
+}
+
+// Login checks the email and password. Callers must check User.TOTPEnabled
+// and ask for a second factor before starting a session.
+func (s *UserService) Login(ctx context.Context, email, password, ip string) (*User, error) {
+	throttle := newLoginThrottle(s.app)
+	emailKey := emailLoginKey(email)
+	ipKey := ipLoginKey(ip)
+
+	var user *User
+	if err := throttle.Guard(ctx, func(ctx context.Context) error {
+		var err error
+		user, err = s.login(ctx, throttle, email, password, emailKey, ipKey)
+		return err
+	}, emailKey, ipKey); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *UserService) login(
+	ctx context.Context, throttle *loginThrottle, email, password string, emailKey, ipKey loginKey,
+) (*User, error) {
+	user, err := selectUserByEmail(ctx, s.app, email)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			if err := throttle.Failed(ctx, nil, emailKey, ipKey); err != nil {
+				return nil, err
+			}
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+
+	hasher := NewPasswordHasher(s.app)
+
+	ok, err := hasher.Verify(user.PasswordHash, password)
+	if err != nil && err != errUnknownHash {
+		return nil, err
+	}
+	if !ok {
+		if err := throttle.Failed(ctx, user, emailKey, ipKey); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidCredentials
+	}
+
+	if err := throttle.Reset(ctx, emailKey); err != nil {
+		return nil, err
+	}
+
+	// Checked after the password, so suspensions are not disclosed to others.
+	if user.Suspended() {
+		return nil, ErrSuspended
+	}
+
+	if hasher.NeedsRehash(user.PasswordHash) {
+		if err := s.rehashPassword(ctx, hasher, user, password); err != nil {
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
+// rehashPassword upgrades the stored hash to the current algorithm and parameters.
+func (s *UserService) rehashPassword(
+	ctx context.Context, hasher PasswordHasher, user *User, password string,
+) error {
+	hash, err := hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.app.IDB(ctx).NewUpdate().
+		Model(user).
+		Set("password_hash = ?", hash).
+		Where("id = ?", user.ID).
+		Exec(ctx); err != nil {
+		return err
+	}
+
+	user.PasswordHash = hash
+	return nil
+}
+
+// Update changes the fields of the user profile that are set in the input.
+// Personal tokens can't change the email or the password.
+func (s *UserService) Update(ctx context.Context, user *User, in *UserUpdate) error {
+	// A leaked personal token must not be enough to take over the account.
+	if isPersonalTokenAuth(ctx) &&
+		(in.Password != nil || (in.Email != nil && *in.Email != user.Email)) {
+		return errSessionRequired
+	}
+
+	oldUsername := user.Username
+
+	q := s.app.IDB(ctx).NewUpdate().
+		Model(user).
+		Where("id = ?", user.ID).
+		Returning("*")
+
+	var changed bool
+	set := func(query string, arg interface{}) {
+		q = q.Set(query, arg)
+		changed = true
+	}
+
+	if in.Email != nil {
+		set("email = ?", *in.Email)
+	}
+	if in.Username != nil {
+		set("username = ?", *in.Username)
+	}
+	if in.Image != nil {
+		if err := validateImageURL(*in.Image); err != nil {
+			return err
+		}
+		set("image = ?", *in.Image)
+	}
+	if in.Bio != nil {
+		set("bio = ?", *in.Bio)
+	}
+	if in.Password != nil && *in.Password != "" {
+		if err := checkPasswordPolicy(s.app, *in.Password); err != nil {
+			return err
+		}
+
+		hash, err := NewPasswordHasher(s.app).Hash(*in.Password)
+		if err != nil {
+			return err
+		}
+		set("password_hash = ?", hash)
+	}
+
+	if !changed {
+		return nil
+	}
+	if _, err := q.Exec(ctx); err != nil {
+		return err
+	}
+	return s.app.Invalidate(ctx, profileCacheKey(oldUsername), profileCacheKey(user.Username))
+}
+
+// LoginSecondFactor completes a login that was interrupted by a 2FA challenge
+// with a TOTP code or a recovery code.
+func (s *UserService) LoginSecondFactor(
+	ctx context.Context, challengeToken, code, recoveryCode string,
+) (*User, error) {
+	userID, err := decodeChallengeToken(s.app, challengeToken)
+	if err != nil {
+		return nil, httperror.New(http.StatusUnauthorized, "invalid_challenge", err.Error())
+	}
+
+	user, err := selectActiveUser(ctx, s.app, userID)
+	if err != nil {
+		return nil, err
+	}
+	// The challenge is stale when 2FA was disabled after it was issued.
+	if !user.TOTPEnabled {
+		return nil, httperror.New(http.StatusUnauthorized,
+			"invalid_challenge", "two-factor authentication is disabled")
+	}
+
+	throttle := newLoginThrottle(s.app)
+	emailKey := emailLoginKey(user.Email)
+
+	if err := throttle.Guard(ctx, func(ctx context.Context) error {
+		if err := verifySecondFactor(ctx, s.app, user, code, recoveryCode); err != nil {
+			if err == errInvalidCode {
+				if err := throttle.Failed(ctx, user, emailKey); err != nil {
+					return err
+				}
+			}
+			return err
+		}
+		return throttle.Reset(ctx, emailKey)
+	}, emailKey); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Avatar returns the generated avatar of the user as an SVG image.
+func (s *UserService) Avatar(ctx context.Context, username string) ([]byte, error) {
+	profile, err := s.cachedProfile(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	return Identicon(profile.Username), nil
+}
+
+// Profile returns the public profile as seen by the viewer, who may be nil.
+func (s *UserService) Profile(ctx context.Context, viewer *User, username string) (*Profile, error) {
+	cached, err := s.cachedProfile(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := *cached
+	if viewer != nil {
+		profile.Following, err = s.app.IDB(ctx).NewSelect().
+			Model((*FollowUser)(nil)).
+			Where("user_id = ?", viewer.ID).
+			Where("followed_user_id = ?", profile.ID).
+			Exists(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &profile, nil
+}
+
+// cachedProfile returns the profile as seen by anonymous users.
+// The returned profile is shared and must not be modified.
+func (s *UserService) cachedProfile(ctx context.Context, username string) (*Profile, error) {
+	key := profileCacheKey(username)
+	if v, ok := s.app.Cache().Get(key); ok {
+		return v.(*Profile), nil
+	}
+
+	profile := new(Profile)
+	if err := s.app.IDB(ctx).NewSelect().
+		Model(profile).
+		Column("id", "username", "bio", "image", "followers_count", "following_count").
+		Where("username = ?", username).
+		Scan(ctx); err != nil {
+		return nil, userErr(err)
+	}
+
+	s.app.Cache().Set(key, profile, profileCacheTTL)
+	return profile, nil
+}
+
+// ProfilesByID returns the profiles of the users with the ids as seen by the viewer.
+// Unknown ids are omitted from the result.
+func (s *UserService) ProfilesByID(
+	ctx context.Context, viewer *User, ids []uint64,
+) (map[uint64]*Profile, error) {
+	var profiles []*Profile
+	if err := s.app.IDB(ctx).NewSelect().
+		Model(&profiles).
+		Column("id", "username", "bio", "image", "followers_count", "following_count").
+		Apply(s.followingColumn(viewer)).
+		Where("id IN (?)", bun.In(ids)).
+		Scan(ctx); err != nil {
+		return nil, err
+	}
+
+	m := make(map[uint64]*Profile, len(profiles))
+	for _, p := range profiles {
+		m[p.ID] = p
+	}
+	return m, nil
+}
+
+func (s *UserService) followingColumn(viewer *User) func(*bun.SelectQuery) *bun.SelectQuery {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		if viewer == nil {
+			return q.ColumnExpr("false AS following")
+		}
+
+		subq := s.app.DB().NewSelect().
+			Model((*FollowUser)(nil)).
+			Where("fu.followed_user_id = u.id").
+			Where("fu.user_id = ?", viewer.ID)
+
+		return q.ColumnExpr("EXISTS (?) AS following", subq)
+	}
+}
+
+func (s *UserService) Follow(ctx context.Context, user *User, username string) (*Profile, error) {
+	followed, err := SelectUserByUsername(ctx, s.app, username)
+	if err != nil {
+		return nil, userErr(err)
+	}
+
+	if err := s.app.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if err := lockUser(ctx, tx, followed.ID); err != nil {
+			return err
+		}
+
+		if blocked, err := IsBlocked(ctx, s.app, followed.ID, user.ID); err != nil {
+			return err
+		} else if blocked {
+			return ErrBlocked
+		}
+
+		res, err := tx.NewInsert().
+			Model(&FollowUser{
+				UserID:         user.ID,
+				FollowedUserID: followed.ID,
+			}).
+			On("CONFLICT DO NOTHING").
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n != 0 {
+			followed.FollowersCount++
+			return s.invalidateFollow(ctx, user, followed)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	followed.Following = true
+	return NewProfile(followed), nil
+}
+
+func (s *UserService) Unfollow(ctx context.Context, user *User, username string) (*Profile, error) {
+	followed, err := SelectUserByUsername(ctx, s.app, username)
+	if err != nil {
+		return nil, userErr(err)
+	}
+
+	res, err := s.app.IDB(ctx).NewDelete().
+		Model((*FollowUser)(nil)).
+		Where("user_id = ?", user.ID).
+		Where("followed_user_id = ?", followed.ID).
+		Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, err
+	} else if n != 0 {
+		followed.FollowersCount--
+		if err := s.invalidateFollow(ctx, user, followed); err != nil {
+			return nil, err
+		}
+	}
+
+	followed.Following = false
+	return NewProfile(followed), nil
+}
+
+// invalidateFollow drops the cached profiles whose follow counters changed.
+func (s *UserService) invalidateFollow(ctx context.Context, user, followed *User) error {
+	return s.app.Invalidate(ctx, profileCacheKey(user.Username), profileCacheKey(followed.Username))
+}
+
+// Suspend suspends the user with the id. Suspending a user twice is a no-op.
+// Admins and moderators can't be suspended.
+func (s *UserService) Suspend(ctx context.Context, id uint64) error {
+	user := new(User)
+	if err := s.app.IDB(ctx).NewSelect().
+		Model(user).
+		Column("id", "is_admin", "is_moderator").
+		Where("id = ?", id).
+		Scan(ctx); err != nil {
+		return userErr(err)
+	}
+	if user.CanModerate() {
+		return ErrSuspendStaff
+	}
+
+	_, err := s.app.IDB(ctx).NewUpdate().
+		Model((*User)(nil)).
+		Set("suspended_at = ?", s.app.Clock().Now()).
+		Where("id = ?", id).
+		Where("NOT is_admin AND NOT is_moderator").
+		Where("suspended_at IS NULL").
+		Exec(ctx)
+	return err
+}
+
+// Recount repairs the follow counters, e.g. after the triggers were disabled.
+func (s *UserService) Recount(ctx context.Context) error {
+	if _, err := s.app.IDB(ctx).NewUpdate().
+		Model((*User)(nil)).
+		Set("followers_count = (SELECT count(*) FROM follow_users AS fu WHERE fu.followed_user_id = u.id)").
+		Set("following_count = (SELECT count(*) FROM follow_users AS fu WHERE fu.user_id = u.id)").
+		Where("TRUE").
+		Exec(ctx); err != nil {
+		return err
+	}
+	return s.app.InvalidateAll(ctx)
+}
+
+func userErr(err error) error {
+	if err == sql.ErrNoRows {
+		return ErrUserNotFound
+	}
+	return err
+}
@@ -1,16 +0,0 @@
-#!/usr/bin/env bash
-set -x
-
-SCRIPTDIR="$( cd "$( dirname "${BASH_SOURCE[0]}" )" >/dev/null && pwd )"
-
-APIURL=${APIURL:-https://conduit.productionready.io/api}
-USERNAME=${USERNAME:-u`date +%s`}
-EMAIL=${EMAIL:-$USERNAME@mail.com}
-PASSWORD=${PASSWORD:-password}
-
-npx newman run $SCRIPTDIR/Conduit.postman_collection.json \
-  --delay-request 500 \
-  --global-var "APIURL=$APIURL" \
-  --global-var "USERNAME=$USERNAME" \
-  --global-var "EMAIL=$EMAIL" \
-  --global-var "PASSWORD=$PASSWORD"
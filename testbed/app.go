@@ -2,6 +2,7 @@ package testbed
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -9,11 +10,14 @@ import (
 
 	"github.com/benbjohnson/clock"
 	"github.com/uptrace/bun-realworld-app/bunapp"
+	"github.com/uptrace/bun-realworld-app/httputil/openapi"
 	"github.com/uptrace/bun-realworld-app/org"
 )
 
 type TestApp struct {
 	*bunapp.App
+
+	validator *openapi.Validator
 }
 
 func StartApp(ctx context.Context) *TestApp {
@@ -27,36 +31,63 @@ func StartApp(ctx context.Context) *TestApp {
 	app.SetClock(mock)
 
 	return &TestApp{
-		App: app,
+		App:       app,
+		validator: openapi.NewValidator(app.OpenAPI()),
 	}
 }
 
+// AdvanceClock moves the mocked app clock forward.
+func (app *TestApp) AdvanceClock(d time.Duration) {
+	app.Clock().(*clock.Mock).Add(d)
+}
+
+// Client returns a client that panics, failing the test, when a request
+// or response does not match the OpenAPI spec.
 func (app *TestApp) Client() Client {
 	return Client{
 		app: app.App,
+		handler: app.validator.Middleware(app.Router(), func(err error) {
+			panic(fmt.Errorf("openapi: %w", err))
+		}),
 	}
 }
 
 func (app *TestApp) TruncateDB(ctx context.Context) {
-	query := "TRUNCATE users, favorite_articles, follow_users, comments, articles, article_tags"
+	query := "TRUNCATE users, favorite_articles, follow_users, comments, articles, article_tags, " +
+		"login_attempts, notifications, recovery_codes, user_identities, oidc_states, personal_tokens, " +
+		"tags, tag_aliases, trending_tags, article_slug_history, media, " +
+		"bookmarks, bookmark_folders, block_users, mute_users, reports"
 	_, err := app.DB().ExecContext(ctx, query)
 	if err != nil {
 		panic(err)
 	}
+	app.Cache().Purge()
 }
 
 //------------------------------------------------------------------------------
 
 type Client struct {
-	app *bunapp.App
+	app     *bunapp.App
+	handler http.Handler
 
-	userID uint64
+	userID    uint64
+	authToken string
 }
 
 func (c Client) WithToken(userID uint64) Client {
 	return Client{
-		app:    c.app,
-		userID: userID,
+		app:     c.app,
+		handler: c.handler,
+		userID:  userID,
+	}
+}
+
+// WithAuthToken uses the token as is, for example, a personal access token.
+func (c Client) WithAuthToken(token string) Client {
+	return Client{
+		app:       c.app,
+		handler:   c.handler,
+		authToken: token,
 	}
 }
 
@@ -94,9 +125,11 @@ func (c Client) Serve(req *http.Request) *httptest.ResponseRecorder {
 			panic(err)
 		}
 		req.Header.Set("Authorization", "Token "+token)
+	} else if c.authToken != "" {
+		req.Header.Set("Authorization", "Token "+c.authToken)
 	}
 
 	resp := httptest.NewRecorder()
-	c.app.Router().ServeHTTP(resp, req)
+	c.handler.ServeHTTP(resp, req)
 	return resp
 }
@@ -0,0 +1,298 @@
+package testbed
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Conformance runs the RealWorld API conformance suite, originally shipped as
+// a Postman collection, against an in-process handler or a live server.
+type Conformance struct {
+	client  *http.Client
+	baseURL string
+
+	username string
+	email    string
+	password string
+
+	vars map[string]string
+}
+
+// NewConformance returns a suite that drives the handler in-process,
+// usually App.Router().
+func NewConformance(handler http.Handler) *Conformance {
+	return newConformance(&http.Client{
+		Transport: handlerTransport{handler: handler},
+	}, "http://selftest")
+}
+
+// NewRemoteConformance returns a suite that sends requests to the server
+// listening on addr, for example, localhost:8000.
+func NewRemoteConformance(addr string) *Conformance {
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	return newConformance(&http.Client{
+		Timeout: 10 * time.Second,
+	}, strings.TrimSuffix(addr, "/"))
+}
+
+func newConformance(client *http.Client, baseURL string) *Conformance {
+	// Redirects are reported as is, because following them turns PUT and POST into GET.
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	username := fmt.Sprintf("u%d", time.Now().UnixNano())
+	return &Conformance{
+		client:  client,
+		baseURL: baseURL,
+
+		username: username,
+		email:    username + "@mail.com",
+		// The collection used "password", which the breached password check rejects.
+		password: "selftest-" + username,
+
+		vars: make(map[string]string),
+	}
+}
+
+// Run executes the steps in order. Steps share state, for example, the slug of
+// the created article, so a failed step may cause the dependent steps to fail too.
+func (c *Conformance) Run(ctx context.Context) *ConformanceReport {
+	report := new(ConformanceReport)
+	for _, step := range conformanceSteps {
+		report.Results = append(report.Results, c.runStep(ctx, step))
+	}
+	return report
+}
+
+func (c *Conformance) runStep(ctx context.Context, step conformanceStep) ConformanceResult {
+	res := ConformanceResult{
+		Name:     step.name,
+		Method:   step.method,
+		Endpoint: endpoint(step.path),
+	}
+
+	path, err := c.expand(step.path)
+	if err != nil {
+		res.Errors = append(res.Errors, err.Error())
+		return res
+	}
+	body, err := c.expand(step.body)
+	if err != nil {
+		res.Errors = append(res.Errors, err.Error())
+		return res
+	}
+
+	var reqBody io.Reader
+	if body != "" {
+		reqBody = strings.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, step.method, c.baseURL+path, reqBody)
+	if err != nil {
+		res.Errors = append(res.Errors, err.Error())
+		return res
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if step.auth {
+		req.Header.Set("Authorization", "Token "+c.vars["token"])
+	}
+
+	start := time.Now()
+	resp, err := c.client.Do(req)
+	res.Duration = time.Since(start)
+	if err != nil {
+		res.Errors = append(res.Errors, err.Error())
+		return res
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		res.Errors = append(res.Errors, err.Error())
+		return res
+	}
+
+	res.Status = resp.StatusCode
+	if resp.StatusCode != http.StatusOK {
+		msg := fmt.Sprintf("got status %d, wanted 200", resp.StatusCode)
+		var httpErr struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(b, &httpErr) == nil && httpErr.Message != "" {
+			msg += ": " + httpErr.Message
+		}
+		res.Errors = append(res.Errors, msg)
+		return res
+	}
+
+	if step.check == nil {
+		return res
+	}
+
+	data := make(map[string]interface{})
+	if err := json.Unmarshal(b, &data); err != nil {
+		res.Errors = append(res.Errors, fmt.Sprintf("invalid JSON: %s", err))
+		return res
+	}
+
+	ck := &checker{conformance: c}
+	step.check(ck, data)
+	res.Errors = ck.errs
+	return res
+}
+
+var (
+	placeholderRE = regexp.MustCompile(`\{\{(\w+)\}\}`)
+	segmentRE     = regexp.MustCompile(`[^/]*\{\{(\w+)\}\}[^/]*`)
+)
+
+// endpoint turns /api/profiles/celeb_{{username}}?x=y into /api/profiles/{username}.
+func endpoint(path string) string {
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+	return segmentRE.ReplaceAllString(path, "{$1}")
+}
+
+// expand replaces {{var}} placeholders with the values saved by previous steps.
+func (c *Conformance) expand(s string) (string, error) {
+	var missing []string
+	s = placeholderRE.ReplaceAllStringFunc(s, func(m string) string {
+		name := m[2 : len(m)-2]
+		switch name {
+		case "username":
+			return c.username
+		case "email":
+			return c.email
+		case "password":
+			return c.password
+		}
+		v, ok := c.vars[name]
+		if !ok {
+			missing = append(missing, name)
+		}
+		return v
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("skipped: previous steps did not set %s", strings.Join(missing, ", "))
+	}
+	return s, nil
+}
+
+//------------------------------------------------------------------------------
+
+type ConformanceResult struct {
+	Name     string
+	Method   string
+	Endpoint string
+	Status   int
+	Duration time.Duration
+	Errors   []string
+}
+
+func (r *ConformanceResult) Passed() bool {
+	return len(r.Errors) == 0
+}
+
+type ConformanceReport struct {
+	Results []ConformanceResult
+}
+
+// Failed returns the number of failed steps.
+func (r *ConformanceReport) Failed() int {
+	var n int
+	for i := range r.Results {
+		if !r.Results[i].Passed() {
+			n++
+		}
+	}
+	return n
+}
+
+// WriteTo writes a pass/fail table grouped by endpoint followed by the failures.
+func (r *ConformanceReport) WriteTo(w io.Writer) (int64, error) {
+	type endpointResult struct {
+		method, path   string
+		passed, failed int
+	}
+
+	var endpoints []*endpointResult
+	byKey := make(map[string]*endpointResult)
+	for i := range r.Results {
+		res := &r.Results[i]
+		key := res.Method + " " + res.Endpoint
+		ep, ok := byKey[key]
+		if !ok {
+			ep = &endpointResult{method: res.Method, path: res.Endpoint}
+			byKey[key] = ep
+			endpoints = append(endpoints, ep)
+		}
+		if res.Passed() {
+			ep.passed++
+		} else {
+			ep.failed++
+		}
+	}
+	sort.SliceStable(endpoints, func(i, j int) bool {
+		if endpoints[i].path != endpoints[j].path {
+			return endpoints[i].path < endpoints[j].path
+		}
+		return endpoints[i].method < endpoints[j].method
+	})
+
+	var buf bytes.Buffer
+
+	tw := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	for _, ep := range endpoints {
+		status := "PASS"
+		if ep.failed > 0 {
+			status = "FAIL"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d/%d\n",
+			status, ep.method, ep.path, ep.passed, ep.passed+ep.failed)
+	}
+	_ = tw.Flush()
+
+	for i := range r.Results {
+		res := &r.Results[i]
+		if res.Passed() {
+			continue
+		}
+		fmt.Fprintf(&buf, "\n%s (%s %s):\n", res.Name, res.Method, res.Endpoint)
+		for _, err := range res.Errors {
+			fmt.Fprintf(&buf, "  - %s\n", err)
+		}
+	}
+
+	fmt.Fprintf(&buf, "\n%d steps, %d failed\n", len(r.Results), r.Failed())
+
+	return buf.WriteTo(w)
+}
+
+//------------------------------------------------------------------------------
+
+type handlerTransport struct {
+	handler http.Handler
+}
+
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp := httptest.NewRecorder()
+	t.handler.ServeHTTP(resp, req)
+	return resp.Result(), nil
+}
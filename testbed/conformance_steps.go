@@ -0,0 +1,379 @@
+package testbed
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+)
+
+type conformanceStep struct {
+	name   string
+	method string
+	path   string
+	body   string
+	auth   bool
+	check  func(ck *checker, data map[string]interface{})
+}
+
+// conformanceSteps mirror the RealWorld Postman collection. Paths use the
+// canonical routes registered by the app, for example, /api/user/.
+var conformanceSteps = []conformanceStep{
+	{
+		name:   "Register",
+		method: "POST",
+		path:   "/api/users",
+		body:   `{"user":{"email":"{{email}}", "password":"{{password}}", "username":"{{username}}"}}`,
+		check:  checkUser,
+	},
+	{
+		name:   "Login",
+		method: "POST",
+		path:   "/api/users/login",
+		body:   `{"user":{"email":"{{email}}", "password":"{{password}}"}}`,
+		check: func(ck *checker, data map[string]interface{}) {
+			checkUser(ck, data)
+			if user, ok := data["user"].(map[string]interface{}); ok {
+				ck.save("token", user["token"])
+			}
+		},
+	},
+	{
+		name:   "Current User",
+		method: "GET",
+		path:   "/api/user/",
+		auth:   true,
+		check:  checkUser,
+	},
+	{
+		name:   "Update User",
+		method: "PUT",
+		path:   "/api/user/",
+		body:   `{"user":{"email":"{{email}}"}}`,
+		auth:   true,
+		check:  checkUser,
+	},
+	{
+		name:   "All Articles",
+		method: "GET",
+		path:   "/api/articles",
+		check:  checkArticles,
+	},
+	{
+		name:   "Articles by Author",
+		method: "GET",
+		path:   "/api/articles?author=johnjacob",
+		check:  checkArticles,
+	},
+	{
+		name:   "Articles Favorited by Username",
+		method: "GET",
+		path:   "/api/articles?favorited=jane",
+		check:  checkArticles,
+	},
+	{
+		name:   "Articles by Tag",
+		method: "GET",
+		path:   "/api/articles?tag=dragons",
+		check:  checkArticles,
+	},
+	{
+		name:   "Create Article",
+		method: "POST",
+		path:   "/api/articles",
+		body: `{"article":{"title":"How to train your dragon", "description":"Ever wonder how?", ` +
+			`"body":"Very carefully.", "tagList":["dragons","training"]}}`,
+		auth: true,
+		check: func(ck *checker, data map[string]interface{}) {
+			article := checkArticle(ck, data)
+			ck.save("slug", article["slug"])
+		},
+	},
+	{
+		name:   "Feed",
+		method: "GET",
+		path:   "/api/articles/feed",
+		auth:   true,
+		check:  checkArticles,
+	},
+	{
+		name:   "All Articles with auth",
+		method: "GET",
+		path:   "/api/articles",
+		auth:   true,
+		check:  checkArticles,
+	},
+	{
+		name:   "Articles by Author with auth",
+		method: "GET",
+		path:   "/api/articles?author={{username}}",
+		auth:   true,
+		check: func(ck *checker, data map[string]interface{}) {
+			checkArticles(ck, data)
+			ck.equal(data, "articlesCount", float64(1))
+		},
+	},
+	{
+		name:   "Articles Favorited by Username with auth",
+		method: "GET",
+		path:   "/api/articles?favorited=jane",
+		auth:   true,
+		check:  checkArticles,
+	},
+	{
+		name:   "Single Article by slug",
+		method: "GET",
+		path:   "/api/articles/{{slug}}",
+		auth:   true,
+		check: func(ck *checker, data map[string]interface{}) {
+			checkArticle(ck, data)
+		},
+	},
+	{
+		name:   "Articles by Tag with auth",
+		method: "GET",
+		path:   "/api/articles?tag=dragons",
+		auth:   true,
+		check:  checkArticles,
+	},
+	{
+		name:   "Update Article",
+		method: "PUT",
+		path:   "/api/articles/{{slug}}",
+		body:   `{"article":{"body":"With two hands"}}`,
+		auth:   true,
+		check: func(ck *checker, data map[string]interface{}) {
+			article := checkArticle(ck, data)
+			ck.equal(article, "body", "With two hands")
+		},
+	},
+	{
+		name:   "Favorite Article",
+		method: "POST",
+		path:   "/api/articles/{{slug}}/favorite",
+		auth:   true,
+		check: func(ck *checker, data map[string]interface{}) {
+			article := checkArticle(ck, data)
+			ck.equal(article, "favorited", true)
+			if n, _ := article["favoritesCount"].(float64); n <= 0 {
+				ck.errorf(`"favoritesCount" is not greater than 0`)
+			}
+		},
+	},
+	{
+		name:   "Unfavorite Article",
+		method: "DELETE",
+		path:   "/api/articles/{{slug}}/favorite",
+		auth:   true,
+		check: func(ck *checker, data map[string]interface{}) {
+			article := checkArticle(ck, data)
+			ck.equal(article, "favorited", false)
+		},
+	},
+	{
+		name:   "Create Comment for Article",
+		method: "POST",
+		path:   "/api/articles/{{slug}}/comments",
+		body:   `{"comment":{"body":"Thank you so much!"}}`,
+		auth:   true,
+		check: func(ck *checker, data map[string]interface{}) {
+			comment := ck.object(data, "comment")
+			checkComment(ck, comment)
+			ck.save("commentId", comment["id"])
+		},
+	},
+	{
+		name:   "All Comments for Article",
+		method: "GET",
+		path:   "/api/articles/{{slug}}/comments",
+		auth:   true,
+		check: func(ck *checker, data map[string]interface{}) {
+			comments := ck.array(data, "comments")
+			if len(comments) == 0 {
+				ck.errorf(`"comments" is empty`)
+				return
+			}
+			comment, _ := comments[0].(map[string]interface{})
+			checkComment(ck, comment)
+		},
+	},
+	{
+		name:   "Delete Comment for Article",
+		method: "DELETE",
+		path:   "/api/articles/{{slug}}/comments/{{commentId}}",
+		auth:   true,
+	},
+	{
+		name:   "Delete Article",
+		method: "DELETE",
+		path:   "/api/articles/{{slug}}",
+		auth:   true,
+	},
+	{
+		name:   "Register Celeb",
+		method: "POST",
+		path:   "/api/users",
+		body:   `{"user":{"email":"celeb_{{email}}", "password":"{{password}}", "username":"celeb_{{username}}"}}`,
+		check:  checkUser,
+	},
+	{
+		name:   "Profile",
+		method: "GET",
+		path:   "/api/profiles/celeb_{{username}}",
+		auth:   true,
+		check: func(ck *checker, data map[string]interface{}) {
+			checkProfile(ck, data)
+		},
+	},
+	{
+		name:   "Follow Profile",
+		method: "POST",
+		path:   "/api/profiles/celeb_{{username}}/follow",
+		auth:   true,
+		check: func(ck *checker, data map[string]interface{}) {
+			profile := checkProfile(ck, data)
+			ck.equal(profile, "following", true)
+		},
+	},
+	{
+		name:   "Unfollow Profile",
+		method: "DELETE",
+		path:   "/api/profiles/celeb_{{username}}/follow",
+		auth:   true,
+		check: func(ck *checker, data map[string]interface{}) {
+			profile := checkProfile(ck, data)
+			ck.equal(profile, "following", false)
+		},
+	},
+	{
+		name:   "All Tags",
+		method: "GET",
+		path:   "/api/tags/",
+		check: func(ck *checker, data map[string]interface{}) {
+			ck.array(data, "tags")
+		},
+	},
+}
+
+func checkUser(ck *checker, data map[string]interface{}) {
+	user := ck.object(data, "user")
+	ck.has(user, "email", "username", "bio", "image", "token")
+}
+
+func checkProfile(ck *checker, data map[string]interface{}) map[string]interface{} {
+	profile := ck.object(data, "profile")
+	ck.has(profile, "username", "bio", "image", "following")
+	return profile
+}
+
+func checkArticle(ck *checker, data map[string]interface{}) map[string]interface{} {
+	article := ck.object(data, "article")
+	checkArticleFields(ck, article)
+	return article
+}
+
+func checkArticles(ck *checker, data map[string]interface{}) {
+	articles := ck.array(data, "articles")
+	ck.integer(data, "articlesCount")
+
+	if len(articles) == 0 {
+		ck.equal(data, "articlesCount", float64(0))
+		return
+	}
+	article, _ := articles[0].(map[string]interface{})
+	checkArticleFields(ck, article)
+}
+
+func checkArticleFields(ck *checker, article map[string]interface{}) {
+	ck.has(article, "title", "slug", "body", "description", "author", "favorited")
+	ck.timestamp(article, "createdAt")
+	ck.timestamp(article, "updatedAt")
+	ck.array(article, "tagList")
+	ck.integer(article, "favoritesCount")
+}
+
+func checkComment(ck *checker, comment map[string]interface{}) {
+	ck.has(comment, "id", "body", "author")
+	ck.timestamp(comment, "createdAt")
+	ck.timestamp(comment, "updatedAt")
+}
+
+//------------------------------------------------------------------------------
+
+// checker collects failed assertions like the tests object in Postman scripts.
+type checker struct {
+	conformance *Conformance
+	errs        []string
+}
+
+func (ck *checker) errorf(format string, args ...interface{}) {
+	ck.errs = append(ck.errs, fmt.Sprintf(format, args...))
+}
+
+func (ck *checker) has(obj map[string]interface{}, keys ...string) {
+	var missing []string
+	for _, key := range keys {
+		if _, ok := obj[key]; !ok {
+			missing = append(missing, fmt.Sprintf("%q", key))
+		}
+	}
+	if len(missing) > 0 {
+		ck.errorf("missing %s", strings.Join(missing, ", "))
+	}
+}
+
+func (ck *checker) object(obj map[string]interface{}, key string) map[string]interface{} {
+	v, ok := obj[key].(map[string]interface{})
+	if !ok {
+		ck.errorf("%q is not an object", key)
+		return nil
+	}
+	return v
+}
+
+func (ck *checker) array(obj map[string]interface{}, key string) []interface{} {
+	v, ok := obj[key].([]interface{})
+	if !ok {
+		ck.errorf("%q is not an array", key)
+		return nil
+	}
+	return v
+}
+
+func (ck *checker) integer(obj map[string]interface{}, key string) {
+	v, ok := obj[key].(float64)
+	if !ok || v != math.Trunc(v) {
+		ck.errorf("%q is not an integer", key)
+	}
+}
+
+var timestampRE = regexp.MustCompile(
+	`^\d{4,}-[01]\d-[0-3]\dT[0-2]\d:[0-5]\d:[0-5]\d(?:\.\d+)?(?:[+-][0-2]\d:[0-5]\d|Z)$`)
+
+func (ck *checker) timestamp(obj map[string]interface{}, key string) {
+	v, _ := obj[key].(string)
+	if !timestampRE.MatchString(v) {
+		ck.errorf("%q is not an ISO 8601 timestamp: %q", key, v)
+	}
+}
+
+func (ck *checker) equal(obj map[string]interface{}, key string, want interface{}) {
+	if got := obj[key]; got != want {
+		ck.errorf("%q is %v, wanted %v", key, got, want)
+	}
+}
+
+// save remembers the value for the {{name}} placeholders used by the next steps.
+func (ck *checker) save(name string, v interface{}) {
+	switch v := v.(type) {
+	case string:
+		if v != "" {
+			ck.conformance.vars[name] = v
+			return
+		}
+	case float64:
+		ck.conformance.vars[name] = fmt.Sprint(int64(v))
+		return
+	}
+	ck.errorf("can't save %s from %v", name, v)
+}
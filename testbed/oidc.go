@@ -0,0 +1,170 @@
+package testbed
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// OIDCProvider is a fake OpenID Connect provider that issues RS256 ID tokens.
+type OIDCProvider struct {
+	*httptest.Server
+
+	ClientID string
+	Now      func() time.Time
+
+	key *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]oidcGrant
+}
+
+type OIDCClaims struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	PreferredUsername string
+}
+
+type oidcGrant struct {
+	clientID      string
+	nonce         string
+	codeChallenge string
+	claims        OIDCClaims
+}
+
+func NewOIDCProvider(clientID string, now func() time.Time) *OIDCProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+
+	p := &OIDCProvider{
+		ClientID: clientID,
+		Now:      now,
+		key:      key,
+		codes:    make(map[string]oidcGrant),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("/jwks", p.jwks)
+	mux.HandleFunc("/token", p.token)
+	p.Server = httptest.NewServer(mux)
+
+	return p
+}
+
+// Authorize emulates the user signing in on the provider's login page
+// and returns the code that is passed to the redirect URL.
+func (p *OIDCProvider) Authorize(authURL string, claims OIDCClaims) (code, state string) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		panic(err)
+	}
+	q := u.Query()
+
+	code = base64.RawURLEncoding.EncodeToString([]byte(q.Get("state") + claims.Subject))
+
+	p.mu.Lock()
+	p.codes[code] = oidcGrant{
+		clientID:      q.Get("client_id"),
+		nonce:         q.Get("nonce"),
+		codeChallenge: q.Get("code_challenge"),
+		claims:        claims,
+	}
+	p.mu.Unlock()
+
+	return code, q.Get("state")
+}
+
+func (p *OIDCProvider) discovery(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, map[string]interface{}{
+		"issuer":                 p.URL,
+		"authorization_endpoint": p.URL + "/authorize",
+		"token_endpoint":         p.URL + "/token",
+		"jwks_uri":               p.URL + "/jwks",
+	})
+}
+
+func (p *OIDCProvider) jwks(w http.ResponseWriter, req *http.Request) {
+	pub := p.key.PublicKey
+	writeJSON(w, map[string]interface{}{
+		"keys": []map[string]interface{}{{
+			"kid": "test",
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+func (p *OIDCProvider) token(w http.ResponseWriter, req *http.Request) {
+	if err := req.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	code := req.PostForm.Get("code")
+
+	p.mu.Lock()
+	grant, ok := p.codes[code]
+	delete(p.codes, code)
+	p.mu.Unlock()
+
+	if !ok || grant.clientID != req.PostForm.Get("client_id") {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	sum := sha256.Sum256([]byte(req.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != grant.codeChallenge {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": "invalid_grant", "error_description": "PKCE"})
+		return
+	}
+
+	now := p.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":                p.URL,
+		"sub":                grant.claims.Subject,
+		"aud":                []string{p.ClientID},
+		"iat":                now.Unix(),
+		"exp":                now.Add(time.Hour).Unix(),
+		"nonce":              grant.nonce,
+		"email":              grant.claims.Email,
+		"email_verified":     grant.claims.EmailVerified,
+		"preferred_username": grant.claims.PreferredUsername,
+	})
+	token.Header["kid"] = "test"
+
+	idToken, err := token.SignedString(p.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, map[string]string{
+		"access_token": "access-" + code,
+		"token_type":   "Bearer",
+		"id_token":     idToken,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
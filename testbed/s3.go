@@ -0,0 +1,90 @@
+package testbed
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// S3Server is a fake S3-compatible server that keeps objects in memory.
+// It only checks that requests are signed with the access key.
+type S3Server struct {
+	*httptest.Server
+
+	AccessKey string
+
+	mu      sync.Mutex
+	objects map[string]S3Object
+}
+
+type S3Object struct {
+	ContentType string
+	Data        []byte
+}
+
+func NewS3Server(accessKey string) *S3Server {
+	s := &S3Server{
+		AccessKey: accessKey,
+		objects:   make(map[string]S3Object),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Object returns the object stored under the path, e.g. "/bucket/key".
+func (s *S3Server) Object(path string) (S3Object, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	obj, ok := s.objects[path]
+	return obj, ok
+}
+
+// Len returns the number of stored objects.
+func (s *S3Server) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.objects)
+}
+
+func (s *S3Server) handle(w http.ResponseWriter, req *http.Request) {
+	auth := req.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 ") ||
+		!strings.Contains(auth, "Credential="+s.AccessKey+"/") ||
+		req.Header.Get("X-Amz-Date") == "" {
+		http.Error(w, "AccessDenied", http.StatusForbidden)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch req.Method {
+	case http.MethodPut:
+		data, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.objects[req.URL.Path] = S3Object{
+			ContentType: req.Header.Get("Content-Type"),
+			Data:        data,
+		}
+	case http.MethodGet:
+		obj, ok := s.objects[req.URL.Path]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", obj.ContentType)
+		_, _ = w.Write(obj.Data)
+	case http.MethodDelete:
+		delete(s.objects, req.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "MethodNotAllowed", http.StatusMethodNotAllowed)
+	}
+}